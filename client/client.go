@@ -0,0 +1,218 @@
+// Package client is the Go client of the json api of the panel, described by its
+// /api/v1/openapi.json. It's used with an api token made in the API tokens page of the panel.
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+// Account types.
+const (
+	Vmess       = "vmess"
+	Shadowsocks = "shadowsocks"
+	SSTP        = "sstp"
+)
+
+// Account is an account of any type.
+type Account struct {
+	Type string `json:"type"`
+	// ID is the server id of the vmess, the password of the shadowsocks and the username of the sstp accounts.
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	DeviceID   string `json:"device_id,omitempty"`
+	StartDate  string `json:"start_date,omitempty"`
+	ExpireDate string `json:"expire_date"`
+	Port       int    `json:"port,omitempty"`
+	Suspended  bool   `json:"suspended"`
+	// Password is only given once, when the sstp account is created.
+	Password string `json:"password,omitempty"`
+}
+
+// AccountRequest creates or edits the account. The type, the username, the device id of the
+// vmess and shadowsocks accounts and the end date are needed to create one, the dates are in
+// the form of 2006-01-02. The empty fields of the edits are kept.
+type AccountRequest struct {
+	Type        string `json:"type,omitempty"`
+	Username    string `json:"username,omitempty"`
+	DeviceID    string `json:"device_id,omitempty"`
+	ServerID    string `json:"server_id,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Description string `json:"description,omitempty"`
+	StartDate   string `json:"start_date,omitempty"`
+	EndDate     string `json:"end_date,omitempty"`
+}
+
+// URIs are the keys of the vmess or shadowsocks account for the apps.
+type URIs struct {
+	URI           string `json:"uri"`
+	Remarks       string `json:"remarks"`
+	LockedURI     string `json:"locked_uri"`
+	LockedRemarks string `json:"locked_remarks"`
+}
+
+// ServerStatus is the status of the vpn services and the counts of the accounts.
+type ServerStatus struct {
+	Services      map[string]string `json:"services"`
+	UptimeSeconds int64             `json:"uptime_seconds"`
+	MemoryBytes   uint64            `json:"memory_bytes"`
+	Accounts      map[string]int    `json:"accounts"`
+}
+
+// Error is the error object of the failed requests.
+type Error struct {
+	Status int `json:"status"`
+	// Code is the snake case of the http status text, eg. not_found or payment_required.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("lothone api: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Client calls the api of a panel.
+type Client struct {
+	baseURL string
+	token   string
+	// HTTPClient sends the requests, http.DefaultClient if it's nil.
+	HTTPClient *http.Client
+}
+
+// New returns the Client of the panel at the baseURL, eg. https://panel.example.com, using the api token.
+func New(baseURL, token string) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/") + "/api/v1", token: token}
+}
+
+// ListAccounts lists the accounts of the accountType, or of every type if it's empty.
+func (c *Client) ListAccounts(ctx context.Context, accountType string) ([]Account, error) {
+	path := "/accounts"
+	if accountType != "" {
+		path += "?type=" + url.QueryEscape(accountType)
+	}
+	var list struct {
+		Accounts []Account `json:"accounts"`
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &list)
+	return list.Accounts, err
+}
+
+// GetAccount gets the account of the accountType with the id.
+func (c *Client) GetAccount(ctx context.Context, accountType, id string) (Account, error) {
+	var a Account
+	err := c.do(ctx, http.MethodGet, accountPath(accountType, id, ""), nil, &a)
+	return a, err
+}
+
+// CreateAccount creates the account of the req.
+func (c *Client) CreateAccount(ctx context.Context, req AccountRequest) (Account, error) {
+	var a Account
+	err := c.do(ctx, http.MethodPost, "/accounts", req, &a)
+	return a, err
+}
+
+// EditAccount changes the username, the device id or the dates of the account to the ones of the req.
+func (c *Client) EditAccount(ctx context.Context, accountType, id string, req AccountRequest) (Account, error) {
+	var a Account
+	err := c.do(ctx, http.MethodPatch, accountPath(accountType, id, ""), req, &a)
+	return a, err
+}
+
+// ExtendAccount moves the expire date of the account the days later, from today if it's expired.
+func (c *Client) ExtendAccount(ctx context.Context, accountType, id string, days int) (Account, error) {
+	var a Account
+	err := c.do(ctx, http.MethodPost, accountPath(accountType, id, "/extend"), map[string]int{"days": days}, &a)
+	return a, err
+}
+
+// SuspendAccount takes the account off the server until it's resumed.
+func (c *Client) SuspendAccount(ctx context.Context, accountType, id string) (Account, error) {
+	var a Account
+	err := c.do(ctx, http.MethodPost, accountPath(accountType, id, "/suspend"), nil, &a)
+	return a, err
+}
+
+// ResumeAccount puts the suspended account back on the server.
+func (c *Client) ResumeAccount(ctx context.Context, accountType, id string) (Account, error) {
+	var a Account
+	err := c.do(ctx, http.MethodPost, accountPath(accountType, id, "/resume"), nil, &a)
+	return a, err
+}
+
+// DeleteAccount deletes the account.
+func (c *Client) DeleteAccount(ctx context.Context, accountType, id string) error {
+	return c.do(ctx, http.MethodDelete, accountPath(accountType, id, ""), nil, nil)
+}
+
+// AccountURIs gets the keys of the account.
+func (c *Client) AccountURIs(ctx context.Context, accountType, id string) (URIs, error) {
+	var uris URIs
+	err := c.do(ctx, http.MethodGet, accountPath(accountType, id, "/uris"), nil, &uris)
+	return uris, err
+}
+
+// ServerStatus gets the status of the server.
+func (c *Client) ServerStatus(ctx context.Context) (ServerStatus, error) {
+	var status ServerStatus
+	err := c.do(ctx, http.MethodGet, "/server/status", nil, &status)
+	return status, err
+}
+
+func accountPath(accountType, id, action string) string {
+	return "/accounts/" + url.PathEscape(accountType) + "/" + url.PathEscape(id) + action
+}
+
+// do sends the request with the body as json, decoding the response into the result if it's not nil.
+// The failed requests return an *Error.
+func (c *Client) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failed struct {
+			Error *Error `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if json.Unmarshal(data, &failed) != nil || failed.Error == nil {
+			return &Error{Status: resp.StatusCode, Code: "unexpected_response", Message: strings.TrimSpace(string(data))}
+		}
+		return failed.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	json "github.com/goccy/go-json"
+)
+
+func TestClient(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer lt_token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/accounts":
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(Account{Type: Vmess, ID: "id", Username: "user", ExpireDate: "2026-01-01"})
+				return
+			}
+			w.Write([]byte(`{"accounts": [{"type": "sstp", "id": "user", "username": "user", "expire_date": "2026-01-01", "suspended": false}]}`))
+		case "/api/v1/accounts/vmess/id":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v1/accounts/vmess/id/extend":
+			w.Write([]byte(`{"type": "vmess", "id": "id", "username": "user", "expire_date": "2026-02-01", "suspended": false}`))
+		case "/api/v1/server/status":
+			w.Write([]byte(`{"services": {"v2ray": "active"}, "uptime_seconds": 10, "memory_bytes": 20, "accounts": {"vmess": 1}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"status": 404, "code": "not_found", "message": "Account not found."}}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := New(server.URL+"/", "lt_token")
+
+	accounts, err := c.ListAccounts(ctx, SSTP)
+	if err != nil || len(accounts) != 1 || accounts[0].Username != "user" {
+		t.Errorf("unexpected accounts %v, %v", accounts, err)
+	}
+	created, err := c.CreateAccount(ctx, AccountRequest{Type: Vmess, Username: "user", EndDate: "2026-01-01"})
+	if err != nil || created.ID != "id" {
+		t.Errorf("unexpected created account %v, %v", created, err)
+	}
+	extended, err := c.ExtendAccount(ctx, Vmess, "id", 31)
+	if err != nil || extended.ExpireDate != "2026-02-01" {
+		t.Errorf("unexpected extended account %v, %v", extended, err)
+	}
+	if err := c.DeleteAccount(ctx, Vmess, "id"); err != nil {
+		t.Error(err)
+	}
+	status, err := c.ServerStatus(ctx)
+	if err != nil || status.Services["v2ray"] != "active" || status.Accounts[Vmess] != 1 {
+		t.Errorf("unexpected status %v, %v", status, err)
+	}
+
+	_, err = c.GetAccount(ctx, Shadowsocks, "missing")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Code != "not_found" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	want := []string{
+		"GET /api/v1/accounts?type=sstp ",
+		`POST /api/v1/accounts {"type":"vmess","username":"user","end_date":"2026-01-01"}`,
+		`POST /api/v1/accounts/vmess/id/extend {"days":31}`,
+		"DELETE /api/v1/accounts/vmess/id ",
+		"GET /api/v1/server/status ",
+		"GET /api/v1/accounts/shadowsocks/missing ",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected requests %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected the request %q, got %q", want[i], got[i])
+		}
+	}
+}
+
+func TestUnexpectedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, "lt_token").ServerStatus(context.Background())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway || apiErr.Message != "Bad Gateway" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
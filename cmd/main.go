@@ -2,25 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"mime"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/httprate"
 	"github.com/htetmyatthar/lothone/handler"
+	"github.com/htetmyatthar/lothone/internal/app"
 	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/logging"
+	"github.com/htetmyatthar/lothone/internal/metrics"
 	"github.com/htetmyatthar/lothone/internal/utils"
 	"github.com/htetmyatthar/lothone/middleware/auth"
-	"github.com/htetmyatthar/lothone/middleware/csrf"
-	"github.com/htetmyatthar/lothone/middleware/session"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/middleware/secure"
+	"github.com/htetmyatthar/lothone/static"
 )
 
 // HACK: SERVER UUID is always unique on each server and should not be the same on one server.
@@ -29,24 +37,151 @@ import (
 // BUG: add auto installation feature
 
 var (
-	staticHandler http.Handler = utils.InitStaticServer()
+	// certificate is the tls certificate the server is serving with, replaced on the configuration reloads.
+	certificate atomic.Pointer[tls.Certificate]
 )
 
 func main() {
+	versionFlag := flag.Bool("version", false, "Show verion number.")
+	installFlag := flag.Bool("install", false, "Install server manager, get cert using certbot, setup vpn protocols")
+	uninstallFlag := flag.Bool("uninstall", false, "Uninstall server manager, remove the vpn services and their firewall rules")
+	archiveDir := flag.String("archive", "", "directory to archive the users files into while uninstalling, nothing is archived if empty")
+	// the admin commands open the database, which the running panel holds.
+	const stopped = ", the panel must be stopped first"
+	adminAdd := flag.String("admin-add", "", "add the admin with the username, the password is read from the standard input"+stopped)
+	adminRemove := flag.String("admin-remove", "", "remove the admin with the username, after removing it from the admins or the admins_file setting"+stopped)
+	adminReset := flag.String("admin-reset", "", "reset the password of the admin with the username, the password is read from the standard input"+stopped)
+	adminResetTOTP := flag.String("admin-reset-totp", "", "disable the two-factor authentication and remove the passkeys of the admin with the username"+stopped)
+	adminSetRole := flag.String("admin-set-role", "", "set the role of the admin with the username to the -role"+stopped)
+	adminRole := flag.String("role", "owner", "role of the added admin or the -admin-set-role, one of owner, admin, reseller and read-only")
+	adminList := flag.Bool("admin-list", false, "list the admins with their roles"+stopped)
+	auditVerify := flag.Bool("audit-verify", false, "verify that the audit log is not changed, exits with 1 if it is"+stopped)
+
+	// parse the flags and load the configuration.
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+
+	// Check if the version flag was set
+	if *versionFlag {
+		fmt.Printf("LoThone V2ray VPN server Panel.%s\nVmess protocol.\n", config.Version)
+		os.Exit(0) // Exit after showing the version
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Set(cfg)
+
+	// the configuration is validated, the level is known.
+	l, _ := logging.ParseLevel(cfg.LogLevel)
+	err = logging.Setup(os.Stderr, cfg.LogFormat, l)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.OnReload(func(old, c *config.Config) (func(), error) {
+		l, err := logging.ParseLevel(c.LogLevel)
+		if err != nil {
+			return nil, err
+		}
+		return func() { logging.SetLevel(l) }, nil
+	})
+
+	if *installFlag {
+		// install all the things.
+		config.Install()
+		os.Exit(0) // Exit after installing the programs.
+	}
+
+	if *uninstallFlag {
+		report := config.Uninstall(*archiveDir)
+		report.Print()
+		if len(report.Failed) != 0 {
+			os.Exit(1)
+		}
+		os.Exit(0) // Exit after uninstalling the programs.
+	}
+
+	for command, username := range map[string]string{app.AdminAdd: *adminAdd, app.AdminRemove: *adminRemove, app.AdminReset: *adminReset, app.AdminTOTPReset: *adminResetTOTP, app.AdminSetRole: *adminSetRole} {
+		if username == "" {
+			continue
+		}
+		err := app.AdminCommand(cfg, command, username, *adminRole)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0) // Exit after managing the admins.
+	}
+	if *adminList {
+		err := app.AdminCommand(cfg, app.AdminList, "", "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+	if *auditVerify {
+		err := app.AuditVerify(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	a, err := app.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer a.Close()
+
+	cert, err := tls.LoadX509KeyPair(cfg.WebCert, cfg.WebKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	certificate.Store(&cert)
+
+	// certificates are loaded again on every reload, the renewed ones keep the same paths.
+	config.OnReload(func(old, c *config.Config) (func(), error) {
+		cert, err := tls.LoadX509KeyPair(c.WebCert, c.WebKey)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the web certificate: %v", err)
+		}
+		return func() { certificate.Store(&cert) }, nil
+	})
+
 	// The HTTP Server
-	server := &http.Server{Addr: *config.WebHostIP + *config.WebPort, Handler: service()}
+	server := &http.Server{
+		Addr:    cfg.WebHostIP + cfg.WebPort,
+		Handler: service(a),
+		TLSConfig: &tls.Config{
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return certificate.Load(), nil
+			},
+		},
+	}
 
 	// Server run context
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
 
+	// Listen for SIGHUP to reload the configuration without dropping the sessions.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("SIGHUP received, reloading the configuration.")
+			_, err := config.Reload()
+			if err != nil {
+				slog.Error("Configuration reload rejected, keeping the current one.", "err", err)
+			}
+		}
+	}()
+
 	// Listen for syscall signals for process to interrupt/quit
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	go func() {
 		<-sig
 
 		// Shutdown signal with grace period of 10 seconds
-		shutdownCtx, _ := context.WithTimeout(serverCtx, 10*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(serverCtx, 10*time.Second)
+		defer cancel()
 
 		go func() {
 			<-shutdownCtx.Done()
@@ -63,8 +198,8 @@ func main() {
 		serverStopCtx()
 	}()
 
-	// Run the server
-	err := server.ListenAndServeTLS(*config.WebCert, *config.WebKey)
+	// Run the server, the certificate is from the TLSConfig.
+	err = server.ListenAndServeTLS("", "")
 	// err := server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		log.Fatal(err)
@@ -74,16 +209,24 @@ func main() {
 	<-serverCtx.Done()
 }
 
-func service() http.Handler {
+func service(a *app.App) http.Handler {
+	staticHandler := utils.InitStaticServer()
+
 	r := chi.NewRouter()
-	// NOTE: logger should always be the first.
-	r.Use(middleware.Logger)
-	r.Use(session.GetSessionMgr().LoadAndSave)
+	// the ip address of the client is resolved once for every handler, behind the trusted proxies.
+	r.Use(clientip.Middleware(handler.ResolveClientIP))
+	// NOTE: logger should always be the first after the ip address.
+	r.Use(logging.Middleware)
+	r.Use(metrics.Middleware)
+	r.Use(secure.Middleware(handler.SecurityOptions))
+	r.Use(a.Sessions.LoadAndSave)
 	r.Use(middleware.CleanPath)
 	r.Use(middleware.StripSlashes)
 	r.Use(middleware.AllowContentType("application/json", "text/css", "text/javascript", "text/plain", "text/xml", "text/html", "application/x-www-form-urlencoded"))
 	r.Use(middleware.Heartbeat("/ping"))
-	r.Use(csrf.CSRFMiddleware)
+	// the api token requests are authenticated before the csrf check, which they are exempt from.
+	r.Use(auth.TokenMiddleware(a.Tokens.Authenticate))
+	r.Use(a.CSRF.CSRFMiddleware)
 
 	r.Handle("/static/*", http.StripPrefix("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract extension
@@ -93,23 +236,17 @@ func service() http.Handler {
 			w.Header().Set("Content-Type", mimeType)
 		}
 
-		// Disable caching for debugging
-		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, proxy-revalidate")
-		w.Header().Set("Expires", "0")
-		w.Header().Set("Pragma", "no-cache")
+		// the fingerprinted urls change with the content, the others are checked again every time.
+		if static.Fingerprinted(r.URL.Path, r.URL.Query().Get("v")) {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
 
 		staticHandler.ServeHTTP(w, r)
 	})))
 
-	// public routes.
-	r.Group(func(r chi.Router) {
-		r.Use(httprate.LimitByIP(20, 1*time.Minute))
-	})
-
-	// private routes.
-	r.Group(func(r chi.Router) {
-		r.Use(auth.AuthMiddleware)
-	})
+	handler.New(a).Routes(r)
 
 	return r
 }
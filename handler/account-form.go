@@ -1,8 +1,6 @@
 package handler
 
 import (
-	"context"
-	"log"
 	"net/http"
 	"strconv"
 
@@ -10,28 +8,28 @@ import (
 	"github.com/htetmyatthar/lothone/web/components"
 )
 
-func accountFormGet(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) accountFormGet(w http.ResponseWriter, r *http.Request) {
 	accountType := r.FormValue("type")
 	if accountType == "" {
-		log.Println("account type is empty")
+		logger(r).Warn("account type is empty.")
 		http.Error(w, "Invalid Request", http.StatusBadRequest)
 		return
 	}
 
 	t, err := strconv.Atoi(accountType)
 	if err != nil {
-		log.Println("account type is not being parsed correctly", err)
+		logger(r).Warn("account type is not being parsed correctly.", "err", err)
 		http.Error(w, "Invalid Request", http.StatusBadRequest)
 		return
 	}
 
 	switch utils.AccountType(t) {
 	case utils.VmessAccountType:
-		components.VmessAccountCreate().Render(context.Background(), w)
+		components.VmessAccountCreate().Render(r.Context(), w)
 	case utils.ShadowsocksAccountType:
-		components.ShadowsocksAccountCreate().Render(context.Background(), w)
+		components.ShadowsocksAccountCreate().Render(r.Context(), w)
 	case utils.SstpAccountType:
-		components.SstpAccountCreate().Render(context.Background(), w)
+		components.SstpAccountCreate().Render(r.Context(), w)
 	}
 	return
 }
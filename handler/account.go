@@ -1,9 +1,6 @@
 package handler
 
 import (
-	"context"
-	"log"
-	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -12,9 +9,10 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
 	"github.com/htetmyatthar/lothone/internal/utils"
-	"github.com/htetmyatthar/lothone/middleware/csrf"
-	"github.com/htetmyatthar/lothone/middleware/session"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
 	"github.com/htetmyatthar/lothone/web/components"
 	"github.com/htetmyatthar/lothone/web/layout"
 )
@@ -23,8 +21,9 @@ import (
 const dateFormat = "2006-01-02"
 const defaultAlterID = 1
 
-func accountCreateHTMX(w http.ResponseWriter, r *http.Request) {
-	log.Println("Account creation request received")
+func (h *Handler) accountCreateHTMX(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+	logger(r).Debug("Account creation request received.")
 
 	username := r.FormValue("username")
 	password := r.FormValue("password")
@@ -34,63 +33,70 @@ func accountCreateHTMX(w http.ResponseWriter, r *http.Request) {
 	sDate := r.FormValue("startDate")
 	eDate := r.FormValue("endDate")
 
-	log.Printf("Received form values - username: %s, password: %s, type: %s, serverId: %s, deviceId: %s, startDate: %s, endDate: %s", username, password, accType, serverId, deviceId, sDate, eDate)
+	logger(r).Debug("Received form values.", "username", username, "type", accType, "server_id", serverId, "device_id", deviceId, "start_date", sDate, "end_date", eDate)
 
 	if deviceId == "" || sDate == "" || eDate == "" || username == "" || accType == "" {
-		log.Println("Missing required fields in request")
+		logger(r).Warn("Missing required fields in request.")
 		http.Error(w, "Invalid Request: missing required fields.", http.StatusBadRequest)
 		return
 	}
 
 	if serverId == "" && password == "" {
-		log.Println("Both serverId and password are missing")
+		logger(r).Warn("Both serverId and password are missing.")
 		http.Error(w, "Invalid Request: both serverid and password can't be empty.", http.StatusBadRequest)
 		return
 	}
 
 	if (serverId != "" && uuid.Validate(serverId) != nil) || (password != "" && uuid.Validate(password) != nil) {
-		log.Println("Invalid UUID format in serverId or password")
+		logger(r).Warn("Invalid UUID format in serverId or password.")
 		http.Error(w, "Invalid Request: invalid UUID format.", http.StatusBadRequest)
 		return
 	}
 
 	parsedAccType, err := utils.ParseAccountType(accType)
 	if err != nil {
-		log.Printf("Invalid account type: %s", accType)
+		logger(r).Warn("Invalid account type.", "type", accType)
 		http.Error(w, "Invalid Request: invalid account type.", http.StatusBadRequest)
 		return
 	}
 
 	if username == "-" {
 		username = "unknown/admin"
-		log.Println("Username set to default 'unknown/admin'")
+		logger(r).Debug("Username set to default 'unknown/admin'.")
 	}
 
 	startDate, err := time.Parse(dateFormat, sDate)
 	if err != nil {
-		log.Println("Invalid start date format")
+		logger(r).Warn("Invalid start date format.")
 		http.Error(w, "Invalid Request: invalid date format", http.StatusBadRequest)
 		return
 	}
 	endDate, err := time.Parse(dateFormat, eDate)
 	if err != nil {
-		log.Println("Invalid end date format")
+		logger(r).Warn("Invalid end date format.")
 		http.Error(w, "Invalid Request: invalid date format", http.StatusBadRequest)
 		return
 	}
+	chargeFrom := chargeStart(startDate)
+	if endDate.Before(chargeFrom) {
+		logger(r).Warn("End date before the start date or today.")
+		http.Error(w, "Invalid Request: the end date is before the start date or today", http.StatusBadRequest)
+		return
+	}
 
 	if err := uuid.Validate(deviceId); err != nil {
-		log.Println("Invalid device UUID")
+		logger(r).Warn("Invalid device UUID.")
 		http.Error(w, "Invalid Request: invalid device uuid", http.StatusBadRequest)
 		return
 	}
 
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		log.Println("Failed to parse IP from RemoteAddr")
+	addr := clientip.FromRequest(r)
+	if !addr.IsValid() {
+		logger(r).Error("Failed to find the IP of the client.")
 		http.Error(w, "Invalid request: unable to determine IP address", http.StatusBadRequest)
 		return
 	}
+	ip := addr.String()
 
 	newClient := utils.Client{
 		Id:         serverId,
@@ -102,69 +108,94 @@ func accountCreateHTMX(w http.ResponseWriter, r *http.Request) {
 		Password:   password,
 	}
 
-	log.Printf("Creating account of type: %s", parsedAccType)
-
-	switch parsedAccType {
-	case utils.SstpAccountType:
-		d := r.FormValue("desc")
-		if strings.Contains(username, "/") {
-			log.Println("Invalid character '/' in SSTP username")
-			http.Error(w, "Invalid username: please don't use '/' character inside sstp usernames.", http.StatusBadRequest)
-			return
-		}
-		log.Println("Creating SSTP user...")
-		resp, err := utils.CreateSSTPUser(newClient.Username, d, password, endDate)
-		if err != nil {
-			log.Printf("Failed to create SSTP user: %v", err)
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		log.Printf("SSTP creation response: %s", resp)
+	if parsedAccType == utils.SstpAccountType && strings.Contains(username, "/") {
+		logger(r).Warn("Invalid character '/' in SSTP username.")
+		http.Error(w, "Invalid username: please don't use '/' character inside sstp usernames.", http.StatusBadRequest)
+		return
+	}
 
-	case utils.ShadowsocksAccountType:
-		log.Println("Creating Shadowsocks user...")
-		c, u := utils.ShadowsocksAccountType.Filename()
-		if err := utils.CreateShadowsocksUser(newClient, c, u); err != nil {
-			log.Printf("Failed to create Shadowsocks user: %v", err)
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		log.Println("Restarting service after Shadowsocks user creation")
-		if err := utils.RestartService(); err != nil {
-			log.Printf("Failed to restart service: %v", err)
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	key := clientKey(parsedAccType, newClient)
+	if parsedAccType == utils.SstpAccountType {
+		key = accountKey(parsedAccType, newClient.Username)
+	}
 
-	case utils.VmessAccountType:
-		log.Println("Creating Vmess user...")
-		c, u := utils.VmessAccountType.Filename()
-		if err := utils.CreateVmessUser(newClient, c, u); err != nil {
-			log.Printf("Failed to create Vmess user: %v", err)
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		log.Println("Restarting service after Vmess user creation")
-		if err := utils.RestartService(); err != nil {
-			log.Printf("Failed to restart service: %v", err)
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if !h.checkLimit(w, r) {
+		return
 	}
 
-	log.Println("Sending Gotify notifications")
-	title := *config.WebHost + " - New user is created"
-	message := newClient.Username + "@" + *config.WebHostIP + " with [[" + newClient.Id + "]] is created by " + ip
-	for _, key := range config.GotifyAPIKeys {
-		utils.SendNoti(*config.GotifyServer, key, title, message, 5)
+	err = h.createAccount(r, parsedAccType, newClient, key, r.FormValue("desc"), days(chargeFrom, endDate))
+	if err != nil {
+		chargeFailed(w, err, http.StatusInternalServerError)
+		return
 	}
 
-	log.Println("Rendering success toast and refreshed account form")
-	components.NotiToast("Account Created Successfully.").Render(context.Background(), w)
+	logger(r).Debug("Sending Gotify notifications.")
+	title := cfg.WebHost + " - New user is created"
+	message := newClient.Username + "@" + cfg.WebHostIP + " with [[" + newClient.Id + "]] is created by " + auth.AdminFromContext(r.Context()) + " (" + ip + ")"
+	h.Notifier.Notify(title, message, 5)
+
+	logger(r).Debug("Rendering success toast and refreshed account form.")
+	components.NotiToast("Account Created Successfully.").Render(r.Context(), w)
 	components.AccountCreateForm(
-		csrf.Generate(w, "/accounts", session.GetSessionMgr().Token(r.Context())),
+		h.CSRF.Generate(w, "/accounts", h.Sessions.Token(r.Context())),
 		templ.Attributes{"hx-swap-oob": "true"},
-	).Render(context.Background(), w)
+	).Render(r.Context(), w)
+}
+
+// createAccount creates the account c of the type t with the key, charging the days of it to the
+// resellers, and makes the logged in admin its owner. The sstp accounts expire at the end date of c.
+func (h *Handler) createAccount(r *http.Request, t utils.AccountType, c utils.Client, key, desc string, days int) error {
+	logger(r).Debug("Creating account.", "type", t)
+	end, err := time.Parse(dateFormat, c.ExpireDate)
+	if err != nil {
+		return err
+	}
+
+	err = h.charge(r, t, days, key, func() error {
+		switch t {
+		case utils.SstpAccountType:
+			logger(r).Debug("Creating SSTP user...")
+			resp, err := utils.CreateSSTPUser(c.Username, desc, c.Password, end)
+			if err != nil {
+				logger(r).Error("Failed to create SSTP user.", "err", err)
+				return err
+			}
+			logger(r).Debug("SSTP user is created.", "id", resp.ID, "username", c.Username)
+
+		case utils.ShadowsocksAccountType:
+			logger(r).Debug("Creating Shadowsocks user...")
+			cFile, uFile := utils.ShadowsocksAccountType.Filename()
+			if err := utils.CreateShadowsocksUser(c, cFile, uFile); err != nil {
+				logger(r).Error("Failed to create Shadowsocks user.", "err", err)
+				return err
+			}
+			logger(r).Debug("Restarting service after Shadowsocks user creation.")
+			if err := utils.RestartService(); err != nil {
+				logger(r).Error("Failed to restart service.", "err", err)
+				return err
+			}
+
+		case utils.VmessAccountType:
+			logger(r).Debug("Creating Vmess user...")
+			cFile, uFile := utils.VmessAccountType.Filename()
+			if err := utils.CreateVmessUser(c, cFile, uFile); err != nil {
+				logger(r).Error("Failed to create Vmess user.", "err", err)
+				return err
+			}
+			logger(r).Debug("Restarting service after Vmess user creation.")
+			if err := utils.RestartService(); err != nil {
+				logger(r).Error("Failed to restart service.", "err", err)
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.setOwner(r, key)
+	h.auditAccount(r, database.AuditAccountCreated, key, nil, &c)
+	return nil
 }
 
 // accountCreateHTMX creates an account on the v2ray server and restart the v2ray service.
@@ -281,25 +312,25 @@ func accountCreateHTMX(w http.ResponseWriter, r *http.Request) {
 // 		}
 // 	}
 //
-// 	title := *config.WebHost + " - New user is created"
-// 	message := newClient.Username + "@" + *config.WebHostIP + " with [[" + newClient.Id + "]] is created by " + ip
-// 	for _, key := range config.GotifyAPIKeys {
-// 		utils.SendNoti(*config.GotifyServer, key, title, message, 5)
+// 	title := config.Get().WebHost + " - New user is created"
+// 	message := newClient.Username + "@" + config.Get().WebHostIP + " with [[" + newClient.Id + "]] is created by " + ip
+// 	for _, key := range config.Get().GotifyAPIKeys {
+// 		utils.SendNoti(config.Get().GotifyServer, key, title, message, 5)
 // 	}
 //
-// 	components.NotiToast("Account Created Successfully.").Render(context.Background(), w)
+// 	components.NotiToast("Account Created Successfully.").Render(r.Context(), w)
 // 	components.AccountCreateForm(
 // 		csrf.Generate(
 // 			w,
 // 			"/accounts",
 // 			session.GetSessionMgr().Token(r.Context())),
 // 		templ.Attributes{"hx-swap-oob": "true"},
-// 	).Render(context.Background(), w)
+// 	).Render(r.Context(), w)
 // 	return
 // }
 
 // accountQRGETHTMX gets qr data of specific account to show inside the modal.
-func accountQRGETHTMX(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) accountQRGETHTMX(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	t := r.FormValue("type")
 
@@ -320,6 +351,10 @@ func accountQRGETHTMX(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.checkAccess(w, r, accountKey(accType, idParam)) {
+		return
+	}
+
 	users, err := GetAllUsers(accType)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -352,7 +387,7 @@ func accountQRGETHTMX(w http.ResponseWriter, r *http.Request) {
 			"id":          "lockedQRTab",
 			"hx-swap-oob": "true",
 		},
-	}).Render(context.Background(), w)
+	}).Render(r.Context(), w)
 	layout.QRTab(layout.QRData{
 		Key:      k,
 		Username: user.Username,
@@ -361,12 +396,12 @@ func accountQRGETHTMX(w http.ResponseWriter, r *http.Request) {
 			"id":          "openedQRTab",
 			"hx-swap-oob": "true",
 		},
-	}).Render(context.Background(), w)
+	}).Render(r.Context(), w)
 	return
 }
 
 // accountQRGETHTMX gets text data of specific account to show inside the modal.
-func accountTextGETHTMX(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) accountTextGETHTMX(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	t := r.FormValue("type")
 
@@ -387,6 +422,10 @@ func accountTextGETHTMX(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.checkAccess(w, r, accountKey(accType, idParam)) {
+		return
+	}
+
 	users, err := GetAllUsers(accType)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -417,28 +456,30 @@ func accountTextGETHTMX(w http.ResponseWriter, r *http.Request) {
 			"id":          "lockedTextKeyTab",
 			"hx-swap-oob": "true",
 		},
-	}).Render(context.Background(), w)
+	}).Render(r.Context(), w)
 	layout.TextKeyTab(layout.TextKeyData{
 		Key: k,
 		Attributes: templ.Attributes{
 			"id":          "openedTextKeyTab",
 			"hx-swap-oob": "true",
 		},
-	}).Render(context.Background(), w)
+	}).Render(r.Context(), w)
 	return
 }
 
 // accountDeleteHTMX deletes the account using the given server and device ids and restart the v2ray service.
-func accountDeleteHTMX(w http.ResponseWriter, r *http.Request) {
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
+func (h *Handler) accountDeleteHTMX(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+	addr := clientip.FromRequest(r)
+	if !addr.IsValid() {
 		http.Error(w, "Invalid request: unable to determine IP address", http.StatusBadRequest)
 		return
 	}
+	ip := addr.String()
 	serverId, deviceId, accType, username := r.FormValue("serverId"), r.FormValue("deviceId"), r.FormValue("type"), r.FormValue("username")
 
 	if deviceId == "" || accType == "" {
-		log.Println("this is the first 403")
+		logger(r).Debug("this is the first 403.")
 		http.Error(w, "Invalid Request: missing required fields.", http.StatusForbidden)
 		return
 	}
@@ -456,79 +497,103 @@ func accountDeleteHTMX(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = uuid.Validate(deviceId)
+	err := uuid.Validate(deviceId)
 	if err != nil {
-		log.Println("this is the device id error.")
+		logger(r).Debug("this is the device id error.")
 		http.Error(w, "Invalid Request: invalid UUID format.", http.StatusBadRequest)
 		return
 	}
 
 	parsedAccType, err := utils.ParseAccountType(accType)
 	if err != nil {
-		log.Println("this is the acc type error.")
+		logger(r).Debug("this is the acc type error.")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	key := accountKey(parsedAccType, serverId)
+	if parsedAccType == utils.SstpAccountType {
+		key = accountKey(parsedAccType, username)
+	}
+	if !h.checkAccess(w, r, key) {
+		return
+	}
+
+	deletedUser, status, err := h.deleteAccount(r, parsedAccType, key, serverId, deviceId, username)
+	if err != nil {
+		logger(r).Error("deleting the account gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error: "+err.Error(), status)
+		return
+	}
+
+	logger(r).Debug("is this the error.")
+	admin := auth.AdminFromContext(r.Context())
+	title := cfg.WebHost + " - Existing user is deleted."
+	var message string
+	if parsedAccType != utils.SstpAccountType {
+		message = deletedUser.Username + "@" + cfg.WebHostIP + " with [[" + deletedUser.Id + "]] is deleted by " + admin + " (" + ip + ")"
+	} else {
+		message = username + "@" + cfg.WebHostIP + " SSTP server is deleted by " + admin + " (" + ip + ")"
+	}
+	h.Notifier.Notify(title, message, 5)
+
+	// NOTE: status 200 with empty response for successful deletion,
+	// other status for failure to delete account.
+	w.WriteHeader(http.StatusOK)
+	utils.RestartService()
+	return
+}
+
+// deleteAccount deletes the account of the type t with the key and its owner, returning the deleted
+// v2ray account. The v2ray accounts are known by the serverId and the deviceId, the sstp ones by the username.
+func (h *Handler) deleteAccount(r *http.Request, t utils.AccountType, key, serverId, deviceId, username string) (*utils.Client, int, error) {
 	var status int
 	var deletedUser *utils.Client
-	switch parsedAccType {
+	var err error
+	switch t {
 	case utils.SstpAccountType:
 		_, err = utils.DeleteSSTPUser(username)
 		if err != nil {
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, http.StatusInternalServerError, err
 		}
 
 	case utils.ShadowsocksAccountType:
 		c, u := utils.ShadowsocksAccountType.Filename()
 		deletedUser, status, err = utils.DeleteShadowsocksUser(serverId, deviceId, c, u)
 		if err != nil {
-			log.Println("Internal server error: ", err.Error())
-			http.Error(w, "Internal Server Error: "+err.Error(), status)
-			return
+			return nil, status, err
 		}
 		err = utils.RestartService()
 		if err != nil {
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, http.StatusInternalServerError, err
 		}
 
 	case utils.VmessAccountType:
 		c, u := utils.VmessAccountType.Filename()
 		deletedUser, status, err = utils.DeleteVmessUser(serverId, deviceId, c, u)
 		if err != nil {
-			log.Println("Internal server error: ", err.Error())
-			http.Error(w, "Internal Server Error: "+err.Error(), status)
-			return
+			return nil, status, err
 		}
 		err = utils.RestartService()
 		if err != nil {
-			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, http.StatusInternalServerError, err
 		}
 	}
 
-	log.Println("is this the error.")
-	title := *config.WebHost + " - Existing user is deleted."
-	var message string
-	if parsedAccType != utils.SstpAccountType {
-		message = deletedUser.Username + "@" + *config.WebHostIP + " with [[" + deletedUser.Id + "]] is deleted by " + ip
-	} else {
-		message = username + "@" + *config.WebHostIP + " SSTP server is deleted by " + ip
+	err = h.Owners.Remove(key)
+	if err != nil {
+		logger(r).Error("removing the account owner gone wrong.", "err", err)
 	}
-	for _, key := range config.GotifyAPIKeys {
-		utils.SendNoti(*config.GotifyServer, key, title, message, 5)
+	if deletedUser != nil {
+		h.auditAccount(r, database.AuditAccountDeleted, key, deletedUser, nil)
+	} else {
+		h.auditAccount(r, database.AuditAccountDeleted, key, &utils.Client{Username: username}, nil)
 	}
-
-	// NOTE: status 200 with empty response for successful deletion,
-	// other status for failure to delete account.
-	w.WriteHeader(http.StatusOK)
-	utils.RestartService()
-	return
+	return deletedUser, http.StatusOK, nil
 }
 
-func accountEditHTMX(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) accountEditHTMX(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
 	username, accType, deviceId, sDate, eDate := r.FormValue("username"), r.FormValue("type"), r.FormValue("deviceId"), r.FormValue("startDate"), r.FormValue("endDate")
 	password, serverId := r.FormValue("password"), r.FormValue("serverId")
 
@@ -557,8 +622,17 @@ func accountEditHTMX(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if parsedAccType == utils.SstpAccountType {
-		log.Println("Invoked unimplemented feature.")
+		logger(r).Warn("Invoked unimplemented feature.")
 		http.Error(w, "Account Edit Unavailable For SSTP Accounts", http.StatusNotImplemented)
+		return
+	}
+
+	key := accountKey(parsedAccType, serverId)
+	if parsedAccType == utils.ShadowsocksAccountType {
+		key = accountKey(parsedAccType, password)
+	}
+	if !h.checkAccess(w, r, key) {
+		return
 	}
 
 	// just a nice touch.
@@ -585,11 +659,12 @@ func accountEditHTMX(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// doing things before writing to the file.
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
+	addr := clientip.FromRequest(r)
+	if !addr.IsValid() {
 		http.Error(w, "Invalid request: unable to determine IP address", http.StatusBadRequest)
 		return
 	}
+	ip := addr.String()
 
 	// modify the users by adding a modified user entity to the users file.
 	modifiedClient := utils.Client{
@@ -602,44 +677,62 @@ func accountEditHTMX(w http.ResponseWriter, r *http.Request) {
 		Password:   password,
 	}
 
-	var oldClient *utils.Client
-	var status int
-	switch parsedAccType {
-	case utils.VmessAccountType:
-		cFile, uFile := parsedAccType.Filename()
-		oldClient, status, err = utils.EditVmessUser(modifiedClient, cFile, uFile)
-		if err != nil {
-			http.Error(w, "Internal Server Error: "+err.Error(), status)
-		}
+	oldClient, status, err := h.editAccount(r, parsedAccType, key, modifiedClient)
+	if err != nil {
+		chargeFailed(w, err, status)
+		return
+	}
 
+	if parsedAccType == utils.VmessAccountType {
 		components.VmessAccount(
 			modifiedClient,
 			templ.Attributes{"hx-swap-oob": "true", "newly-swapped": "true"},
-		).Render(context.Background(), w)
-
-	case utils.ShadowsocksAccountType:
-		cFile, uFile := parsedAccType.Filename()
-		oldClient, status, err = utils.EditShadowsocksUser(modifiedClient, cFile, uFile)
-		if err != nil {
-			http.Error(w, "Internal Server Error: "+err.Error(), status)
-		}
-
+		).Render(r.Context(), w)
+	} else {
 		components.ShadowsocksAccount(
 			modifiedClient,
 			templ.Attributes{"hx-swap-oob": "true", "newly-swapped": "true"},
-		).Render(context.Background(), w)
+		).Render(r.Context(), w)
 	}
 
-	title := *config.WebHost + " - User is updated"
-	message := oldClient.Username + "@" + *config.WebHostIP + " with \nid: [[" + oldClient.Id + "]]\ndevice id: [[" + oldClient.DeviceId + "]]\n is updated by (" + ip + ") to " + modifiedClient.Username + "\ndevice id: [[" + modifiedClient.DeviceId + "]]"
-	for _, key := range config.GotifyAPIKeys {
-		utils.SendNoti(*config.GotifyServer, key, title, message, 5)
-	}
-	components.NotiToast("User information updated.").Render(context.Background(), w)
+	title := cfg.WebHost + " - User is updated"
+	message := oldClient.Username + "@" + cfg.WebHostIP + " with \nid: [[" + oldClient.Id + "]]\ndevice id: [[" + oldClient.DeviceId + "]]\n is updated by (" + ip + ") to " + modifiedClient.Username + "\ndevice id: [[" + modifiedClient.DeviceId + "]]"
+	h.Notifier.Notify(title, message, 5)
+	components.NotiToast("User information updated.").Render(r.Context(), w)
 	return
 }
 
-func accountEditGetHTMX(w http.ResponseWriter, r *http.Request) {
+// editAccount changes the v2ray account of the type t with the key to the modified, charging the
+// extended days of it to the resellers, and returns the account before the change.
+func (h *Handler) editAccount(r *http.Request, t utils.AccountType, key string, modified utils.Client) (*utils.Client, int, error) {
+	endDate, err := time.Parse(dateFormat, modified.ExpireDate)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	extended, err := extendedDays(t, key, endDate)
+	if err != nil {
+		logger(r).Error("getting the account to edit gone wrong.", "err", err)
+		return nil, http.StatusInternalServerError, err
+	}
+
+	var oldClient *utils.Client
+	status := http.StatusInternalServerError
+	err = h.charge(r, t, extended, key, func() (err error) {
+		cFile, uFile := t.Filename()
+		if t == utils.VmessAccountType {
+			oldClient, status, err = utils.EditVmessUser(modified, cFile, uFile)
+		} else {
+			oldClient, status, err = utils.EditShadowsocksUser(modified, cFile, uFile)
+		}
+		return err
+	})
+	if err == nil {
+		h.auditAccount(r, database.AuditAccountEdited, key, oldClient, &modified)
+	}
+	return oldClient, status, err
+}
+
+func (h *Handler) accountEditGetHTMX(w http.ResponseWriter, r *http.Request) {
 	id, password := r.FormValue("serverId"), r.FormValue("password")
 	t := r.FormValue("type")
 
@@ -662,7 +755,7 @@ func accountEditGetHTMX(w http.ResponseWriter, r *http.Request) {
 
 	// note: seperate this code block util the feature is implemented.
 	if accType == utils.SstpAccountType {
-		log.Println("SSTP is not yet implemented yet being called.")
+		logger(r).Warn("SSTP is not yet implemented yet being called.")
 		http.Error(w, "Not implemented", http.StatusNotImplemented)
 		return
 	}
@@ -677,7 +770,7 @@ func accountEditGetHTMX(w http.ResponseWriter, r *http.Request) {
 	// that can lead to perfomance overhead if there's more things to compare.
 	found, user := false, utils.Client{}
 	if accType == utils.VmessAccountType {
-		log.Println("acc type is vmess")
+		logger(r).Debug("acc type is vmess.")
 		err := uuid.Validate(id)
 		if err != nil {
 			http.Error(w, "Invalid Request: invalid UUID format.", http.StatusBadRequest)
@@ -690,7 +783,7 @@ func accountEditGetHTMX(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	} else if accType == utils.ShadowsocksAccountType {
-		log.Println("acc type is shadowsocks")
+		logger(r).Debug("acc type is shadowsocks.")
 		err := uuid.Validate(password)
 		if err != nil {
 			http.Error(w, "Invalid Request: invalid UUID format.", http.StatusBadRequest)
@@ -705,11 +798,15 @@ func accountEditGetHTMX(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !found {
-		log.Println("Invalid user is being searched.")
+		logger(r).Warn("Invalid user is being searched.")
 		http.Error(w, "Invalid Request", http.StatusBadRequest)
 		return
 	}
 
+	if !h.checkAccess(w, r, clientKey(accType, user)) {
+		return
+	}
+
 	startDate, err := time.Parse(dateFormat, user.StartDate)
 	if err != nil {
 		http.Error(w, "Internal Server Error: invalid date format", http.StatusInternalServerError)
@@ -732,7 +829,7 @@ func accountEditGetHTMX(w http.ResponseWriter, r *http.Request) {
 		Password:   password,
 		Type:       accType,
 	},
-		csrf.Generate(w, "/accounts", session.GetSessionMgr().Token(r.Context())),
-	).Render(context.Background(), w) // BUG: gives out the csrf token.
+		h.CSRF.Generate(w, "/accounts", h.Sessions.Token(r.Context())),
+	).Render(r.Context(), w) // BUG: gives out the csrf token.
 	return
 }
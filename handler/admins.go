@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// roleOf returns the role of the admin for the auth.RoleMiddleware, the removed admins are allowed nothing.
+func (h *Handler) roleOf(username string) (auth.Role, error) {
+	admin, err := h.Admins.Get(username)
+	if errors.Is(err, database.ErrAdminNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return auth.SavedRole(admin.Role), nil
+}
+
+// adminsGETHTMX shows the admins with their roles.
+func (h *Handler) adminsGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	admins, err := h.Admins.All()
+	if err != nil {
+		logger(r).Error("listing the admins gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	t := h.CSRF.Generate(w, "/admins", h.Sessions.Token(r.Context()))
+	layout.AdminsDashboard(admins, auth.AdminFromContext(r.Context()), t).Render(r.Context(), w)
+}
+
+// adminRolePOSTHTMX changes the role of the admin, the last owner is kept.
+func (h *Handler) adminRolePOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	username := r.FormValue("username")
+	role, err := auth.ParseRole(r.FormValue("role"))
+	if err != nil || username == "" {
+		http.Error(w, "Invalid Request: invalid admin or role.", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Admins.SetRole(username, string(role))
+	if err == database.ErrLastOwner {
+		components.ErrorToast(err.Error()).Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		logger(r).Error("changing the role gone wrong.", "err", err)
+		components.ErrorToast("Changing the role of "+username+" failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	admin := auth.AdminFromContext(r.Context())
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditRoleChanged, Target: username, Detail: string(role)})
+	logger(r).Info("Role is changed.", "username", username, "role", role)
+
+	components.NotiToast(username+" is now "+string(role)+".").Render(r.Context(), w)
+}
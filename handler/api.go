@@ -0,0 +1,752 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	json "github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+)
+
+// apiBodyLimit is the largest request body of the api.
+const apiBodyLimit = 1 << 20
+
+// started is when the panel is started, for the uptime of the server status.
+var started = time.Now()
+
+var errAccountNotFound = errors.New("Account not found.")
+
+// apiError is the error object of every failed api response, wrapped in {"error": ...}.
+type apiError struct {
+	Status int `json:"status"`
+	// Code is the snake case of the http status text, eg. not_found.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiAccount is an account of any type in the api responses.
+type apiAccount struct {
+	Type string `json:"type"`
+	// ID is the server id of the vmess, the password of the shadowsocks and the username of the sstp accounts.
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	DeviceID   string `json:"device_id,omitempty"`
+	StartDate  string `json:"start_date,omitempty"`
+	ExpireDate string `json:"expire_date"`
+	Port       int    `json:"port,omitempty"`
+	Suspended  bool   `json:"suspended"`
+	// Password is only given once, when the sstp account is created.
+	Password string `json:"password,omitempty"`
+}
+
+// apiAccountRequest is the body creating or editing the account, the empty fields of the edits are kept.
+type apiAccountRequest struct {
+	Type        string `json:"type"`
+	Username    string `json:"username"`
+	DeviceID    string `json:"device_id"`
+	ServerID    string `json:"server_id"`
+	Password    string `json:"password"`
+	Description string `json:"description"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+}
+
+// apiExtendRequest is the body extending the account.
+type apiExtendRequest struct {
+	Days int `json:"days"`
+}
+
+// apiURIs are the keys of the v2ray account for the apps.
+type apiURIs struct {
+	URI           string `json:"uri"`
+	Remarks       string `json:"remarks"`
+	LockedURI     string `json:"locked_uri"`
+	LockedRemarks string `json:"locked_remarks"`
+}
+
+// apiServerStatus is the status of the server and the panel.
+type apiServerStatus struct {
+	// Services are the systemd states of the vpn services and the panel.
+	Services      map[string]string `json:"services"`
+	UptimeSeconds int64             `json:"uptime_seconds"` // of the panel.
+	MemoryBytes   uint64            `json:"memory_bytes"`   // allocated by the panel.
+	// Accounts are the counts of the accounts of each type, the sstp ones are left out if its server can't be reached.
+	Accounts map[string]int `json:"accounts"`
+}
+
+// apiRoutes registers the json api of the version 1 on the r. The api is used with the api
+// tokens or the session of the logged in admin, and answers every error with an apiError.
+// The changes of the routes must be made to the openapi.json too.
+func (h *Handler) apiRoutes(r chi.Router) {
+	r.Get("/openapi.json", openAPIGET)
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.trustedOnly(config.TrustedPrivate, apiUntrusted))
+		r.Use(h.apiAuth)
+		r.Use(auth.RoleMiddleware(h.Sessions, h.roleOf))
+		r.Use(logAdmin)
+		r.Use(h.apiRequireTOTP)
+
+		r.With(apiRequire(auth.ViewAccounts)).Get("/accounts", h.apiAccountsGET)
+		r.With(apiRequire(auth.CreateAccounts)).Post("/accounts", h.apiAccountCreatePOST)
+		r.With(apiRequire(auth.ViewAccounts)).Get("/accounts/{type}/{id}", h.apiAccountGET)
+		r.With(apiRequire(auth.EditAccounts)).Patch("/accounts/{type}/{id}", h.apiAccountEditPATCH)
+		r.With(apiRequire(auth.DeleteAccounts)).Delete("/accounts/{type}/{id}", h.apiAccountDELETE)
+		r.With(apiRequire(auth.EditAccounts)).Post("/accounts/{type}/{id}/extend", h.apiAccountExtendPOST)
+		r.With(apiRequire(auth.EditAccounts)).Post("/accounts/{type}/{id}/suspend", h.apiAccountSuspendPOST)
+		r.With(apiRequire(auth.EditAccounts)).Post("/accounts/{type}/{id}/resume", h.apiAccountResumePOST)
+		r.With(apiRequire(auth.ViewAccounts)).Get("/accounts/{type}/{id}/uris", h.apiAccountURIsGET)
+
+		r.With(h.trustedOnly(config.TrustedServer, apiUntrusted), apiRequire(auth.ViewServer)).Get("/server/status", h.apiServerStatusGET)
+	})
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apiFail(w, http.StatusNotFound, "There's no such api endpoint.")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		apiFail(w, http.StatusMethodNotAllowed, "The method is not allowed for the endpoint.")
+	})
+}
+
+// apiRespond writes the v as the json response with the status.
+func apiRespond(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Error("writing the json response gone wrong.", "err", err)
+	}
+}
+
+// apiFail writes the apiError of the status with the message.
+func apiFail(w http.ResponseWriter, status int, message string) {
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	apiRespond(w, status, map[string]apiError{"error": {Status: status, Code: code, Message: message}})
+}
+
+// apiFailed writes the apiError of the failed change of an account, with the status for the changes that failed.
+func apiFailed(w http.ResponseWriter, err error, status int) {
+	if errors.Is(err, database.ErrNoCredits) {
+		apiFail(w, http.StatusPaymentRequired, "Not enough credits, ask the owner to top up.")
+		return
+	}
+	if status == 0 || status == http.StatusOK {
+		status = http.StatusInternalServerError
+	}
+	apiFail(w, status, err.Error())
+}
+
+// apiCheckLimit is the checkLimit of the api, refusing with an apiError.
+func (h *Handler) apiCheckLimit(w http.ResponseWriter, r *http.Request) bool {
+	limit, err := h.limitReached(r)
+	if err != nil {
+		logger(r).Error("counting the active accounts gone wrong.", "err", err)
+		apiFail(w, http.StatusInternalServerError, "Internal Server Error")
+		return false
+	}
+	if limit != 0 {
+		apiFail(w, http.StatusForbidden, "The limit of "+strconv.Itoa(limit)+" active accounts is reached.")
+		return false
+	}
+	return true
+}
+
+// apiAuth refuses the requests that are neither authenticated with an api token nor logged in.
+func (h *Handler) apiAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.FromToken(r.Context()) && !h.Sessions.GetBool(r.Context(), utils.AuthenticatedField) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="lothone"`)
+			apiFail(w, http.StatusUnauthorized, "An api token is needed.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiRequire is the auth.Require of the api, refusing with an apiError.
+func apiRequire(p auth.Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.Can(r.Context(), p) {
+				logger(r).Warn("Forbidden api request.", "method", r.Method, "path", r.URL.Path)
+				apiFail(w, http.StatusForbidden, "The role or the scopes of the token are not allowed to do this.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decodeJSON reads the json body of the r into the v, writing the apiError if it's invalid.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	d := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiBodyLimit))
+	d.DisallowUnknownFields()
+	err := d.Decode(v)
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid json body, "+err.Error())
+		return false
+	}
+	return true
+}
+
+// clientAccount returns the apiAccount of the v2ray account.
+func clientAccount(t utils.AccountType, c utils.Client, suspended bool) apiAccount {
+	id := c.Id
+	if t == utils.ShadowsocksAccountType {
+		id = c.Password
+	}
+	return apiAccount{
+		Type:       t.String(),
+		ID:         id,
+		Username:   c.Username,
+		DeviceID:   c.DeviceId,
+		StartDate:  c.StartDate,
+		ExpireDate: c.ExpireDate,
+		Port:       c.Port,
+		Suspended:  suspended,
+	}
+}
+
+// sstpAccount returns the apiAccount of the sstp account.
+func sstpAccount(u utils.UserInfo) apiAccount {
+	return apiAccount{Type: utils.SstpAccountType.String(), ID: u.Name, Username: u.Name, ExpireDate: u.Expires}
+}
+
+// listAccounts returns the accounts of the type, with the suspended ones, that the logged in admin can access.
+func (h *Handler) listAccounts(r *http.Request, t utils.AccountType) ([]apiAccount, error) {
+	if t == utils.SstpAccountType {
+		users, err := utils.GetSSTPUsers()
+		if err != nil {
+			return nil, err
+		}
+		users, err = ownedOnly(h, r, users, func(u utils.UserInfo) string { return accountKey(t, u.Name) })
+		if err != nil {
+			return nil, err
+		}
+		accounts := make([]apiAccount, 0, len(users))
+		for _, u := range users {
+			accounts = append(accounts, sstpAccount(u))
+		}
+		return accounts, nil
+	}
+
+	users, err := GetAllUsers(t)
+	if err != nil {
+		return nil, err
+	}
+	users, err = h.ownedClients(r, t, users)
+	if err != nil {
+		return nil, err
+	}
+	suspended, err := h.Suspended.List(t.String())
+	if err != nil {
+		return nil, err
+	}
+	suspended, err = ownedOnly(h, r, suspended, func(a database.SuspendedAccount) string { return a.Key })
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]apiAccount, 0, len(users)+len(suspended))
+	for _, u := range users {
+		accounts = append(accounts, clientAccount(t, u, false))
+	}
+	for _, a := range suspended {
+		accounts = append(accounts, clientAccount(t, a.Client, true))
+	}
+	return accounts, nil
+}
+
+// findAccount returns the account of the type with the id, and the v2ray account of it. The
+// suspended accounts are found too.
+func (h *Handler) findAccount(t utils.AccountType, id string) (apiAccount, utils.Client, error) {
+	if t == utils.SstpAccountType {
+		users, err := utils.GetSSTPUsers()
+		if err != nil {
+			return apiAccount{}, utils.Client{}, err
+		}
+		for _, u := range users {
+			if u.Name == id {
+				return sstpAccount(u), utils.Client{}, nil
+			}
+		}
+		return apiAccount{}, utils.Client{}, errAccountNotFound
+	}
+
+	users, err := GetAllUsers(t)
+	if err != nil {
+		return apiAccount{}, utils.Client{}, err
+	}
+	for _, u := range users {
+		if clientKey(t, u) == accountKey(t, id) {
+			return clientAccount(t, u, false), u, nil
+		}
+	}
+
+	a, err := h.Suspended.Get(accountKey(t, id))
+	if errors.Is(err, database.ErrNotSuspended) {
+		return apiAccount{}, utils.Client{}, errAccountNotFound
+	}
+	if err != nil {
+		return apiAccount{}, utils.Client{}, err
+	}
+	return clientAccount(t, a.Client, true), a.Client, nil
+}
+
+// apiAccount returns the account of the url that the logged in admin can access, writing the
+// apiError if there's none. The accounts of the others are answered as not found.
+func (h *Handler) apiAccount(w http.ResponseWriter, r *http.Request) (utils.AccountType, apiAccount, utils.Client, bool) {
+	t, err := utils.ParseAccountType(chi.URLParam(r, "type"))
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid account type.")
+		return t, apiAccount{}, utils.Client{}, false
+	}
+	id := chi.URLParam(r, "id")
+	if t != utils.SstpAccountType && uuid.Validate(id) != nil {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid UUID format.")
+		return t, apiAccount{}, utils.Client{}, false
+	}
+
+	ok, err := h.canAccess(r, accountKey(t, id))
+	if err != nil {
+		logger(r).Error("getting the account owner gone wrong.", "err", err)
+		apiFail(w, http.StatusInternalServerError, "Internal Server Error")
+		return t, apiAccount{}, utils.Client{}, false
+	}
+	if !ok {
+		logger(r).Warn("Access to the account of another admin.", "account", accountKey(t, id))
+		apiFail(w, http.StatusNotFound, errAccountNotFound.Error())
+		return t, apiAccount{}, utils.Client{}, false
+	}
+
+	account, c, err := h.findAccount(t, id)
+	if errors.Is(err, errAccountNotFound) {
+		apiFail(w, http.StatusNotFound, err.Error())
+		return t, account, c, false
+	}
+	if err != nil {
+		logger(r).Error("getting the account gone wrong.", "err", err)
+		apiFail(w, http.StatusInternalServerError, "Internal Server Error: "+err.Error())
+		return t, account, c, false
+	}
+	return t, account, c, true
+}
+
+// apiAccountsGET lists the accounts of every type, or of the type of the query.
+func (h *Handler) apiAccountsGET(w http.ResponseWriter, r *http.Request) {
+	types := []utils.AccountType{utils.VmessAccountType, utils.ShadowsocksAccountType, utils.SstpAccountType}
+	if r.URL.Query().Has("type") {
+		t, err := utils.ParseAccountType(r.URL.Query().Get("type"))
+		if err != nil {
+			apiFail(w, http.StatusBadRequest, "Invalid Request: invalid account type.")
+			return
+		}
+		types = []utils.AccountType{t}
+	}
+
+	accounts := []apiAccount{}
+	for _, t := range types {
+		list, err := h.listAccounts(r, t)
+		if err != nil {
+			logger(r).Error("listing the accounts gone wrong.", "type", t, "err", err)
+			apiFail(w, http.StatusInternalServerError, "Internal Server Error: listing the "+t.String()+" accounts failed.")
+			return
+		}
+		accounts = append(accounts, list...)
+	}
+	apiRespond(w, http.StatusOK, map[string][]apiAccount{"accounts": accounts})
+}
+
+// apiAccountGET gives the account.
+func (h *Handler) apiAccountGET(w http.ResponseWriter, r *http.Request) {
+	_, account, _, ok := h.apiAccount(w, r)
+	if !ok {
+		return
+	}
+	apiRespond(w, http.StatusOK, account)
+}
+
+// apiAccountCreatePOST creates the account of the body, the missing server id and password are made up.
+func (h *Handler) apiAccountCreatePOST(w http.ResponseWriter, r *http.Request) {
+	var req apiAccountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	t, err := utils.ParseAccountType(req.Type)
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid account type.")
+		return
+	}
+	if req.Username == "" || req.EndDate == "" || (t != utils.SstpAccountType && req.DeviceID == "") {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: missing required fields.")
+		return
+	}
+	if t == utils.SstpAccountType && strings.Contains(req.Username, "/") {
+		apiFail(w, http.StatusBadRequest, "Invalid username: please don't use '/' character inside sstp usernames.")
+		return
+	}
+	if t != utils.SstpAccountType && uuid.Validate(req.DeviceID) != nil {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid device uuid")
+		return
+	}
+	if (req.ServerID != "" && uuid.Validate(req.ServerID) != nil) || (req.Password != "" && uuid.Validate(req.Password) != nil) {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid UUID format.")
+		return
+	}
+	if req.StartDate == "" {
+		req.StartDate = time.Now().Format(dateFormat)
+	}
+	startDate, err := time.Parse(dateFormat, req.StartDate)
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid date format")
+		return
+	}
+	endDate, err := time.Parse(dateFormat, req.EndDate)
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: invalid date format")
+		return
+	}
+	chargeFrom := chargeStart(startDate)
+	if endDate.Before(chargeFrom) {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: the end date is before the start date or today")
+		return
+	}
+
+	c := utils.Client{
+		Id:         req.ServerID,
+		AlterId:    defaultAlterID,
+		Username:   req.Username,
+		DeviceId:   req.DeviceID,
+		StartDate:  startDate.Format(dateFormat),
+		ExpireDate: endDate.Format(dateFormat),
+		Password:   req.Password,
+	}
+	switch {
+	case t == utils.VmessAccountType && c.Id == "":
+		c.Id = uuid.NewString()
+	case t != utils.VmessAccountType && c.Password == "":
+		c.Password = uuid.NewString()
+	}
+
+	key := clientKey(t, c)
+	if t == utils.SstpAccountType {
+		key = accountKey(t, c.Username)
+	}
+	if !h.apiCheckLimit(w, r) {
+		return
+	}
+
+	err = h.createAccount(r, t, c, key, req.Description, days(chargeFrom, endDate))
+	if err != nil {
+		logger(r).Error("creating the account gone wrong.", "err", err)
+		apiFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	h.apiNotify(r, "New user is created", c.Username, key, "created")
+
+	account := clientAccount(t, c, false)
+	if t == utils.SstpAccountType {
+		account = apiAccount{Type: t.String(), ID: c.Username, Username: c.Username, ExpireDate: c.ExpireDate, Password: c.Password}
+	}
+	apiRespond(w, http.StatusCreated, account)
+}
+
+// apiAccountEditPATCH changes the fields of the body of the v2ray account, the empty ones are kept.
+func (h *Handler) apiAccountEditPATCH(w http.ResponseWriter, r *http.Request) {
+	t, account, c, ok := h.apiAccount(w, r)
+	if !ok {
+		return
+	}
+	var req apiAccountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Type != "" || req.ServerID != "" || req.Password != "" || req.Description != "" {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: only the username, the device id and the dates can be changed.")
+		return
+	}
+
+	if req.Username != "" {
+		c.Username = req.Username
+	}
+	if req.DeviceID != "" {
+		if uuid.Validate(req.DeviceID) != nil {
+			apiFail(w, http.StatusBadRequest, "Invalid Request: invalid new device uuid")
+			return
+		}
+		c.DeviceId = req.DeviceID
+	}
+	for _, d := range []struct{ value, field string }{{req.StartDate, "start"}, {req.EndDate, "end"}} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.Parse(dateFormat, d.value); err != nil {
+			apiFail(w, http.StatusBadRequest, "Invalid Request: invalid "+d.field+" date format")
+			return
+		}
+	}
+	if req.StartDate != "" {
+		c.StartDate = req.StartDate
+	}
+	if req.EndDate != "" {
+		c.ExpireDate = req.EndDate
+	}
+
+	h.apiChange(w, r, t, account, c)
+}
+
+// apiAccountExtendPOST moves the expire date of the v2ray account the days later, from today if it's expired.
+func (h *Handler) apiAccountExtendPOST(w http.ResponseWriter, r *http.Request) {
+	t, account, c, ok := h.apiAccount(w, r)
+	if !ok {
+		return
+	}
+	var req apiExtendRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Days <= 0 {
+		apiFail(w, http.StatusBadRequest, "Invalid Request: the days must be more than 0.")
+		return
+	}
+
+	from := time.Now()
+	expire, err := time.Parse(dateFormat, c.ExpireDate)
+	if err == nil && expire.After(from) {
+		from = expire
+	}
+	c.ExpireDate = from.AddDate(0, 0, req.Days).Format(dateFormat)
+
+	h.apiChange(w, r, t, account, c)
+}
+
+// apiChange saves the changed v2ray account c and responds with it, the suspended accounts are refused.
+func (h *Handler) apiChange(w http.ResponseWriter, r *http.Request, t utils.AccountType, account apiAccount, c utils.Client) {
+	if t == utils.SstpAccountType {
+		apiFail(w, http.StatusNotImplemented, "Account Edit Unavailable For SSTP Accounts")
+		return
+	}
+	if account.Suspended {
+		apiFail(w, http.StatusConflict, "The account is suspended, resume it first.")
+		return
+	}
+
+	old, status, err := h.editAccount(r, t, accountKey(t, account.ID), c)
+	if err != nil {
+		logger(r).Error("editing the account gone wrong.", "err", err)
+		apiFailed(w, err, status)
+		return
+	}
+	h.apiNotify(r, "User is updated", old.Username, accountKey(t, account.ID), "updated to "+c.Username+" until "+c.ExpireDate)
+	apiRespond(w, http.StatusOK, clientAccount(t, c, false))
+}
+
+// apiAccountDELETE deletes the account, the suspended ones are forgotten.
+func (h *Handler) apiAccountDELETE(w http.ResponseWriter, r *http.Request) {
+	t, account, c, ok := h.apiAccount(w, r)
+	if !ok {
+		return
+	}
+	key := accountKey(t, account.ID)
+
+	if account.Suspended {
+		err := h.Suspended.Remove(key)
+		if err == nil {
+			err = h.Owners.Remove(key)
+		}
+		if err != nil {
+			logger(r).Error("removing the suspended account gone wrong.", "err", err)
+			apiFail(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		h.auditAccount(r, database.AuditAccountDeleted, key, &c, nil)
+	} else {
+		_, status, err := h.deleteAccount(r, t, key, account.ID, c.DeviceId, account.Username)
+		if err != nil {
+			logger(r).Error("deleting the account gone wrong.", "err", err)
+			apiFailed(w, err, status)
+			return
+		}
+	}
+	h.apiNotify(r, "Existing user is deleted.", account.Username, key, "deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiAccountSuspendPOST takes the v2ray account off the server, keeping it to be resumed.
+func (h *Handler) apiAccountSuspendPOST(w http.ResponseWriter, r *http.Request) {
+	t, account, c, ok := h.apiAccount(w, r)
+	if !ok {
+		return
+	}
+	if t == utils.SstpAccountType {
+		apiFail(w, http.StatusNotImplemented, "Suspending Unavailable For SSTP Accounts")
+		return
+	}
+	if account.Suspended {
+		apiFail(w, http.StatusConflict, "The account is already suspended.")
+		return
+	}
+
+	key := accountKey(t, account.ID)
+	admin := auth.AdminFromContext(r.Context())
+	err := h.Suspended.Add(database.SuspendedAccount{Key: key, Type: t.String(), Client: c, Actor: admin})
+	if err != nil {
+		logger(r).Error("saving the suspended account gone wrong.", "err", err)
+		apiFail(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	cFile, uFile := t.Filename()
+	var status int
+	if t == utils.VmessAccountType {
+		_, status, err = utils.DeleteVmessUser(c.Id, c.DeviceId, cFile, uFile)
+	} else {
+		_, status, err = utils.DeleteShadowsocksUser(c.Password, c.DeviceId, cFile, uFile)
+	}
+	if err != nil {
+		logger(r).Error("suspending the account gone wrong.", "err", err)
+		// the account is still on the server.
+		h.Suspended.Remove(key)
+		apiFailed(w, err, status)
+		return
+	}
+	err = utils.RestartService()
+	if err != nil {
+		logger(r).Error("restarting the services after suspending gone wrong.", "err", err)
+		apiFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditAccountSuspended, Target: accountTarget(key, c.Username)})
+	logger(r).Info("Account is suspended.", "account", key)
+
+	account.Suspended = true
+	apiRespond(w, http.StatusOK, account)
+}
+
+// apiAccountResumePOST puts the suspended v2ray account back on the server. The shadowsocks
+// accounts may get another port, changing their keys.
+func (h *Handler) apiAccountResumePOST(w http.ResponseWriter, r *http.Request) {
+	t, account, c, ok := h.apiAccount(w, r)
+	if !ok {
+		return
+	}
+	if !account.Suspended {
+		apiFail(w, http.StatusConflict, "The account is not suspended.")
+		return
+	}
+	// the suspended accounts aren't active, resuming one counts like creating it.
+	if !h.apiCheckLimit(w, r) {
+		return
+	}
+
+	cFile, uFile := t.Filename()
+	var err error
+	if t == utils.VmessAccountType {
+		err = utils.CreateVmessUser(c, cFile, uFile)
+	} else {
+		err = utils.CreateShadowsocksUser(c, cFile, uFile)
+	}
+	if err == nil {
+		err = utils.RestartService()
+	}
+	if err != nil {
+		logger(r).Error("resuming the account gone wrong.", "err", err)
+		apiFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	key := accountKey(t, account.ID)
+	err = h.Suspended.Remove(key)
+	if err != nil {
+		logger(r).Error("removing the suspended account gone wrong.", "err", err)
+	}
+
+	ip := clientip.FromRequest(r).String()
+	admin := auth.AdminFromContext(r.Context())
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditAccountResumed, Target: accountTarget(key, c.Username)})
+	logger(r).Info("Account is resumed.", "account", key)
+
+	account, _, err = h.findAccount(t, account.ID)
+	if err != nil {
+		logger(r).Error("getting the resumed account gone wrong.", "err", err)
+		apiFail(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	apiRespond(w, http.StatusOK, account)
+}
+
+// apiAccountURIsGET gives the keys of the v2ray account.
+func (h *Handler) apiAccountURIsGET(w http.ResponseWriter, r *http.Request) {
+	t, _, c, ok := h.apiAccount(w, r)
+	if !ok {
+		return
+	}
+	if t == utils.SstpAccountType {
+		apiFail(w, http.StatusNotImplemented, "SSTP accounts have no URIs.")
+		return
+	}
+
+	var uris apiURIs
+	var err error
+	uris.URI, uris.Remarks, err = GenerateURI(c, t)
+	if err == nil {
+		uris.LockedURI, uris.LockedRemarks, err = GenerateLockedURI(c, t)
+	}
+	if err != nil {
+		logger(r).Error("generating the uris gone wrong.", "err", err)
+		apiFail(w, http.StatusInternalServerError, "Internal Server Error: "+err.Error())
+		return
+	}
+	apiRespond(w, http.StatusOK, uris)
+}
+
+// apiServerStatusGET gives the status of the vpn services and the counts of the accounts.
+func (h *Handler) apiServerStatusGET(w http.ResponseWriter, r *http.Request) {
+	status := apiServerStatus{
+		Services:      map[string]string{},
+		UptimeSeconds: int64(time.Since(started).Seconds()),
+		MemoryBytes:   utils.GetMemoryUsage(),
+		Accounts:      map[string]int{},
+	}
+	for _, s := range utils.Services {
+		status.Services[s.Name] = utils.ServiceState(s.Unit)
+	}
+
+	for _, t := range []utils.AccountType{utils.VmessAccountType, utils.ShadowsocksAccountType} {
+		users, err := GetAllUsers(t)
+		if err != nil {
+			logger(r).Error("counting the accounts gone wrong.", "type", t, "err", err)
+			apiFail(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		status.Accounts[t.String()] = len(users)
+	}
+	users, err := utils.GetSSTPUsers()
+	if err != nil {
+		logger(r).Error("counting the sstp accounts gone wrong.", "err", err)
+	} else {
+		status.Accounts[utils.SstpAccountType.String()] = len(users)
+	}
+	apiRespond(w, http.StatusOK, status)
+}
+
+// apiNotify sends the change of the account with the key made by the logged in admin with the api.
+func (h *Handler) apiNotify(r *http.Request, title, username, key, change string) {
+	cfg := config.Get()
+	ip := clientip.FromRequest(r).String()
+	message := username + "@" + cfg.WebHostIP + " with [[" + key + "]] is " + change + " by " + auth.AdminFromContext(r.Context()) + " (api, " + ip + ")"
+	h.Notifier.Notify(cfg.WebHost+" - "+title, message, 5)
+}
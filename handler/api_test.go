@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	json "github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/htetmyatthar/lothone/internal/app"
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+)
+
+// testAPI returns the api with the admins owner, reseller and other, whose api tokens are their
+// usernames. The v2ray files are in a temporary directory and the services are not restarted.
+func testAPI(t *testing.T) (*Handler, http.Handler) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"vmess.json":             `{"inbounds": [{"port": 10086, "protocol": "vmess", "settings": {"clients": []}}]}`,
+		"vmess_users.json":       `{"clients": []}`,
+		"shadowsocks.json":       `{"inbounds": []}`,
+		"shadowsocks_users.json": `{"clients": []}`,
+		// the systemctl of the restarts.
+		"sudo": "#!/bin/sh\nexit 0\n",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	c := config.Default()
+	c.ConfigFilePrefix, c.UserFilePrefix = dir+"/", dir+"/"
+	c.CreditPrices = []string{"vmess~30"}
+	config.Set(c)
+
+	db, err := database.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	a := &app.App{DB: db, Sessions: scs.New(), Notifier: utils.NewNotifier()}
+	a.Admins, err = database.NewAdminStore(db)
+	if err == nil {
+		a.Owners, err = database.NewOwnerStore(db)
+	}
+	if err == nil {
+		a.Credits, err = database.NewCreditStore(db)
+	}
+	if err == nil {
+		a.Suspended, err = database.NewSuspendedStore(db)
+	}
+	if err == nil {
+		a.Audit, err = database.NewAuditStore(db)
+	}
+	for _, admin := range []struct{ name, role string }{{"owner", "owner"}, {"reseller", "reseller"}, {"other", "reseller"}} {
+		if err == nil {
+			err = a.Admins.Add(admin.name, "hash", admin.role)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(a)
+	r := chi.NewRouter()
+	r.Use(a.Sessions.LoadAndSave)
+	r.Use(auth.TokenMiddleware(func(token string) (string, []string, error) {
+		return token, []string{"accounts:read", "accounts:write"}, nil
+	}))
+	r.Route("/api/v1", h.apiRoutes)
+	return h, r
+}
+
+// apiDo makes the request of the admin of the token, without one if it's empty.
+func apiDo(h http.Handler, token, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/v1"+path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// decodeAccount returns the account of the successful response w.
+func decodeAccount(t *testing.T, w *httptest.ResponseRecorder, status int) apiAccount {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected %d, got %d: %s", status, w.Code, w.Body)
+	}
+	var account apiAccount
+	err := json.Unmarshal(w.Body.Bytes(), &account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+// createVmess creates the vmess account of the reseller until the end, topping up the credits it needs.
+func createVmess(t *testing.T, h *Handler, api http.Handler, end time.Time) apiAccount {
+	t.Helper()
+	_, err := h.Credits.Credit(database.CreditEntry{Admin: "reseller", Kind: database.CreditTopUp, Amount: 100, Actor: "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"type": "1", "username": "user", "device_id": "` + uuid.NewString() + `", "end_date": "` + end.Format(dateFormat) + `"}`
+	return decodeAccount(t, apiDo(api, "reseller", http.MethodPost, "/accounts", body), http.StatusCreated)
+}
+
+func TestAPIAccountErrors(t *testing.T) {
+	_, api := testAPI(t)
+	tests := []struct {
+		name         string
+		token        string
+		method, path string
+		body         string
+		status       int
+		code         string
+	}{
+		{"without token", "", http.MethodGet, "/accounts", "", http.StatusUnauthorized, "unauthorized"},
+		{"unknown endpoint", "owner", http.MethodGet, "/nothing", "", http.StatusNotFound, "not_found"},
+		{"wrong method", "owner", http.MethodPut, "/accounts", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"invalid type", "owner", http.MethodGet, "/accounts/9/id", "", http.StatusBadRequest, "bad_request"},
+		{"invalid id", "owner", http.MethodGet, "/accounts/1/id", "", http.StatusBadRequest, "bad_request"},
+		{"missing account", "owner", http.MethodGet, "/accounts/1/" + uuid.NewString(), "", http.StatusNotFound, "not_found"},
+		{"unknown field", "owner", http.MethodPost, "/accounts", `{"name": "user"}`, http.StatusBadRequest, "bad_request"},
+		{"not allowed", "reseller", http.MethodDelete, "/accounts/1/" + uuid.NewString(), "", http.StatusForbidden, "forbidden"},
+		{"end before today", "owner", http.MethodPost, "/accounts",
+			`{"type": "1", "username": "user", "device_id": "` + uuid.NewString() + `", "start_date": "2020-01-01", "end_date": "2020-02-01"}`,
+			http.StatusBadRequest, "bad_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := apiDo(api, tt.token, tt.method, tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected the json content type, got %q", ct)
+			}
+			var resp map[string]apiError
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil {
+				t.Fatalf("expected an error object, got %s: %v", w.Body, err)
+			}
+			e := resp["error"]
+			if e.Status != tt.status || e.Code != tt.code || e.Message == "" {
+				t.Errorf("unexpected error %+v", e)
+			}
+		})
+	}
+}
+
+func TestAPIAccountsOfOthers(t *testing.T) {
+	h, api := testAPI(t)
+	account := createVmess(t, h, api, time.Now().AddDate(0, 0, 30))
+	path := "/accounts/1/" + account.ID
+
+	// the accounts of the other admins are not found, even to change them.
+	for _, r := range []struct{ method, path, body string }{
+		{http.MethodGet, path, ""},
+		{http.MethodPatch, path, `{"username": "taken"}`},
+		{http.MethodPost, path + "/extend", `{"days": 30}`},
+		{http.MethodPost, path + "/suspend", ""},
+	} {
+		w := apiDo(api, "other", r.method, r.path, r.body)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d: %s", r.method, r.path, w.Code, w.Body)
+		}
+	}
+
+	w := apiDo(api, "other", http.MethodGet, "/accounts?type=1", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), account.ID) {
+		t.Errorf("expected the list without the account of the reseller, got %d: %s", w.Code, w.Body)
+	}
+
+	// the owners can access every account.
+	got := decodeAccount(t, apiDo(api, "owner", http.MethodGet, path, ""), http.StatusOK)
+	if got.Username != "user" {
+		t.Errorf("unexpected account %+v", got)
+	}
+}
+
+func TestAPIExtendDebits(t *testing.T) {
+	h, api := testAPI(t)
+	account := createVmess(t, h, api, time.Now().AddDate(0, 0, 30))
+	balance, err := h.Credits.Balance("reseller")
+	if err != nil || balance != 70 {
+		t.Fatalf("expected 30 credits to be debited for the new account, got the balance of %d, %v", balance, err)
+	}
+
+	extended := decodeAccount(t, apiDo(api, "reseller", http.MethodPost, "/accounts/1/"+account.ID+"/extend", `{"days": 60}`), http.StatusOK)
+	expire, _ := time.Parse(dateFormat, account.ExpireDate)
+	if extended.ExpireDate != expire.AddDate(0, 0, 60).Format(dateFormat) {
+		t.Errorf("expected the expire date 60 days after %s, got %s", account.ExpireDate, extended.ExpireDate)
+	}
+	balance, err = h.Credits.Balance("reseller")
+	if err != nil || balance != 10 {
+		t.Fatalf("expected 60 credits to be debited for the extension, got the balance of %d, %v", balance, err)
+	}
+
+	// the extension isn't made without the credits.
+	w := apiDo(api, "reseller", http.MethodPost, "/accounts/1/"+account.ID+"/extend", `{"days": 30}`)
+	if w.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected 402, got %d: %s", w.Code, w.Body)
+	}
+	got := decodeAccount(t, apiDo(api, "reseller", http.MethodGet, "/accounts/1/"+account.ID, ""), http.StatusOK)
+	if got.ExpireDate != extended.ExpireDate {
+		t.Errorf("expected the expire date %s to be kept, got %s", extended.ExpireDate, got.ExpireDate)
+	}
+
+	// the owners don't pay.
+	decodeAccount(t, apiDo(api, "owner", http.MethodPost, "/accounts/1/"+account.ID+"/extend", `{"days": 30}`), http.StatusOK)
+	balance, err = h.Credits.Balance("reseller")
+	if err != nil || balance != 10 {
+		t.Errorf("expected the balance of 10 to be kept, got %d, %v", balance, err)
+	}
+}
+
+func TestAPIExtendExpired(t *testing.T) {
+	h, api := testAPI(t)
+	account := createVmess(t, h, api, time.Now().AddDate(0, 0, 30))
+	path := "/accounts/1/" + account.ID
+	body := `{"start_date": "` + time.Now().AddDate(0, 0, -40).Format(dateFormat) + `", "end_date": "` + time.Now().AddDate(0, 0, -10).Format(dateFormat) + `"}`
+	decodeAccount(t, apiDo(api, "owner", http.MethodPatch, path, body), http.StatusOK)
+
+	// the days it's expired are not charged.
+	extended := decodeAccount(t, apiDo(api, "reseller", http.MethodPost, path+"/extend", `{"days": 30}`), http.StatusOK)
+	if want := time.Now().AddDate(0, 0, 30).Format(dateFormat); extended.ExpireDate != want {
+		t.Errorf("expected the expire date %s, got %s", want, extended.ExpireDate)
+	}
+	balance, err := h.Credits.Balance("reseller")
+	if err != nil || balance != 40 {
+		t.Errorf("expected 30 credits to be debited for the extension, got the balance of %d, %v", balance, err)
+	}
+}
+
+func TestAPISuspendResume(t *testing.T) {
+	h, api := testAPI(t)
+	account := createVmess(t, h, api, time.Now().AddDate(0, 0, 30))
+	path := "/accounts/1/" + account.ID
+
+	suspended := decodeAccount(t, apiDo(api, "reseller", http.MethodPost, path+"/suspend", ""), http.StatusOK)
+	if !suspended.Suspended {
+		t.Fatalf("expected the account to be suspended, got %+v", suspended)
+	}
+	users, err := GetAllUsers(utils.VmessAccountType)
+	if err != nil || len(users) != 0 {
+		t.Fatalf("expected the account to be taken off the server, got %+v, %v", users, err)
+	}
+	got := decodeAccount(t, apiDo(api, "reseller", http.MethodGet, path, ""), http.StatusOK)
+	if !got.Suspended || got.ExpireDate != account.ExpireDate {
+		t.Errorf("expected the suspended account to be kept, got %+v", got)
+	}
+
+	for _, p := range []string{path + "/suspend", path + "/extend"} {
+		w := apiDo(api, "reseller", http.MethodPost, p, `{"days": 30}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s: expected 409, got %d: %s", p, w.Code, w.Body)
+		}
+	}
+
+	// the suspended accounts aren't active, so the limit is reached by resuming it.
+	err = h.Admins.SetAccountLimit("reseller", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createVmess(t, h, api, time.Now().AddDate(0, 0, 30))
+	w := apiDo(api, "reseller", http.MethodPost, path+"/resume", "")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected the limit to refuse the resume, got %d: %s", w.Code, w.Body)
+	}
+	err = h.Admins.SetAccountLimit("reseller", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := decodeAccount(t, apiDo(api, "reseller", http.MethodPost, path+"/resume", ""), http.StatusOK)
+	if resumed.Suspended || resumed.ID != account.ID || resumed.ExpireDate != account.ExpireDate {
+		t.Errorf("expected the account to be resumed as it was, got %+v", resumed)
+	}
+	users, err = GetAllUsers(utils.VmessAccountType)
+	if err != nil || len(users) != 2 {
+		t.Errorf("expected the account back on the server, got %+v, %v", users, err)
+	}
+	w = apiDo(api, "reseller", http.MethodPost, path+"/resume", "")
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 resuming again, got %d: %s", w.Code, w.Body)
+	}
+
+	// the audit log names the account without its id.
+	entries, err := h.Audit.Search(account.ID, 10)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected the id of the account to be left out of the audit log, got %+v, %v", entries, err)
+	}
+	entries, err = h.Audit.Search("vmess user (..."+account.ID[len(account.ID)-4:]+")", 10)
+	if err != nil || len(entries) != 3 {
+		t.Errorf("expected the creation, the suspension and the resume of the account, got %+v, %v", entries, err)
+	}
+}
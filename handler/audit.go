@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// auditLimit is the most entries the audit page shows.
+const auditLimit = 200
+
+// accountSecrets are the fields of the accountFields that are the keys of the accounts.
+var accountSecrets = []string{"server_id", "password"}
+
+// accountFields are the fields of the account c compared by the audit log, none if it's nil.
+func accountFields(c *utils.Client) map[string]string {
+	if c == nil {
+		return nil
+	}
+	return map[string]string{
+		"username":    c.Username,
+		"device_id":   c.DeviceId,
+		"server_id":   c.Id,
+		"password":    c.Password,
+		"start_date":  c.StartDate,
+		"expire_date": c.ExpireDate,
+	}
+}
+
+// accountTarget names the account with the key in the audit log by its protocol, the username and
+// the last characters of the id, as the ids of the v2ray accounts are what they connect with.
+func accountTarget(key, username string) string {
+	typ, id, _ := strings.Cut(key, ":")
+	t, err := utils.ParseAccountType(typ)
+	if err != nil {
+		return username
+	}
+	if t == utils.SstpAccountType {
+		return t.Protocol() + " " + id
+	}
+	if len(id) > 4 {
+		id = id[len(id)-4:]
+	}
+	return t.Protocol() + " " + username + " (..." + id + ")"
+}
+
+// auditAccount records the action of the logged in admin on the account with the key, with the
+// changes of the account from the before to the after.
+func (h *Handler) auditAccount(r *http.Request, action, key string, before, after *utils.Client) {
+	username := ""
+	if after != nil {
+		username = after.Username
+	} else if before != nil {
+		username = before.Username
+	}
+	h.audit(database.AuditEntry{
+		Actor:   auth.AdminFromContext(r.Context()),
+		IP:      clientip.FromRequest(r).String(),
+		Action:  action,
+		Target:  accountTarget(key, username),
+		Changes: database.AuditDiff(accountFields(before), accountFields(after), accountSecrets...),
+	})
+}
+
+// auditGETHTMX shows the latest entries of the audit log with the q in them, and whether the log is intact.
+// The search box only asks for the rows.
+func (h *Handler) auditGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	entries, err := h.Audit.Search(query, auditLimit)
+	if err != nil {
+		logger(r).Error("searching the audit log gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Target") == "audit-rows" {
+		components.AuditRows(entries).Render(r.Context(), w)
+		return
+	}
+
+	verified, intact := h.verifyAudit(r)
+	layout.AuditDashboard(entries, query, verified, intact).Render(r.Context(), w)
+}
+
+// verifyAudit checks the hash chain of the audit log, returning the result to show and whether it's intact.
+func (h *Handler) verifyAudit(r *http.Request) (string, bool) {
+	n, err := h.Audit.Verify()
+	if err != nil {
+		logger(r).Error("the audit log is broken.", "err", err)
+		return "The audit log is tampered with after " + strconv.Itoa(n) + " entries: " + err.Error(), false
+	}
+	return "The audit log of " + strconv.Itoa(n) + " entries is intact.", true
+}
+
+// auditVerifyGETHTMX verifies the audit log again, the -audit-verify command can't open the
+// database while the panel is running.
+func (h *Handler) auditVerifyGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	verified, intact := h.verifyAudit(r)
+	logger(r).Info("The audit log is verified.", "intact", intact)
+	layout.AuditVerified(verified, intact).Render(r.Context(), w)
+}
@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// creditHistoryLimit is the most ledger entries shown.
+const creditHistoryLimit = 200
+
+// days returns the whole days from the start to the end.
+func days(start, end time.Time) int {
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// chargeStart returns the day the new account starting at the start is charged from, the days
+// before today can't be used so they're not charged, but the account isn't free either.
+func chargeStart(start time.Time) time.Time {
+	today, _ := time.Parse(dateFormat, time.Now().Format(dateFormat))
+	if start.Before(today) {
+		return today
+	}
+	return start
+}
+
+// extendedDays returns the days the new end extends the v2ray account with the key, zero if it's not extended.
+// The expired accounts are extended from today, the days they're expired aren't charged.
+func extendedDays(t utils.AccountType, key string, end time.Time) (int, error) {
+	users, err := GetAllUsers(t)
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range users {
+		if clientKey(t, u) != key {
+			continue
+		}
+		expire, err := time.Parse(dateFormat, u.ExpireDate)
+		if err != nil {
+			return 0, err
+		}
+		return max(days(chargeStart(expire), end), 0), nil
+	}
+	return 0, nil
+}
+
+// charge makes the change of the account with the key, debiting the credits of the days of the
+// account type from the resellers. The other roles don't pay for the accounts.
+func (h *Handler) charge(r *http.Request, t utils.AccountType, days int, key string, change func() error) error {
+	credits := config.Get().Credits(t.Protocol(), days)
+	if auth.RoleFromContext(r.Context()) != auth.RoleReseller || credits == 0 {
+		return change()
+	}
+
+	admin := auth.AdminFromContext(r.Context())
+	e, err := h.Credits.Debit(database.CreditEntry{
+		Admin:   admin,
+		Account: key,
+		Actor:   admin,
+		Note:    strconv.Itoa(days) + " days of " + t.Protocol(),
+	}, credits, change)
+	if err != nil {
+		return err
+	}
+	logger(r).Info("Credits are debited.", "credits", credits, "reseller", admin, "account", key, "balance", e.Balance)
+	return nil
+}
+
+// chargeFailed writes the error response of the failed charge, with the status for the failed changes.
+func chargeFailed(w http.ResponseWriter, err error, status int) {
+	if err == database.ErrNoCredits {
+		http.Error(w, "Not enough credits, ask the owner to top up.", http.StatusPaymentRequired)
+		return
+	}
+	http.Error(w, "Internal Server Error: "+err.Error(), status)
+}
+
+// activeAccounts returns the count of the accounts of the admin that are not expired yet.
+func (h *Handler) activeAccounts(admin string) (int, error) {
+	owned, err := h.Owners.Owned(admin)
+	if err != nil || len(owned) == 0 {
+		return 0, err
+	}
+
+	now := time.Now()
+	active := 0
+	for _, t := range []utils.AccountType{utils.VmessAccountType, utils.ShadowsocksAccountType} {
+		users, err := GetAllUsers(t)
+		if err != nil {
+			return 0, err
+		}
+		for _, u := range users {
+			if owned[clientKey(t, u)] && accountActive(u.ExpireDate, now) {
+				active++
+			}
+		}
+	}
+
+	// the sstp server is only asked if there's any sstp account owned.
+	for key := range owned {
+		if !strings.HasPrefix(key, accountKey(utils.SstpAccountType, "")) {
+			continue
+		}
+		users, err := utils.GetSSTPUsers()
+		if err != nil {
+			return 0, err
+		}
+		for _, u := range users {
+			if owned[accountKey(utils.SstpAccountType, u.Name)] && (!u.IsExpires || accountActive(u.Expires, now)) {
+				active++
+			}
+		}
+		break
+	}
+	return active, nil
+}
+
+// limitReached returns the account limit of the logged in reseller if it's reached, zero if
+// it's not or the admin isn't a reseller.
+func (h *Handler) limitReached(r *http.Request) (int, error) {
+	if auth.RoleFromContext(r.Context()) != auth.RoleReseller {
+		return 0, nil
+	}
+
+	admin, err := h.Admins.Get(auth.AdminFromContext(r.Context()))
+	if err != nil || admin.AccountLimit == 0 {
+		return 0, err
+	}
+
+	active, err := h.activeAccounts(admin.Username)
+	if err != nil || active < admin.AccountLimit {
+		return 0, err
+	}
+	logger(r).Warn("Account limit is reached.", "reseller", admin.Username)
+	return admin.AccountLimit, nil
+}
+
+// checkLimit writes the error response and returns false if the logged in reseller has reached its account limit.
+func (h *Handler) checkLimit(w http.ResponseWriter, r *http.Request) bool {
+	limit, err := h.limitReached(r)
+	if err != nil {
+		logger(r).Error("counting the active accounts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if limit != 0 {
+		http.Error(w, "The limit of "+strconv.Itoa(limit)+" active accounts is reached.", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// creditsGETHTMX shows the credits of the logged in admin, or of the reseller chosen by the owners.
+func (h *Handler) creditsGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	manage := auth.Can(r.Context(), auth.ManageAdmins)
+	data := layout.CreditsData{Admin: auth.AdminFromContext(r.Context()), Prices: config.Get().CreditPrices}
+	if manage && r.FormValue("admin") != "" {
+		data.Admin = r.FormValue("admin")
+	}
+
+	err := h.creditsData(&data, manage)
+	if err != nil {
+		logger(r).Error("getting the credits gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	t := h.CSRF.Generate(w, "/credits", h.Sessions.Token(r.Context()))
+	layout.CreditsDashboard(data, t).Render(r.Context(), w)
+}
+
+// creditsData fills the data of the data.Admin, and the resellers for the owners.
+func (h *Handler) creditsData(data *layout.CreditsData, manage bool) error {
+	admin, err := h.Admins.Get(data.Admin)
+	if err != nil {
+		return err
+	}
+	data.Limit = admin.AccountLimit
+	data.Balance, err = h.Credits.Balance(admin.Username)
+	if err != nil {
+		return err
+	}
+	data.Active, err = h.activeAccounts(admin.Username)
+	if err != nil {
+		return err
+	}
+	data.History, err = h.Credits.History(admin.Username, creditHistoryLimit)
+	if err != nil || !manage {
+		return err
+	}
+
+	admins, err := h.Admins.All()
+	if err != nil {
+		return err
+	}
+	balances, err := h.Credits.Balances()
+	if err != nil {
+		return err
+	}
+	for _, a := range admins {
+		if auth.SavedRole(a.Role) == auth.RoleReseller {
+			data.Resellers = append(data.Resellers, components.ResellerCredits{Username: a.Username, Balance: balances[a.Username], Limit: a.AccountLimit})
+		}
+	}
+	return nil
+}
+
+// creditAdjustPOSTHTMX tops up or refunds the credits of the reseller.
+func (h *Handler) creditAdjustPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	username, kind, note := r.FormValue("admin"), r.FormValue("kind"), strings.TrimSpace(r.FormValue("note"))
+	amount, err := strconv.Atoi(r.FormValue("amount"))
+	if err != nil || amount <= 0 || (kind != database.CreditTopUp && kind != database.CreditRefund) {
+		http.Error(w, "Invalid Request: the credits must be a number more than 0.", http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.Admins.Get(username)
+	if errors.Is(err, database.ErrAdminNotFound) {
+		components.ErrorToast("There's no admin named "+username+".").Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	actor := auth.AdminFromContext(r.Context())
+	e, err := h.Credits.Credit(database.CreditEntry{Admin: username, Kind: kind, Amount: amount, Actor: actor, Note: note})
+	if err != nil {
+		logger(r).Error("crediting gone wrong.", "err", err)
+		components.ErrorToast("Crediting "+username+" failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	h.audit(database.AuditEntry{Actor: actor, IP: ip, Action: database.AuditCredited, Target: username, Detail: kind + " " + strconv.Itoa(amount)})
+	logger(r).Info("Credits are credited.", "credits", amount, "reseller", username, "kind", kind)
+
+	h.renderCredits(w, r, username)
+	components.NotiToast(strconv.Itoa(amount)+" credits are added, "+username+" has "+strconv.Itoa(e.Balance)+" now.").Render(r.Context(), w)
+}
+
+// creditLimitPOSTHTMX sets the most active accounts the reseller can have.
+func (h *Handler) creditLimitPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	username := r.FormValue("admin")
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil || limit < 0 {
+		http.Error(w, "Invalid Request: the limit must be a number, 0 for no limit.", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Admins.SetAccountLimit(username, limit)
+	if err != nil {
+		logger(r).Error("setting the account limit gone wrong.", "err", err)
+		components.ErrorToast("Setting the limit of "+username+" failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	actor := auth.AdminFromContext(r.Context())
+	h.audit(database.AuditEntry{Actor: actor, IP: ip, Action: database.AuditLimitChanged, Target: username, Detail: strconv.Itoa(limit)})
+	logger(r).Info("Account limit is changed.", "reseller", username, "limit", limit)
+
+	h.renderCredits(w, r, username)
+	components.NotiToast("The account limit of "+username+" is saved.").Render(r.Context(), w)
+}
+
+// renderCredits renders the credits of the reseller again after the owner changed them.
+func (h *Handler) renderCredits(w http.ResponseWriter, r *http.Request, username string) {
+	data := layout.CreditsData{Admin: username, Prices: config.Get().CreditPrices}
+	err := h.creditsData(&data, true)
+	if err != nil {
+		logger(r).Error("getting the credits gone wrong.", "err", err)
+		return
+	}
+	t := h.CSRF.Generate(w, "/credits", h.Sessions.Token(r.Context()))
+	layout.CreditsDashboard(data, t).Render(r.Context(), w)
+}
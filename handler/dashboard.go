@@ -1,5 +1 @@
 package handler
-
-import (
-	"github.com/go-chi/chi/v5"
-)
@@ -1,19 +1,15 @@
 package handler
 
 import (
-	"context"
-	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/htetmyatthar/lothone/internal/utils"
-	"github.com/htetmyatthar/lothone/middleware/csrf"
-	"github.com/htetmyatthar/lothone/middleware/session"
 	"github.com/htetmyatthar/lothone/web/components"
 	"github.com/htetmyatthar/lothone/web/layout"
 )
 
-func dashboardSpecificRefreshHTMX(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) dashboardSpecificRefreshHTMX(w http.ResponseWriter, r *http.Request) {
 	t := chi.URLParam(r, "type")
 
 	if r.Header.Get("HX-Request") != "true" {
@@ -24,37 +20,48 @@ func dashboardSpecificRefreshHTMX(w http.ResponseWriter, r *http.Request) {
 	switch t {
 
 	case "sstp":
-		log.Println("sstp dashboard is being rendered.")
+		logger(r).Debug("sstp dashboard is being rendered.")
 		sstpUsers, err := utils.GetSSTPUsers()
+		if err == nil {
+			sstpUsers, err = ownedOnly(h, r, sstpUsers, func(u utils.UserInfo) string {
+				return accountKey(utils.SstpAccountType, u.Name)
+			})
+		}
 		if err != nil {
-			log.Println("sstp error", err.Error())
+			logger(r).Error("getting the sstp users gone wrong.", "err", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		layout.SstpAccountsDashboard(sstpUsers, csrf.Generate(w, "/accounts", session.GetSessionMgr().Token(r.Context()))).Render(context.Background(), w)
-		components.NotiToast("SSTP dashboard refreshed.").Render(context.Background(), w)
+		layout.SstpAccountsDashboard(sstpUsers, h.CSRF.Generate(w, "/accounts", h.Sessions.Token(r.Context()))).Render(r.Context(), w)
+		components.NotiToast("SSTP dashboard refreshed.").Render(r.Context(), w)
 
 	case "vmess":
-		log.Println("vmess dashboard is being rendered.")
+		logger(r).Debug("vmess dashboard is being rendered.")
 		users, err := GetAllUsers(utils.VmessAccountType)
+		if err == nil {
+			users, err = h.ownedClients(r, utils.VmessAccountType, users)
+		}
 		if err != nil {
-			log.Println("vmess get users error: ", err.Error())
+			logger(r).Error("getting the vmess users gone wrong.", "err", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		layout.VmessAccountsDashboard(users, csrf.Generate(w, "/accounts", session.GetSessionMgr().Token(r.Context()))).Render(context.Background(), w)
-		components.NotiToast("Vmess dashboard refreshed.").Render(context.Background(), w)
+		layout.VmessAccountsDashboard(users, h.CSRF.Generate(w, "/accounts", h.Sessions.Token(r.Context()))).Render(r.Context(), w)
+		components.NotiToast("Vmess dashboard refreshed.").Render(r.Context(), w)
 
 	case "shadowsocks":
-		log.Println("shadowsocks dashboard is being rendered.")
+		logger(r).Debug("shadowsocks dashboard is being rendered.")
 		users, err := GetAllUsers(utils.ShadowsocksAccountType)
+		if err == nil {
+			users, err = h.ownedClients(r, utils.ShadowsocksAccountType, users)
+		}
 		if err != nil {
-			log.Println("shadowsocks get users error: ", err.Error())
+			logger(r).Error("getting the shadowsocks users gone wrong.", "err", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		layout.ShadowsocksAccountsDashboard(users, csrf.Generate(w, "/accounts", session.GetSessionMgr().Token(r.Context()))).Render(context.Background(), w)
-		components.NotiToast("Shadowsocks dashboard refreshed.").Render(context.Background(), w)
+		layout.ShadowsocksAccountsDashboard(users, h.CSRF.Generate(w, "/accounts", h.Sessions.Token(r.Context()))).Render(r.Context(), w)
+		components.NotiToast("Shadowsocks dashboard refreshed.").Render(r.Context(), w)
 
 	default:
 		w.Header().Set("HX-Redirect", "/dashboard")
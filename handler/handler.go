@@ -1 +1,111 @@
 package handler
+
+import (
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/htetmyatthar/lothone/internal/app"
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+)
+
+// Handler serves the panel with the dependencies of the App.
+type Handler struct {
+	*app.App
+}
+
+// New returns the Handler using the a.
+func New(a *app.App) *Handler {
+	return &Handler{App: a}
+}
+
+// Routes registers the public and the private routes of the panel on the r.
+func (h *Handler) Routes(r chi.Router) {
+	// public routes.
+	r.Group(func(r chi.Router) {
+		r.Use(limitByClientIP(20, 1*time.Minute))
+
+		r.Get("/login", newMuxHandler(nil, h.loginHTMX, h.loginHTML).CreateHandler())
+		r.Post("/login", h.loginPOSTHTMX)
+		r.Post("/login/totp", h.loginTOTPPOSTHTMX)
+		r.Post("/login/passkey/begin", h.loginPasskeyBeginPOST)
+		r.Post("/login/passkey", h.loginPasskeyPOST)
+	})
+
+	// json api, with its own authentication answering the errors in json.
+	r.Route("/api/v1", h.apiRoutes)
+
+	// prometheus metrics, for the api tokens unless they're public.
+	r.With(h.trustedOnly(config.TrustedPrivate, apiUntrusted), h.metricsAuth).Get("/metrics", h.metricsGET)
+
+	// private routes.
+	r.Group(func(r chi.Router) {
+		r.Use(h.trustedOnly(config.TrustedPrivate, untrustedPage))
+		r.Use(auth.AuthMiddleware(h.Sessions))
+		r.Use(auth.RoleMiddleware(h.Sessions, h.roleOf))
+		r.Use(logAdmin)
+		r.Use(h.sessionSeen)
+		r.Use(h.requireTOTP)
+
+		r.With(auth.Require(auth.ViewAccounts)).Get("/dashboard/{type}/refresh", h.dashboardSpecificRefreshHTMX)
+
+		r.With(auth.Require(auth.CreateAccounts)).Get("/account-form", h.accountFormGet)
+		r.With(auth.Require(auth.CreateAccounts)).Post("/accounts", h.accountCreateHTMX)
+		r.With(auth.Require(auth.EditAccounts)).Put("/accounts", h.accountEditHTMX)
+		r.With(auth.Require(auth.DeleteAccounts)).Delete("/accounts", h.accountDeleteHTMX)
+		r.With(auth.Require(auth.EditAccounts)).Get("/accounts/edit", h.accountEditGetHTMX)
+		r.With(auth.Require(auth.ViewAccounts)).Get("/accounts/{id}/qr", h.accountQRGETHTMX)
+		r.With(auth.Require(auth.ViewAccounts)).Get("/accounts/{id}/textkey", h.accountTextGETHTMX)
+		r.With(auth.Require(auth.TransferAccounts)).Post("/accounts/transfer", h.accountTransferPOSTHTMX)
+
+		// the pages of the logged in admins, not for the api tokens.
+		r.Group(func(r chi.Router) {
+			r.Use(auth.SessionOnly)
+
+			r.Post("/logout", newMuxHandler(nil, h.logoutPOSTHTMX, h.logoutPOSTHTML).CreateHandler())
+
+			// every role manages its own login.
+			r.Get("/totp", h.totpGETHTMX)
+			r.Post("/totp/enable", h.totpEnablePOSTHTMX)
+			r.Post("/totp/recovery", h.totpRecoveryPOSTHTMX)
+			r.Post("/totp/disable", h.totpDisablePOSTHTMX)
+
+			r.Get("/passkeys", h.passkeysGETHTMX)
+			r.Post("/passkeys/begin", h.passkeyBeginPOST)
+			r.Post("/passkeys", h.passkeyCreatePOST)
+			r.Post("/passkeys/remove", h.passkeyRemovePOSTHTMX)
+
+			r.Get("/tokens", h.tokensGETHTMX)
+			r.Post("/tokens", h.tokenCreatePOSTHTMX)
+			r.Post("/tokens/revoke", h.tokenRevokePOSTHTMX)
+
+			r.Get("/sessions", h.sessionsGETHTMX)
+			r.Post("/sessions/revoke", h.sessionRevokePOSTHTMX)
+			r.Post("/sessions/revoke-all", h.sessionRevokeAllPOSTHTMX)
+
+			// every role sees its own credits, the owners manage the credits of the resellers.
+			r.Get("/credits", h.creditsGETHTMX)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Require(auth.ManageAdmins))
+
+			r.Post("/credits/adjust", h.creditAdjustPOSTHTMX)
+			r.Post("/credits/limit", h.creditLimitPOSTHTMX)
+
+			r.Get("/admins", h.adminsGETHTMX)
+			r.Post("/admins/role", h.adminRolePOSTHTMX)
+
+			r.Get("/lockouts", h.lockoutsGETHTMX)
+			r.Post("/lockouts/unlock", h.lockoutUnlockPOSTHTMX)
+
+			r.Get("/audit", h.auditGETHTMX)
+			r.Get("/audit/verify", h.auditVerifyGETHTMX)
+		})
+
+		r.With(h.trustedOnly(config.TrustedServer, untrustedPage), auth.Require(auth.ViewServer)).Get("/server", h.serverGETHTMX)
+		r.With(h.trustedOnly(config.TrustedServer, untrustedPage), auth.Require(auth.ViewServer)).Get("/server/status", h.serverStatusGETHTMX)
+		r.With(h.trustedOnly(config.TrustedServer, untrustedPage), auth.Require(auth.ManageServer)).Post("/server/restart", h.serverRestartPOSTHTMX)
+		r.With(h.trustedOnly(config.TrustedServer, untrustedPage), auth.Require(auth.ManageServer)).Post("/server/reload", h.serverReloadPOSTHTMX)
+	})
+}
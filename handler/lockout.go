@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// lockoutsGETHTMX shows the usernames and the ip addresses that are locked out.
+func (h *Handler) lockoutsGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	lockouts, err := h.Lockouts.List()
+	if err != nil {
+		logger(r).Error("listing the lockouts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	t := h.CSRF.Generate(w, "/lockouts", h.Sessions.Token(r.Context()))
+	layout.LockoutsDashboard(lockouts, t).Render(r.Context(), w)
+}
+
+// lockoutUnlockPOSTHTMX unlocks the username or the ip address of the key before the lockout ends.
+func (h *Handler) lockoutUnlockPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	key := r.FormValue("key")
+	if !strings.HasPrefix(key, database.UserKey("")) && !strings.HasPrefix(key, database.IPKey("")) {
+		http.Error(w, "Invalid Request: invalid lockout key.", http.StatusBadRequest)
+		return
+	}
+
+	err := h.Lockouts.Reset(key)
+	if err != nil {
+		logger(r).Error("unlocking gone wrong.", "err", err)
+		components.ErrorToast("Unlocking "+key+" failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	admin := auth.AdminFromContext(r.Context())
+	err = h.Audit.Record(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditUnlocked, Target: key})
+	if err != nil {
+		logger(r).Error("recording the unlock gone wrong.", "err", err)
+	}
+	logger(r).Info("Unlocked.", "key", key)
+
+	// the row is swapped with nothing.
+	components.NotiToast(key+" is unlocked.").Render(r.Context(), w)
+}
@@ -2,138 +2,251 @@ package handler
 
 import (
 	"context"
-	"log"
-	"net"
+	"fmt"
+	"log/slog"
 	"net/http"
 	// "os"
 	"strings"
+	"time"
 
 	// "github.com/goccy/go-json"
 	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/metrics"
 	"github.com/htetmyatthar/lothone/internal/utils"
-	"github.com/htetmyatthar/lothone/middleware/csrf"
-	"github.com/htetmyatthar/lothone/middleware/session"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
 	"github.com/htetmyatthar/lothone/web/layout"
 )
 
-func loginHTMX(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) loginHTMX(w http.ResponseWriter, r *http.Request) {
 	// HACK: use the exists method instead of getbool?
-	authenticated := session.GetSessionMgr().GetBool(r.Context(), utils.AuthenticatedField)
+	authenticated := h.Sessions.GetBool(r.Context(), utils.AuthenticatedField)
 
 	if authenticated {
 		w.Header().Set("HX-Redirect", "/dashboard")
 		w.WriteHeader(http.StatusOK)
-		log.Println("Authenticated User Redirecting to dashboard")
+		logger(r).Debug("Authenticated User Redirecting to dashboard.")
 		return
 	}
 
-	session.GetSessionMgr().Put(r.Context(), utils.AuthenticatedField, false)
-	token, _, _ := session.GetSessionMgr().Commit(r.Context()) // note: Commit() method also checks that a session exists or not.
-	t := csrf.Generate(w, "/login", token)
+	h.Sessions.Put(r.Context(), utils.AuthenticatedField, false)
+	token, _, _ := h.Sessions.Commit(r.Context()) // note: Commit() method also checks that a session exists or not.
+	t := h.CSRF.Generate(w, "/login", token)
 
-	layout.LoginMain(t, config.Version).Render(context.Background(), w)
+	layout.LoginMain(t, config.Version).Render(r.Context(), w)
 	return
 }
 
-func loginHTML(w http.ResponseWriter, r *http.Request) {
-	authenticated := session.GetSessionMgr().GetBool(r.Context(), utils.AuthenticatedField)
+func (h *Handler) loginHTML(w http.ResponseWriter, r *http.Request) {
+	authenticated := h.Sessions.GetBool(r.Context(), utils.AuthenticatedField)
 
 	if authenticated {
-		log.Println("Authenticated User Redirecting to dashboard")
+		logger(r).Debug("Authenticated User Redirecting to dashboard.")
 		http.Redirect(w, r, "/dashboard", http.StatusFound)
 		return
 	}
 
-	session.GetSessionMgr().Put(r.Context(), utils.AuthenticatedField, false)
-	token, _, _ := session.GetSessionMgr().Commit(r.Context()) // note: Commit() method also checks that a session exists or not.
-	t := csrf.Generate(w, "/login", token)
+	h.Sessions.Put(r.Context(), utils.AuthenticatedField, false)
+	token, _, _ := h.Sessions.Commit(r.Context()) // note: Commit() method also checks that a session exists or not.
+	t := h.CSRF.Generate(w, "/login", token)
 
-	layout.LoginPage(t, config.Version).Render(context.Background(), w)
+	layout.LoginPage(t, config.Version).Render(r.Context(), w)
 	return
 }
 
-func loginPOSTHTMX(w http.ResponseWriter, r *http.Request) {
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		log.Println("IP not found to log error.")
+func (h *Handler) loginPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+	addr := clientip.FromRequest(r)
+	if !addr.IsValid() {
+		logger(r).Error("IP not found to log error.")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	ip := addr.String()
 
 	// new token for error form.
-	t := csrf.Generate(w, "/login", session.GetSessionMgr().Token(r.Context()))
+	t := h.CSRF.Generate(w, "/login", h.Sessions.Token(r.Context()))
 
 	name := r.FormValue("username")
 	pw := r.FormValue("password")
 
 	if name == "" || pw == "" {
-		log.Println("Attempt with blank credentials")
-		layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+		logger(r).Warn("Attempt with blank credentials.")
+		layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
 		return
 	}
 
-	// NOTE: sanity check. internal/utils.go:216 func InitPanelUsers
-	// BUG: refactor this so that the insanity check will pass through and changing one value won't affect other
-	// initpaneluser and this should use the same function to check the validation of username and password.
-	if len(name) > 30 || len(pw) > 30 {
-		layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+	// NOTE: sanity check, the same one the admin commands use.
+	if utils.CheckCredentials(name, pw) != nil {
+		layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
 		return
 	}
 
-	if _, ok := utils.PanelUsers[name]; !ok {
-		log.Println("Attempt with wrong username.")
-		layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+	until, err := h.Lockouts.Check(database.UserKey(name), database.IPKey(ip))
+	if err != nil {
+		logger(r).Error("checking the lockouts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !until.IsZero() {
+		logger(r).Warn("Attempt while locked out.")
+		layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
+		components.ErrorToast("Too many failed attempts, try again in "+time.Until(until).Round(time.Second).String()+".").Render(r.Context(), w)
 		return
 	}
 
-	correct, err := utils.VerifyPassword(pw, utils.PanelUsers[name])
+	admin, err := h.Admins.Get(name)
+	if err == database.ErrAdminNotFound {
+		logger(r).Warn("Attempt with wrong username.")
+		utils.VerifyDummy(pw)
+		h.loginFailed("password", name, ip)
+		layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	correct, err := utils.VerifyPassword(pw, admin.Hash)
 	// handle hashing errors.
 	if err != nil && err != utils.ErrWrongPassword {
-		log.Println("verifying user password gone wrong.", err)
+		logger(r).Error("verifying user password gone wrong.", "err", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	if !correct {
-		log.Println("Attempt with wrong password.", pw)
+		logger(r).Warn("Attempt with wrong password.")
 		// send a notification to the gotify server.
-		title := *config.WebHost + " - " + name + " logged in"
-		message := name + " logged into " + *config.WebHostIP + " using wrong password and " + ip
-		for _, key := range config.GotifyAPIKeys {
-			utils.SendNoti(*config.GotifyServer, key, title, message, 9)
-		}
-		layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+		title := cfg.WebHost + " - " + name + " logged in"
+		message := name + " logged into " + cfg.WebHostIP + " using wrong password and " + ip
+		h.Notifier.Notify(title, message, 9)
+		h.loginFailed("password", name, ip)
+		layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
 		return
 	}
 
-	// NOTE: below this assumes user is authenticated.
-	rememberMe := r.FormValue("remember")
-	if rememberMe == "1" { // checked.
-		session.GetSessionMgr().RememberMe(r.Context(), true)
+	// NOTE: below this assumes the password is correct.
+	// upgrade the legacy and the weaker hashes while the password is known.
+	if utils.NeedsRehash(admin.Hash) {
+		hash, err := utils.HashPassword(pw)
+		if err == nil {
+			err = h.Admins.SetHash(name, hash)
+		}
+		if err != nil {
+			logger(r).Error("upgrading the password hash gone wrong.", "err", err)
+		} else {
+			logger(r).Info("Password hash is upgraded.", "admin", name)
+		}
 	}
 
-	session.GetSessionMgr().Put(r.Context(), utils.AuthenticatedField, true)
+	rememberMe := r.FormValue("remember") == "1" // checked.
 
-	// send a notification to the gotify server.
-	title := *config.WebHost + " - " + name + " logged in"
-	message := name + " logged into " + *config.WebHostIP + " and " + ip
-	for _, key := range config.GotifyAPIKeys {
-		utils.SendNoti(*config.GotifyServer, key, title, message, 9)
+	passkeys, err := h.hasPasskeys(name)
+	if err != nil {
+		logger(r).Error("listing the passkeys gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
-	url := session.GetSessionMgr().GetString(r.Context(), utils.URLAfterLogin)
-	if strings.Contains(url, "dashboard") {
-		w.Header().Set("HX-Push-Url", url)
-		w.Header().Set("HX-Redirect", url)
-		w.WriteHeader(http.StatusFound)
-		session.GetSessionMgr().Remove(r.Context(), utils.URLAfterLogin)
+	// the two-factor code or a passkey is asked before logging in, or the two-factor
+	// authentication is set up first when it's required.
+	if admin.TOTPEnabled() || passkeys || cfg.RequireTOTP {
+		h.startSecondStep(w, r, admin, passkeys, rememberMe)
 		return
 	}
-	w.Header().Set("HX-Push-Url", "/dashboard")
-	w.Header().Set("HX-Redirect", "/dashboard")
+
+	h.logIn(r, name, ip, rememberMe)
+	h.redirectAfterLogin(w, r)
+}
+
+// logIn authenticates the session as the admin, the credentials must be verified already.
+func (h *Handler) logIn(r *http.Request, name, ip string, rememberMe bool) {
+	cfg := config.Get()
+
+	if rememberMe {
+		h.Sessions.RememberMe(r.Context(), true)
+	}
+
+	h.clearPending(r.Context())
+	// a new token for the logged in session, it's tracked with it.
+	err := h.Sessions.RenewToken(r.Context())
+	if err != nil {
+		logger(r).Error("renewing the session token gone wrong.", "err", err)
+	}
+	h.Sessions.Put(r.Context(), utils.AuthenticatedField, true)
+	h.Sessions.Put(r.Context(), utils.AdminField, name)
+	h.trackSession(r, name, ip)
+	h.audit(database.AuditEntry{Actor: name, IP: ip, Action: database.AuditLogin, Target: name})
+
+	for _, key := range []string{database.UserKey(name), database.IPKey(ip)} {
+		err := h.Lockouts.Reset(key)
+		if err != nil {
+			logger(r).Error("resetting the failed attempts gone wrong.", "err", err)
+		}
+	}
+
+	// send a notification to the gotify server.
+	title := cfg.WebHost + " - " + name + " logged in"
+	message := name + " logged into " + cfg.WebHostIP + " and " + ip
+	h.Notifier.Notify(title, message, 9)
+}
+
+// redirectAfterLogin sends the logged in admin to the page they were going to, or the dashboard.
+func (h *Handler) redirectAfterLogin(w http.ResponseWriter, r *http.Request) {
+	url := h.urlAfterLogin(r.Context())
+	w.Header().Set("HX-Push-Url", url)
+	w.Header().Set("HX-Redirect", url)
 	w.WriteHeader(http.StatusFound)
-	session.GetSessionMgr().Remove(r.Context(), utils.URLAfterLogin)
-	return
+}
+
+// urlAfterLogin returns the page the logged in admin was going to, or the dashboard.
+func (h *Handler) urlAfterLogin(ctx context.Context) string {
+	url := h.Sessions.PopString(ctx, utils.URLAfterLogin)
+	if strings.Contains(url, "dashboard") {
+		return url
+	}
+	return "/dashboard"
+}
+
+// loginFailed records the failed attempt of the username from the ip with the method, password, totp
+// or passkey, locking both of them out after the config.MaxFailedAttempts.
+func (h *Handler) loginFailed(method, name, ip string) {
+	cfg := config.Get()
+	lockout := time.Duration(cfg.LockOutDuration) * time.Minute
+	metrics.LoginFailures.Inc(method)
+
+	err := h.Audit.Record(database.AuditEntry{Actor: name, IP: ip, Action: database.AuditLoginFailed})
+	if err != nil {
+		slog.Error("recording the failed attempt gone wrong.", "err", err)
+	}
+
+	for _, key := range []string{database.UserKey(name), database.IPKey(ip)} {
+		l, err := h.Lockouts.Fail(key, config.MaxFailedAttempts, lockout)
+		if err != nil {
+			slog.Error("recording the failed attempt gone wrong.", "err", err)
+			continue
+		}
+		if !l.Locked {
+			continue
+		}
+
+		slog.Warn("Locked out after too many failed attempts.", "key", key, "ip", ip)
+		err = h.Audit.Record(database.AuditEntry{
+			Actor:  name,
+			IP:     ip,
+			Action: database.AuditLoginLocked,
+			Target: key,
+			Detail: fmt.Sprintf("%d failed attempts, locked for %d minutes", l.Failures, cfg.LockOutDuration),
+		})
+		if err != nil {
+			slog.Error("recording the lockout gone wrong.", "err", err)
+		}
+		h.Notifier.Notify(cfg.WebHost+" - "+key+" is locked out", key+" is locked out of "+cfg.WebHostIP+" after too many failed attempts from "+ip, 9)
+	}
 }
 
 // HACK: not finished yet.
@@ -153,21 +266,21 @@ func loginPOSTHTMX(w http.ResponseWriter, r *http.Request) {
 // 	if name == "" || pw == "" {
 // 		log.Println("Attempt with blank credentials")
 // 		// BUG: redirect back to the login form?
-// 		// layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+// 		// layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
 // 		return
 // 	}
 //
 // 	// NOTE: sanity check. internal/utils.go:196
 // 	if len(name) > 20 || len(pw) > 30 {
 // 		// BUG: redirect back to the login form?
-// 		// layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+// 		// layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
 // 		return
 // 	}
 //
 // 	if _, ok := utils.PanelUsers[name]; !ok {
 // 		log.Println("Attempt with wrong username.")
 // 		// BUG: redirect back to the login form?
-// 		// layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+// 		// layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
 // 		return
 // 	}
 //
@@ -180,15 +293,15 @@ func loginPOSTHTMX(w http.ResponseWriter, r *http.Request) {
 // 	}
 //
 // 	if !correct {
-// 		log.Println("Attempt with wrong password.", pw)
+// 		log.Println("Attempt with wrong password.")
 // 		// send a notification to the gotify server.
-// 		title := *config.WebHost + " - " + name + " logged in"
-// 		message := name + " logged into " + *config.WebHostIP + " using wrong password and " + ip
-// 		for _, key := range config.GotifyAPIKeys {
-// 			utils.SendNoti(*config.GotifyServer, key, title, message, 9)
+// 		title := config.Get().WebHost + " - " + name + " logged in"
+// 		message := name + " logged into " + config.Get().WebHostIP + " using wrong password and " + ip
+// 		for _, key := range config.Get().GotifyAPIKeys {
+// 			utils.SendNoti(config.Get().GotifyServer, key, title, message, 9)
 // 		}
 // 		// BUG: redirect back to the login form?
-// 		// layout.LoginFormWithError(t, name, pw).Render(context.Background(), w)
+// 		// layout.LoginFormWithError(t, name, pw).Render(r.Context(), w)
 // 		return
 // 	}
 //
@@ -227,10 +340,10 @@ func loginPOSTHTMX(w http.ResponseWriter, r *http.Request) {
 // 	}
 //
 // 	// send a notification to the gotify server.
-// 	title := *config.WebHost + " - " + name + " logged in"
-// 	message := name + " logged into " + *config.WebHostIP + " and " + ip
-// 	for _, key := range config.GotifyAPIKeys {
-// 		utils.SendNoti(*config.GotifyServer, key, title, message, 9)
+// 	title := config.Get().WebHost + " - " + name + " logged in"
+// 	message := name + " logged into " + config.Get().WebHostIP + " and " + ip
+// 	for _, key := range config.Get().GotifyAPIKeys {
+// 		utils.SendNoti(config.Get().GotifyServer, key, title, message, 9)
 // 	}
 //
 // 	layout.DashboardPage(
@@ -242,6 +355,6 @@ func loginPOSTHTMX(w http.ResponseWriter, r *http.Request) {
 // 			w,
 // 			"/accounts",
 // 			session.GetSessionMgr().Token(r.Context())),
-// 	).Render(context.Background(), w)
+// 	).Render(r.Context(), w)
 // 	return
 // }
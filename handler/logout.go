@@ -1,47 +1,58 @@
 package handler
 
 import (
-	"log"
 	"net/http"
 
+	"github.com/htetmyatthar/lothone/internal/database"
 	"github.com/htetmyatthar/lothone/internal/utils"
-	"github.com/htetmyatthar/lothone/middleware/session"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
 )
 
-func logoutPOSTHTMX(w http.ResponseWriter, r *http.Request) {
-	authenticated := session.GetSessionMgr().GetBool(r.Context(), utils.AuthenticatedField)
+func (h *Handler) logoutPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	authenticated := h.Sessions.GetBool(r.Context(), utils.AuthenticatedField)
 
 	if !authenticated {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	h.auditLogout(r)
 	// remove session and such?
-	err := session.GetSessionMgr().Destroy(r.Context())
+	err := h.Sessions.Destroy(r.Context())
 	if err != nil {
-		log.Println("There's no session to be destroy.")
+		logger(r).Warn("There's no session to be destroy.")
 		return
 	}
 
 	w.Header().Set("HX-Redirect", "/login")
 	w.Header().Set("HX-Pust-Url", "/login")
 	w.WriteHeader(http.StatusOK)
-	log.Println("Authenticated User Redirecting to login page and Loging Out.")
+	logger(r).Debug("Authenticated User Redirecting to login page and Loging Out.")
 	return
 }
 
-func logoutPOSTHTML(w http.ResponseWriter, r *http.Request) {
-	authenticated := session.GetSessionMgr().GetBool(r.Context(), utils.AuthenticatedField)
+func (h *Handler) logoutPOSTHTML(w http.ResponseWriter, r *http.Request) {
+	authenticated := h.Sessions.GetBool(r.Context(), utils.AuthenticatedField)
 
 	if !authenticated {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// remove only the authentication field?
-	session.GetSessionMgr().Put(r.Context(), utils.AuthenticatedField, false)
+	h.auditLogout(r)
+	// the session is removed with where it's logged in from.
+	err := h.Sessions.Destroy(r.Context())
+	if err != nil {
+		logger(r).Warn("There's no session to be destroy.")
+	}
 
 	http.Redirect(w, r, "/login", http.StatusFound)
-	log.Println("Authenticated User Redirecting to login page and Loging Out.")
+	logger(r).Debug("Authenticated User Redirecting to login page and Loging Out.")
 	return
 }
+
+// auditLogout records the logging out of the admin of the session.
+func (h *Handler) auditLogout(r *http.Request) {
+	admin := h.Sessions.GetString(r.Context(), utils.AdminField)
+	h.audit(database.AuditEntry{Actor: admin, IP: clientip.FromRequest(r).String(), Action: database.AuditLogout, Target: admin})
+}
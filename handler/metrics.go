@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/metrics"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+)
+
+// metricsAuth serves the metrics only if they're turned on, to the api tokens of the server:read
+// scope unless they're public.
+func (h *Handler) metricsAuth(next http.Handler) http.Handler {
+	protected := h.apiAuth(auth.RoleMiddleware(h.Sessions, h.roleOf)(logAdmin(apiRequire(auth.ViewServer)(next))))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := config.Get()
+		if !c.Metrics {
+			http.NotFound(w, r)
+			return
+		}
+		if c.MetricsPublic {
+			next.ServeHTTP(w, r)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	})
+}
+
+// metricsGET writes the metrics of the panel with the accounts counted now, in the prometheus text format.
+func (h *Handler) metricsGET(w http.ResponseWriter, r *http.Request) {
+	accounts, expiring := h.accountMetrics(r, time.Now())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err := metrics.Default.Write(w, accounts, expiring)
+	if err != nil {
+		logger(r).Error("writing the metrics gone wrong.", "err", err)
+	}
+}
+
+// accountMetrics counts the accounts of every protocol by their status, active, expired or suspended, and
+// the active ones expiring within the config.MetricsExpiringDays from the now. The protocols whose
+// accounts can't be listed are left out.
+func (h *Handler) accountMetrics(r *http.Request, now time.Time) (accounts, expiring *metrics.Gauge) {
+	accounts = metrics.NewGauge("lothone_accounts", "Count of the accounts by the protocol and the status.", "protocol", "status")
+	expiring = metrics.NewGauge("lothone_accounts_expiring", "Count of the active accounts expiring soon by the protocol.", "protocol")
+	within := time.Duration(config.Get().MetricsExpiringDays) * 24 * time.Hour
+
+	count := func(t utils.AccountType, expireDate string) {
+		status, soon := accountStatus(expireDate, now, within)
+		accounts.Add(1, t.Protocol(), status)
+		if soon {
+			expiring.Add(1, t.Protocol())
+		}
+	}
+
+	for _, t := range []utils.AccountType{utils.VmessAccountType, utils.ShadowsocksAccountType} {
+		users, err := GetAllUsers(t)
+		if err != nil {
+			logger(r).Error("counting the accounts gone wrong.", "type", t, "err", err)
+			continue
+		}
+		suspended, err := h.Suspended.List(t.String())
+		if err != nil {
+			logger(r).Error("counting the suspended accounts gone wrong.", "type", t, "err", err)
+			continue
+		}
+		// the protocols without any account are still there as 0.
+		accounts.Add(0, t.Protocol(), "active")
+		expiring.Add(0, t.Protocol())
+		for _, u := range users {
+			count(t, u.ExpireDate)
+		}
+		accounts.Add(float64(len(suspended)), t.Protocol(), "suspended")
+	}
+
+	users, err := utils.GetSSTPUsers()
+	if err != nil {
+		logger(r).Error("counting the sstp accounts gone wrong.", "err", err)
+		return accounts, expiring
+	}
+	accounts.Add(0, utils.SstpAccountType.Protocol(), "active")
+	expiring.Add(0, utils.SstpAccountType.Protocol())
+	for _, u := range users {
+		// the sstp accounts without the expiry never expire.
+		if !u.IsExpires {
+			count(utils.SstpAccountType, "")
+			continue
+		}
+		count(utils.SstpAccountType, u.Expires)
+	}
+	return accounts, expiring
+}
+
+// accountActive reports whether the account expiring at the date is active at the now.
+func accountActive(date string, now time.Time) bool {
+	status, _ := accountStatus(date, now, 0)
+	return status == "active"
+}
+
+// accountStatus returns whether the account expiring at the date is active or expired at the now, and
+// whether it's active and expires within the duration. The accounts are active through the end of
+// their expire date, and the ones without a valid date are active.
+func accountStatus(date string, now time.Time, within time.Duration) (status string, expiring bool) {
+	end, err := time.ParseInLocation(dateFormat, date, now.Location())
+	if err != nil {
+		return "active", false
+	}
+	end = end.AddDate(0, 0, 1)
+	if !now.Before(end) {
+		return "expired", false
+	}
+	return "active", end.Sub(now) <= within
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/htetmyatthar/lothone/internal/app"
+	"github.com/htetmyatthar/lothone/internal/config"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	sessions := scs.New()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := sessions.LoadAndSave(New(&app.App{Sessions: sessions}).metricsAuth(ok))
+
+	tests := []struct {
+		name   string
+		on     bool
+		public bool
+		status int
+	}{
+		{"off", false, false, http.StatusNotFound},
+		{"without token", true, false, http.StatusUnauthorized},
+		{"public", true, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.Default()
+			c.Metrics, c.MetricsPublic = tt.on, tt.public
+			config.Set(c)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if w.Code != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestAccountStatus(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	tests := []struct {
+		date     string
+		status   string
+		expiring bool
+	}{
+		{"2026-03-01", "expired", false},
+		{"2026-03-09", "expired", false},
+		{"2026-03-10", "active", true},
+		{"2026-03-15", "active", true},
+		{"2026-04-15", "active", false},
+		{"", "active", false},
+	}
+	for _, tt := range tests {
+		status, expiring := accountStatus(tt.date, now, week)
+		if status != tt.status || expiring != tt.expiring {
+			t.Errorf("%q: expected %s %v, got %s %v", tt.date, tt.status, tt.expiring, status, expiring)
+		}
+	}
+}
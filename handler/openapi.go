@@ -0,0 +1,17 @@
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPI is the OpenAPI document of the api, kept in sync with the apiRoutes by the tests.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+// openAPIGET gives the OpenAPI document of the api.
+func openAPIGET(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
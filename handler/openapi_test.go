@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	json "github.com/goccy/go-json"
+	"github.com/htetmyatthar/lothone/client"
+	"github.com/htetmyatthar/lothone/internal/app"
+	"github.com/htetmyatthar/lothone/internal/config"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func apiRouter() chi.Router {
+	config.Set(config.Default())
+	r := chi.NewRouter()
+	sessions := scs.New()
+	r.Use(sessions.LoadAndSave)
+	New(&app.App{Sessions: sessions}).apiRoutes(r)
+	return r
+}
+
+func readOpenAPI(t *testing.T) openAPIDocument {
+	var doc openAPIDocument
+	err := json.Unmarshal(openAPI, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	var routes []string
+	err := chi.Walk(apiRouter(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+strings.TrimSuffix(route, "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var documented []string
+	for path, item := range readOpenAPI(t).Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	slices.Sort(routes)
+	slices.Sort(documented)
+	if !slices.Equal(routes, documented) {
+		t.Errorf("the routes and the openapi.json are out of sync\nroutes:     %v\ndocumented: %v", routes, documented)
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := readOpenAPI(t)
+	// the types of the client must be in sync too.
+	for _, s := range []struct {
+		name string
+		v    any
+	}{
+		{"Account", apiAccount{}},
+		{"Account", client.Account{}},
+		{"AccountRequest", apiAccountRequest{}},
+		{"AccountRequest", client.AccountRequest{}},
+		{"ExtendRequest", apiExtendRequest{}},
+		{"URIs", apiURIs{}},
+		{"URIs", client.URIs{}},
+		{"ServerStatus", apiServerStatus{}},
+		{"ServerStatus", client.ServerStatus{}},
+		{"Error", apiError{}},
+		{"Error", client.Error{}},
+	} {
+		var fields []string
+		typ := reflect.TypeOf(s.v)
+		for i := range typ.NumField() {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			fields = append(fields, tag)
+		}
+
+		var properties []string
+		for property := range doc.Components.Schemas[s.name].Properties {
+			properties = append(properties, property)
+		}
+
+		slices.Sort(fields)
+		slices.Sort(properties)
+		if !slices.Equal(fields, properties) {
+			t.Errorf("the %s schema is out of sync with %T, fields %v, properties %v", s.name, s.v, fields, properties)
+		}
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	r := apiRouter()
+	for path, status := range map[string]int{"/accounts": http.StatusUnauthorized, "/missing": http.StatusNotFound, "/openapi.json": http.StatusOK} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("expected %d for %s, got %d", status, path, w.Code)
+			continue
+		}
+		if status == http.StatusOK {
+			continue
+		}
+
+		var body map[string]apiError
+		err := json.Unmarshal(w.Body.Bytes(), &body)
+		if err != nil || body["error"].Status != status || body["error"].Code == "" || body["error"].Message == "" {
+			t.Errorf("unexpected error object of %s: %s, %v", path, w.Body, err)
+		}
+	}
+}
+
+func TestAPIUntrusted(t *testing.T) {
+	r := apiRouter()
+	c, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-admins", "a~b", "-trusted", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Set(c)
+	defer config.Set(config.Default())
+
+	req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	req.RemoteAddr = "8.8.8.8:1000"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected the untrusted ip to be forbidden, got %d", w.Code)
+	}
+
+	// the trusted ones are authenticated as usual.
+	req.RemoteAddr = "10.0.0.1:1000"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the trusted ip to be unauthorized, got %d", w.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+)
+
+// accountKey is the key of the owner of the account, the sstp accounts are known by the username
+// and the others by the server id, which is the password of the shadowsocks accounts.
+func accountKey(t utils.AccountType, id string) string {
+	return database.AccountKey(t.String(), id)
+}
+
+// clientKey is the accountKey of the v2ray account.
+func clientKey(t utils.AccountType, c utils.Client) string {
+	if t == utils.ShadowsocksAccountType {
+		return accountKey(t, c.Password)
+	}
+	return accountKey(t, c.Id)
+}
+
+// canAccess reports whether the logged in admin can access the account with the key, the
+// admins without the auth.AllAccounts permission can access their own accounts only.
+func (h *Handler) canAccess(r *http.Request, key string) (bool, error) {
+	if auth.Can(r.Context(), auth.AllAccounts) {
+		return true, nil
+	}
+	owner, err := h.Owners.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return owner != "" && owner == auth.AdminFromContext(r.Context()), nil
+}
+
+// checkAccess writes the error response and returns false if the logged in admin can't access
+// the account with the key. The accounts of the others are answered as not found.
+func (h *Handler) checkAccess(w http.ResponseWriter, r *http.Request, key string) bool {
+	ok, err := h.canAccess(r, key)
+	if err != nil {
+		logger(r).Error("getting the account owner gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		logger(r).Warn("Access to the account of another admin.", "account", key)
+		http.Error(w, "Account not found.", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// ownedOnly returns the accounts of the list that the logged in admin can access.
+func ownedOnly[T any](h *Handler, r *http.Request, list []T, key func(T) string) ([]T, error) {
+	if auth.Can(r.Context(), auth.AllAccounts) {
+		return list, nil
+	}
+	owned, err := h.Owners.Owned(auth.AdminFromContext(r.Context()))
+	if err != nil {
+		return nil, err
+	}
+	var accounts []T
+	for _, a := range list {
+		if owned[key(a)] {
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts, nil
+}
+
+// ownedClients returns the v2ray accounts of the type that the logged in admin can access.
+func (h *Handler) ownedClients(r *http.Request, t utils.AccountType, users []utils.Client) ([]utils.Client, error) {
+	return ownedOnly(h, r, users, func(c utils.Client) string { return clientKey(t, c) })
+}
+
+// setOwner makes the logged in admin the owner of the new account with the key.
+func (h *Handler) setOwner(r *http.Request, key string) {
+	_, err := h.Owners.Set(key, auth.AdminFromContext(r.Context()))
+	if err != nil {
+		logger(r).Error("saving the account owner gone wrong.", "err", err)
+	}
+}
+
+// accountTransferPOSTHTMX gives the account to the admin typed into the prompt.
+func (h *Handler) accountTransferPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	accType, err := utils.ParseAccountType(r.FormValue("type"))
+	id := r.FormValue("serverId")
+	to := strings.TrimSpace(r.Header.Get("HX-Prompt"))
+	if err != nil || id == "" || to == "" {
+		http.Error(w, "Invalid Request: missing required fields.", http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.Admins.Get(to)
+	if errors.Is(err, database.ErrAdminNotFound) {
+		components.ErrorToast("There's no admin named "+to+".").Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	key := accountKey(accType, id)
+	from, err := h.Owners.Set(key, to)
+	if err != nil {
+		logger(r).Error("transferring the account gone wrong.", "err", err)
+		components.ErrorToast("Transferring the account failed.").Render(r.Context(), w)
+		return
+	}
+
+	// the username only names the v2ray account in the audit log.
+	var username string
+	if accType != utils.SstpAccountType {
+		account, _, err := h.findAccount(accType, id)
+		if err != nil {
+			logger(r).Warn("getting the transferred account gone wrong.", "err", err)
+		}
+		username = account.Username
+	}
+
+	ip := clientip.FromRequest(r).String()
+	admin := auth.AdminFromContext(r.Context())
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditAccountTransferred, Target: accountTarget(key, username), Detail: from + " -> " + to})
+	logger(r).Info("Account is transferred.", "account", key, "from", from, "to", to)
+
+	components.NotiToast("The account is transferred to "+to+".").Render(r.Context(), w)
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	json "github.com/goccy/go-json"
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/internal/webauthn"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// maxPasskeyName is the length limit of the passkey names.
+const maxPasskeyName = 40
+
+// passkeyVerifyTimeout is the time a passkey has to be registered in after the admin verified again.
+const passkeyVerifyTimeout = 5 * time.Minute
+
+// relyingParty is the panel the passkeys are registered to, the browsers only use them on the same domain.
+func relyingParty() webauthn.RelyingParty {
+	cfg := config.Get()
+	origin := "https://" + cfg.WebHost
+	if cfg.WebPort != ":443" {
+		origin += cfg.WebPort
+	}
+	return webauthn.RelyingParty{ID: cfg.WebHost, Name: "Lothone " + cfg.WebHost, Origin: origin}
+}
+
+// hasPasskeys reports whether the admin has registered any passkey.
+func (h *Handler) hasPasskeys(name string) (bool, error) {
+	passkeys, err := h.Passkeys.List(name)
+	return len(passkeys) != 0, err
+}
+
+// newChallenge makes the challenge of the passkey prompt, kept in the session until it's answered.
+func (h *Handler) newChallenge(ctx context.Context) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+	h.Sessions.Put(ctx, utils.PasskeyChallengeField, challenge)
+	return challenge, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Error("writing the json response gone wrong.", "err", err)
+	}
+}
+
+// loginPasskeyBeginPOST gives the options of the passkey prompt of the login. The passkeys of the
+// admin are asked for the second step after the password, otherwise any passkey of the panel
+// can be used without the password, with the user verification of the authenticator.
+func (h *Handler) loginPasskeyBeginPOST(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.newChallenge(r.Context())
+	if err != nil {
+		logger(r).Error("making the passkey challenge gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	name := h.pendingAdmin(r.Context())
+	if name == "" {
+		writeJSON(w, relyingParty().RequestOptions(challenge, nil, true))
+		return
+	}
+
+	passkeys, err := h.Passkeys.List(name)
+	if err != nil {
+		logger(r).Error("listing the passkeys gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	var ids [][]byte
+	for _, p := range passkeys {
+		ids = append(ids, p.ID)
+	}
+	writeJSON(w, relyingParty().RequestOptions(challenge, ids, false))
+}
+
+// passkeyLogin is the answer of the passkey prompt of the login.
+type passkeyLogin struct {
+	webauthn.AssertionResponse
+	// Remember is the remember me of the login form, for the logins without the password.
+	Remember bool `json:"remember"`
+}
+
+// loginPasskeyPOST logs in with the passkey, as the second step after the password or without it.
+func (h *Handler) loginPasskeyPOST(w http.ResponseWriter, r *http.Request) {
+	addr := clientip.FromRequest(r)
+	if !addr.IsValid() {
+		logger(r).Error("IP not found to log error.")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	ip := addr.String()
+
+	challenge := h.Sessions.PopString(r.Context(), utils.PasskeyChallengeField)
+	var req passkeyLogin
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req)
+	if err != nil || challenge == "" {
+		http.Error(w, "Invalid Request: invalid passkey response.", http.StatusBadRequest)
+		return
+	}
+
+	until, err := h.Lockouts.Check(database.IPKey(ip))
+	if err != nil {
+		logger(r).Error("checking the lockouts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !until.IsZero() {
+		logger(r).Warn("Passkey attempt while locked out.")
+		http.Error(w, "Too many failed attempts, try again in "+time.Until(until).Round(time.Second).String()+".", http.StatusTooManyRequests)
+		return
+	}
+
+	id, err := req.CredentialID()
+	if err != nil {
+		http.Error(w, "Invalid Request: invalid passkey id.", http.StatusBadRequest)
+		return
+	}
+	passkey, err := h.Passkeys.Get(id)
+	if err == database.ErrPasskeyNotFound {
+		logger(r).Warn("Attempt with unknown passkey.")
+		h.loginFailed("passkey", req.User(), ip)
+		http.Error(w, "The passkey is not registered.", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		logger(r).Error("getting the passkey gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	pending := h.pendingAdmin(r.Context())
+	passwordless := pending == ""
+	if (!passwordless && passkey.Admin != pending) || (req.UserHandle != "" && req.User() != passkey.Admin) {
+		logger(r).Warn("Attempt with the passkey of another admin.")
+		h.loginFailed("passkey", passkey.Admin, ip)
+		http.Error(w, "The passkey is not of this admin.", http.StatusUnauthorized)
+		return
+	}
+
+	until, err = h.Lockouts.Check(database.UserKey(passkey.Admin))
+	if err != nil {
+		logger(r).Error("checking the lockouts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !until.IsZero() {
+		logger(r).Warn("Passkey attempt while locked out.")
+		http.Error(w, "Too many failed attempts, try again in "+time.Until(until).Round(time.Second).String()+".", http.StatusTooManyRequests)
+		return
+	}
+
+	credential := webauthn.Credential{ID: passkey.ID, PublicKey: passkey.PublicKey, SignCount: passkey.SignCount}
+	signCount, err := relyingParty().VerifyLogin(challenge, credential, req.AssertionResponse, passwordless)
+	if err != nil {
+		logger(r).Warn("Attempt with invalid passkey response.", "err", err)
+		h.loginFailed("passkey", passkey.Admin, ip)
+		if err == webauthn.ErrSignCount {
+			cfg := config.Get()
+			h.Notifier.Notify(cfg.WebHost+" - "+passkey.Admin+" passkey may be cloned", "The passkey "+passkey.Name+" of "+passkey.Admin+" went backwards on "+cfg.WebHostIP+" from "+ip, 9)
+		}
+		http.Error(w, "The passkey is not verified.", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.Passkeys.Used(passkey.ID, signCount)
+	if err != nil {
+		logger(r).Error("saving the passkey counter gone wrong.", "err", err)
+	}
+
+	rememberMe := req.Remember
+	if !passwordless {
+		rememberMe = h.Sessions.GetBool(r.Context(), utils.PendingRememberField)
+	}
+	h.logIn(r, passkey.Admin, ip, rememberMe)
+	writeJSON(w, map[string]string{"redirect": h.urlAfterLogin(r.Context())})
+}
+
+// passkeysGETHTMX shows the passkeys of the logged in admin.
+func (h *Handler) passkeysGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	admin, err := h.Admins.Get(h.Sessions.GetString(r.Context(), utils.AdminField))
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	passkeys, err := h.Passkeys.List(admin.Username)
+	if err != nil {
+		logger(r).Error("listing the passkeys gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	t := h.CSRF.Generate(w, "/passkeys", h.Sessions.Token(r.Context()))
+	layout.PasskeysDashboard(passkeys, admin.TOTPEnabled(), t).Render(r.Context(), w)
+}
+
+// passkeyVerification is the password, or the two-factor code when it's enabled, the admin verifies
+// again with before registering a new passkey.
+type passkeyVerification struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// passkeyBeginPOST gives the options of the passkey prompt registering a new passkey of the logged in admin,
+// after verifying the admin again so a left open session can't add a way to log in.
+func (h *Handler) passkeyBeginPOST(w http.ResponseWriter, r *http.Request) {
+	ip := clientip.FromRequest(r).String()
+	name := h.Sessions.GetString(r.Context(), utils.AdminField)
+
+	var req passkeyVerification
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid Request: invalid verification.", http.StatusBadRequest)
+		return
+	}
+
+	admin, err := h.Admins.Get(name)
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	until, err := h.Lockouts.Check(database.UserKey(name), database.IPKey(ip))
+	if err != nil {
+		logger(r).Error("checking the lockouts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !until.IsZero() {
+		logger(r).Warn("Passkey verification while locked out.")
+		http.Error(w, "Too many failed attempts, try again in "+time.Until(until).Round(time.Second).String()+".", http.StatusTooManyRequests)
+		return
+	}
+
+	if admin.TOTPEnabled() {
+		_, err = h.verifyTOTP(admin, ip, strings.TrimSpace(req.Code))
+		if wrongCode(err) {
+			logger(r).Warn("Passkey verification with wrong code.")
+			h.loginFailed("totp", name, ip)
+			http.Error(w, "The authentication code is wrong.", http.StatusForbidden)
+			return
+		}
+	} else {
+		var correct bool
+		correct, err = utils.VerifyPassword(req.Password, admin.Hash)
+		if !correct && (err == nil || err == utils.ErrWrongPassword) {
+			logger(r).Warn("Passkey verification with wrong password.")
+			h.loginFailed("password", name, ip)
+			http.Error(w, "The password is wrong.", http.StatusForbidden)
+			return
+		}
+	}
+	if err != nil {
+		logger(r).Error("verifying the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.Sessions.Put(r.Context(), utils.PasskeyVerifiedField, time.Now())
+
+	passkeys, err := h.Passkeys.List(name)
+	if err != nil {
+		logger(r).Error("listing the passkeys gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	challenge, err := h.newChallenge(r.Context())
+	if err != nil {
+		logger(r).Error("making the passkey challenge gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var ids [][]byte
+	for _, p := range passkeys {
+		ids = append(ids, p.ID)
+	}
+	writeJSON(w, relyingParty().CreationOptions(challenge, name, ids))
+}
+
+// passkeyRegistration is the answer of the passkey prompt registering a new passkey.
+type passkeyRegistration struct {
+	webauthn.AttestationResponse
+	Name string `json:"name"`
+}
+
+// passkeyCreatePOST registers the new passkey of the logged in admin.
+func (h *Handler) passkeyCreatePOST(w http.ResponseWriter, r *http.Request) {
+	ip := clientip.FromRequest(r).String()
+	admin := h.Sessions.GetString(r.Context(), utils.AdminField)
+
+	// each verification registers one passkey.
+	verified := h.Sessions.PopTime(r.Context(), utils.PasskeyVerifiedField)
+	if time.Since(verified) > passkeyVerifyTimeout {
+		logger(r).Warn("Passkey registration without a recent verification.")
+		h.Sessions.Remove(r.Context(), utils.PasskeyChallengeField)
+		http.Error(w, "Verify again to add the passkey.", http.StatusForbidden)
+		return
+	}
+
+	challenge := h.Sessions.PopString(r.Context(), utils.PasskeyChallengeField)
+	var req passkeyRegistration
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req)
+	if err != nil || challenge == "" {
+		http.Error(w, "Invalid Request: invalid passkey response.", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if utf8.RuneCountInString(name) > maxPasskeyName {
+		http.Error(w, "Invalid Request: the name is too long.", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := relyingParty().VerifyRegistration(challenge, req.AttestationResponse)
+	if err != nil {
+		logger(r).Warn("Invalid passkey registration.", "err", err)
+		http.Error(w, "The passkey is not verified: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.Passkeys.Add(database.Passkey{
+		ID:        credential.ID,
+		Admin:     admin,
+		Name:      name,
+		PublicKey: credential.PublicKey,
+		SignCount: credential.SignCount,
+	})
+	if err != nil {
+		logger(r).Error("saving the passkey gone wrong.", "err", err)
+		http.Error(w, "Saving the passkey failed.", http.StatusInternalServerError)
+		return
+	}
+
+	logger(r).Info("Passkey is added.")
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditPasskeyAdded, Target: admin, Detail: name})
+	writeJSON(w, map[string]string{"name": name})
+}
+
+// passkeyRemovePOSTHTMX removes the passkey of the logged in admin.
+func (h *Handler) passkeyRemovePOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	ip := clientip.FromRequest(r).String()
+	admin := h.Sessions.GetString(r.Context(), utils.AdminField)
+
+	id, err := webauthn.DecodeID(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid Request: invalid passkey id.", http.StatusBadRequest)
+		return
+	}
+
+	passkey, err := h.Passkeys.Get(id)
+	if err == nil {
+		err = h.Passkeys.Remove(admin, id)
+	}
+	if err != nil {
+		logger(r).Error("removing the passkey gone wrong.", "err", err)
+		components.ErrorToast("Removing the passkey failed.").Render(r.Context(), w)
+		return
+	}
+
+	logger(r).Info("Passkey is removed.")
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditPasskeyRemoved, Target: admin, Detail: passkey.Name})
+
+	// the row is swapped with nothing.
+	components.NotiToast(passkey.Name+" is removed.").Render(r.Context(), w)
+}
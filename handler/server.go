@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/sysinfo"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// statsInterval is how long the cpu and the network usage are sampled for the server status.
+const statsInterval = 250 * time.Millisecond
+
+// panelRestartDelay is how long the restart of the panel waits for its response to be sent.
+const panelRestartDelay = time.Second
+
+// restarting is held while a service is restarted, so the restarts don't overlap.
+var restarting sync.Mutex
+
+// serverStatus reads the usage of the server and the systemd states of the services by their names,
+// the usage is nil if it can't be read.
+func serverStatus(r *http.Request) (*sysinfo.Stats, map[string]string) {
+	states := map[string]string{}
+	for _, s := range utils.Services {
+		states[s.Name] = utils.ServiceState(s.Unit)
+	}
+	stats, err := sysinfo.ReadServer(statsInterval)
+	if err != nil {
+		logger(r).Error("reading the usage of the server gone wrong.", "err", err)
+		return nil, states
+	}
+	return &stats, states
+}
+
+// serverGETHTMX shows the server page with its status, and the restarts of the services.
+func (h *Handler) serverGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	stats, states := serverStatus(r)
+	t := h.CSRF.Generate(w, "/server", h.Sessions.Token(r.Context()))
+	layout.ServerDashboard(stats, states, t).Render(r.Context(), w)
+}
+
+// serverStatusGETHTMX gives the status of the server, for the server page to keep it up to date and
+// the check of the dashboard.
+func (h *Handler) serverStatusGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	stats, states := serverStatus(r)
+	components.ServerStatus(stats, states).Render(r.Context(), w)
+}
+
+// serverRestartPOSTHTMX restarts one of the utils.Services, one restart at a time. The panel is
+// restarted after its response is sent, as it's stopped by its own restart.
+func (h *Handler) serverRestartPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	service, ok := utils.FindService(r.FormValue("service"))
+	if !ok {
+		logger(r).Warn("Restart of an unknown service.", "service", r.FormValue("service"))
+		http.Error(w, "Invalid Request: unknown service.", http.StatusBadRequest)
+		return
+	}
+	if !restarting.TryLock() {
+		components.ErrorToast("Another service is restarting, try again in a moment.").Render(r.Context(), w)
+		return
+	}
+
+	entry := database.AuditEntry{
+		Actor:  auth.AdminFromContext(r.Context()),
+		IP:     clientip.FromRequest(r).String(),
+		Action: database.AuditServerRestarted,
+		Target: service.Name,
+	}
+
+	if service.Name == utils.PanelService {
+		h.audit(entry)
+		logger(r).Warn("The panel is restarting.")
+		go func() {
+			defer restarting.Unlock()
+			time.Sleep(panelRestartDelay)
+			err := utils.RestartUnit(service.Unit)
+			if err != nil {
+				slog.Error("restarting the panel gone wrong.", "err", err)
+			}
+		}()
+		components.NotiToast("The panel is restarting, reload the page in a few seconds.").Render(r.Context(), w)
+		return
+	}
+
+	err := utils.RestartUnit(service.Unit)
+	restarting.Unlock()
+	w.Header().Set("HX-Trigger", "server-restarted")
+	if err != nil {
+		logger(r).Error("restarting the service gone wrong.", "service", service.Name, "err", err)
+		entry.Detail = "failed: " + err.Error()
+		h.audit(entry)
+		components.ErrorToast("Restarting "+service.Name+" failed, see the logs of the panel.").Render(r.Context(), w)
+		return
+	}
+	h.audit(entry)
+	logger(r).Info("The service is restarted.", "service", service.Name)
+	components.NotiToast("The "+service.Name+" service is restarted.").Render(r.Context(), w)
+}
+
+// serverReloadPOSTHTMX reloads the configuration of the panel, same as sending SIGHUP.
+func (h *Handler) serverReloadPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	changes, err := config.Reload()
+	if err != nil {
+		logger(r).Error("Configuration reload rejected, keeping the current one.", "err", err)
+		components.ErrorToast("Configuration is not reloaded: "+err.Error()).Render(r.Context(), w)
+		return
+	}
+	// the values of the secret settings are not in the changes.
+	h.audit(database.AuditEntry{
+		Actor:  auth.AdminFromContext(r.Context()),
+		IP:     clientip.FromRequest(r).String(),
+		Action: database.AuditConfigReloaded,
+		Detail: strings.Join(changes, "; "),
+	})
+
+	if len(changes) == 0 {
+		components.NotiToast("Configuration reloaded, nothing changed.").Render(r.Context(), w)
+		return
+	}
+	components.NotiToast(fmt.Sprintf("Configuration reloaded, %d setting(s) changed.", len(changes))).Render(r.Context(), w)
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// sessionKey returns the key of the session of the r in the session store.
+func (h *Handler) sessionKey(r *http.Request) string {
+	return database.SessionKey(h.Sessions.Token(r.Context()))
+}
+
+// trackSession saves where the admin logged in from with the new session of the r and logs
+// them out of their least recently used sessions over the max sessions.
+// The session must have its token, renewed at the login.
+func (h *Handler) trackSession(r *http.Request, admin, ip string) {
+	now := time.Now()
+	key := h.sessionKey(r)
+	err := h.SessionStore.Track(database.SessionInfo{ID: key, Admin: admin, IP: ip, UserAgent: r.UserAgent(), CreatedAt: now, LastSeen: now})
+	if err != nil {
+		logger(r).Error("tracking the session gone wrong.", "err", err)
+		return
+	}
+
+	revoked, err := h.SessionStore.Limit(admin, key, config.Get().MaxSessions)
+	if err != nil {
+		logger(r).Error("limiting the sessions gone wrong.", "err", err)
+		return
+	}
+	for _, s := range revoked {
+		h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditSessionRevoked, Target: admin, Detail: s.IP + " over the session limit"})
+	}
+}
+
+// sessionSeen saves when and where the sessions of the logged in admins are last used.
+func (h *Handler) sessionSeen(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.FromToken(r.Context()) {
+			ip := clientip.FromRequest(r).String()
+			err := h.SessionStore.Seen(h.sessionKey(r), auth.AdminFromContext(r.Context()), ip, r.UserAgent(), time.Now())
+			if err != nil {
+				logger(r).Error("saving the session last seen gone wrong.", "err", err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sessionsGETHTMX shows the sessions of the logged in admin.
+func (h *Handler) sessionsGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+
+	sessions, err := h.SessionStore.Sessions(auth.AdminFromContext(r.Context()))
+	if err != nil {
+		logger(r).Error("listing the sessions gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	t := h.CSRF.Generate(w, "/sessions", h.Sessions.Token(r.Context()))
+	layout.SessionsDashboard(sessions, h.sessionKey(r), t).Render(r.Context(), w)
+}
+
+// sessionRevokePOSTHTMX logs the logged in admin out of another of their sessions.
+func (h *Handler) sessionRevokePOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if id == "" || id == h.sessionKey(r) {
+		http.Error(w, "Invalid Request: the current session is logged out instead.", http.StatusBadRequest)
+		return
+	}
+
+	admin := auth.AdminFromContext(r.Context())
+	s, err := h.SessionStore.Revoke(admin, id)
+	if err != nil {
+		logger(r).Error("revoking the session gone wrong.", "err", err)
+		components.ErrorToast("Revoking the session failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditSessionRevoked, Target: admin, Detail: s.IP})
+	logger(r).Info("Session is revoked.", "session_ip", s.IP)
+
+	// the row is swapped with nothing.
+	components.NotiToast("The session of "+s.IP+" is logged out.").Render(r.Context(), w)
+}
+
+// sessionRevokeAllPOSTHTMX logs the logged in admin out of every session, the current one too.
+func (h *Handler) sessionRevokeAllPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	admin := auth.AdminFromContext(r.Context())
+	n, err := h.SessionStore.RevokeAll(admin, h.sessionKey(r))
+	if err != nil {
+		logger(r).Error("revoking the sessions gone wrong.", "err", err)
+		components.ErrorToast("Logging out everywhere failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditSessionsRevoked, Target: admin, Detail: strconv.Itoa(n+1) + " sessions"})
+	logger(r).Info("Logged out everywhere.")
+
+	h.logoutPOSTHTMX(w, r)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// tokenExpiries are the days the api tokens can be valid for, zero for the tokens that don't expire.
+var tokenExpiries = map[string]int{"7": 7, "30": 30, "90": 90, "365": 365, "0": 0}
+
+// tokensGETHTMX shows the api tokens of the logged in admin.
+func (h *Handler) tokensGETHTMX(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+		return
+	}
+	h.renderTokens(w, r, "")
+}
+
+// tokenCreatePOSTHTMX creates a new api token of the logged in admin and shows it once.
+func (h *Handler) tokenCreatePOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid Request: invalid form.", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	scopes := r.Form["scope"]
+	days, ok := tokenExpiries[r.FormValue("expires")]
+	if name == "" || len(name) > 40 || len(scopes) == 0 || !ok {
+		http.Error(w, "Invalid Request: the token needs a name, a scope and an expiry.", http.StatusBadRequest)
+		return
+	}
+	_, err = auth.ScopePermissions(scopes)
+	if err != nil {
+		http.Error(w, "Invalid Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt time.Time
+	if days != 0 {
+		expiresAt = time.Now().AddDate(0, 0, days)
+	}
+	admin := auth.AdminFromContext(r.Context())
+	token, t, err := h.Tokens.Create(admin, name, scopes, expiresAt)
+	if err != nil {
+		logger(r).Error("creating the api token gone wrong.", "err", err)
+		components.ErrorToast("Creating the api token failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditTokenCreated, Target: admin, Detail: t.Name + " " + strings.Join(t.Scopes, ",") + " " + strconv.Itoa(days) + " days"})
+	logger(r).Info("API token is created.", "name", t.Name)
+
+	h.renderTokens(w, r, token)
+	components.NotiToast(t.Name+" is created.").Render(r.Context(), w)
+}
+
+// tokenRevokePOSTHTMX revokes the api token of the logged in admin.
+func (h *Handler) tokenRevokePOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	admin := auth.AdminFromContext(r.Context())
+	t, err := h.Tokens.Revoke(admin, r.FormValue("id"))
+	if err != nil {
+		logger(r).Error("revoking the api token gone wrong.", "err", err)
+		components.ErrorToast("Revoking the api token failed.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	h.audit(database.AuditEntry{Actor: admin, IP: ip, Action: database.AuditTokenRevoked, Target: admin, Detail: t.Name})
+	logger(r).Info("API token is revoked.", "name", t.Name)
+
+	// the row is swapped with nothing.
+	components.NotiToast(t.Name+" is revoked.").Render(r.Context(), w)
+}
+
+// renderTokens renders the api tokens of the logged in admin, with the newToken if it's just created.
+func (h *Handler) renderTokens(w http.ResponseWriter, r *http.Request, newToken string) {
+	tokens, err := h.Tokens.List(auth.AdminFromContext(r.Context()))
+	if err != nil {
+		logger(r).Error("listing the api tokens gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	t := h.CSRF.Generate(w, "/tokens", h.Sessions.Token(r.Context()))
+	layout.TokensDashboard(tokens, newToken, t).Render(r.Context(), w)
+}
@@ -0,0 +1,529 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/web/components"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// pendingLoginTimeout is the time the second step of the login has to be done in after the password.
+const pendingLoginTimeout = 5 * time.Minute
+
+// startSecondStep asks the two-factor code of the admin whose password is verified,
+// or makes them set it up when it's required and they don't have it yet.
+func (h *Handler) startSecondStep(w http.ResponseWriter, r *http.Request, admin database.Admin, passkeys, rememberMe bool) {
+	h.Sessions.Put(r.Context(), utils.PendingAdminField, admin.Username)
+	h.Sessions.Put(r.Context(), utils.PendingUntilField, time.Now().Add(pendingLoginTimeout))
+	h.Sessions.Put(r.Context(), utils.PendingRememberField, rememberMe)
+
+	t := h.CSRF.Generate(w, "/login", h.Sessions.Token(r.Context()))
+	if admin.TOTPEnabled() || passkeys {
+		layout.LoginTOTPForm(t, admin.TOTPEnabled(), passkeys, false).Render(r.Context(), w)
+		return
+	}
+
+	secret, err := h.setupSecret(r.Context())
+	if err != nil {
+		logger(r).Error("making the two-factor secret gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	uri := utils.TOTPURI(config.Get().WebHost, admin.Username, secret)
+	layout.LoginTOTPEnrollForm(t, uri, admin.Username, secret, false).Render(r.Context(), w)
+}
+
+// loginTOTPPOSTHTMX is the second step of the login, verifying the two-factor code or a recovery
+// code, or setting up the two-factor authentication with its first code.
+func (h *Handler) loginTOTPPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	addr := clientip.FromRequest(r)
+	if !addr.IsValid() {
+		logger(r).Error("IP not found to log error.")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	ip := addr.String()
+
+	name := h.pendingAdmin(r.Context())
+	if name == "" {
+		logger(r).Warn("Two-factor attempt without a pending login.")
+		h.clearPending(r.Context())
+		w.Header().Set("HX-Redirect", "/login")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	rememberMe := h.Sessions.GetBool(r.Context(), utils.PendingRememberField)
+
+	// new token for error form.
+	t := h.CSRF.Generate(w, "/login", h.Sessions.Token(r.Context()))
+
+	admin, err := h.Admins.Get(name)
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	until, err := h.Lockouts.Check(database.UserKey(name), database.IPKey(ip))
+	if err != nil {
+		logger(r).Error("checking the lockouts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !until.IsZero() {
+		logger(r).Warn("Two-factor attempt while locked out.")
+		h.renderSecondStep(w, r, t, admin, true)
+		components.ErrorToast("Too many failed attempts, try again in "+time.Until(until).Round(time.Second).String()+".").Render(r.Context(), w)
+		return
+	}
+
+	code := strings.TrimSpace(r.FormValue("code"))
+
+	if !admin.TOTPEnabled() {
+		passkeys, err := h.hasPasskeys(name)
+		if err != nil {
+			logger(r).Error("listing the passkeys gone wrong.", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		// only the passkeys can be used.
+		if passkeys {
+			h.renderSecondStep(w, r, t, admin, true)
+			return
+		}
+
+		// the requirement is turned off by a reload in the middle of the login.
+		if !config.Get().RequireTOTP {
+			h.logIn(r, name, ip, rememberMe)
+			h.redirectAfterLogin(w, r)
+			return
+		}
+
+		secret := h.Sessions.GetString(r.Context(), utils.TOTPSecretField)
+		step, ok := utils.ValidTOTP(secret, code, time.Now())
+		if secret == "" || !ok {
+			logger(r).Warn("Two-factor setup attempt with wrong code.")
+			h.loginFailed("totp", name, ip)
+			h.renderSecondStep(w, r, t, admin, true)
+			return
+		}
+
+		codes, err := h.enableTOTP(name, ip, secret, step)
+		if err != nil {
+			logger(r).Error("enabling the two-factor authentication gone wrong.", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		h.logIn(r, name, ip, rememberMe)
+		layout.LoginRecoveryCodes(codes).Render(r.Context(), w)
+		return
+	}
+
+	recovery, err := h.verifyTOTP(admin, ip, code)
+	if wrongCode(err) {
+		logger(r).Warn("Two-factor attempt with wrong code.")
+		h.loginFailed("totp", name, ip)
+		h.renderSecondStep(w, r, t, admin, true)
+		return
+	}
+	if err != nil {
+		logger(r).Error("verifying the two-factor code gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if recovery {
+		logger(r).Warn("Logged in with a recovery code.", "admin", name)
+	}
+
+	h.logIn(r, name, ip, rememberMe)
+	h.redirectAfterLogin(w, r)
+}
+
+// renderSecondStep renders the form of the second step of the login again.
+func (h *Handler) renderSecondStep(w http.ResponseWriter, r *http.Request, t string, admin database.Admin, hasError bool) {
+	passkeys, err := h.hasPasskeys(admin.Username)
+	if err != nil {
+		logger(r).Error("listing the passkeys gone wrong.", "err", err)
+	}
+	if admin.TOTPEnabled() || passkeys {
+		layout.LoginTOTPForm(t, admin.TOTPEnabled(), passkeys, hasError).Render(r.Context(), w)
+		return
+	}
+	secret := h.Sessions.GetString(r.Context(), utils.TOTPSecretField)
+	uri := utils.TOTPURI(config.Get().WebHost, admin.Username, secret)
+	layout.LoginTOTPEnrollForm(t, uri, admin.Username, secret, hasError).Render(r.Context(), w)
+}
+
+// pendingAdmin returns the admin of the login waiting for the second step, empty if there's none
+// or it's too late.
+func (h *Handler) pendingAdmin(ctx context.Context) string {
+	name := h.Sessions.GetString(ctx, utils.PendingAdminField)
+	if name == "" || time.Now().After(h.Sessions.GetTime(ctx, utils.PendingUntilField)) {
+		return ""
+	}
+	return name
+}
+
+// clearPending forgets the login waiting for the second step.
+func (h *Handler) clearPending(ctx context.Context) {
+	h.Sessions.Remove(ctx, utils.PendingAdminField)
+	h.Sessions.Remove(ctx, utils.PendingUntilField)
+	h.Sessions.Remove(ctx, utils.PendingRememberField)
+	h.Sessions.Remove(ctx, utils.TOTPSecretField)
+}
+
+// setupSecret returns the two-factor secret being set up in the session, making a new one if
+// there's none, so the QR code stays the same while the admin is setting it up.
+func (h *Handler) setupSecret(ctx context.Context) (string, error) {
+	secret := h.Sessions.GetString(ctx, utils.TOTPSecretField)
+	if secret != "" {
+		return secret, nil
+	}
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	h.Sessions.Put(ctx, utils.TOTPSecretField, secret)
+	return secret, nil
+}
+
+// enableTOTP saves the secret whose first code of the step is verified, returning the new recovery codes.
+func (h *Handler) enableTOTP(name, ip, secret string, step int64) ([]string, error) {
+	codes, hashes, err := utils.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = h.Admins.SetTOTP(name, secret, hashes)
+	if err != nil {
+		return nil, err
+	}
+	err = h.Admins.UseTOTPStep(name, step)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Two-factor authentication is enabled.", "admin", name)
+	h.audit(database.AuditEntry{Actor: name, IP: ip, Action: database.AuditTOTPEnabled, Target: name})
+	return codes, nil
+}
+
+// verifyTOTP checks the code of the authenticator app, or a recovery code which can be used only once.
+// The errors of the wrong codes are reported by the wrongCode.
+func (h *Handler) verifyTOTP(admin database.Admin, ip, code string) (recovery bool, err error) {
+	if step, ok := utils.ValidTOTP(admin.TOTPSecret, code, time.Now()); ok {
+		return false, h.Admins.UseTOTPStep(admin.Username, step)
+	}
+
+	err = h.Admins.UseRecoveryCode(admin.Username, utils.HashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+
+	cfg := config.Get()
+	h.audit(database.AuditEntry{Actor: admin.Username, IP: ip, Action: database.AuditRecoveryCodeUsed, Target: admin.Username})
+	h.Notifier.Notify(cfg.WebHost+" - "+admin.Username+" used a recovery code", admin.Username+" used a two-factor recovery code on "+cfg.WebHostIP+" from "+ip, 9)
+	return true, nil
+}
+
+// wrongCode reports whether the err of the verifyTOTP is because of a wrong or a reused code.
+func wrongCode(err error) bool {
+	return err == database.ErrTOTPReused || err == database.ErrRecoveryCode
+}
+
+// audit records the entry to the audit log, the failures are only logged.
+func (h *Handler) audit(e database.AuditEntry) {
+	err := h.Audit.Record(e)
+	if err != nil {
+		slog.Error("recording the audit log gone wrong.", "err", err)
+	}
+}
+
+// totpGETHTMX shows the two-factor authentication of the logged in admin.
+func (h *Handler) totpGETHTMX(w http.ResponseWriter, r *http.Request) {
+	admin, err := h.Admins.Get(h.Sessions.GetString(r.Context(), utils.AdminField))
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		// the admins sent here by requireTOTP can't use the dashboard yet.
+		enroll, err := h.mustEnroll(admin)
+		if err != nil {
+			logger(r).Error("listing the passkeys gone wrong.", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !enroll {
+			http.Redirect(w, r, "/dashboard", http.StatusMovedPermanently)
+			return
+		}
+
+		data, err := h.totpData(w, r, admin, nil, false)
+		if err != nil {
+			logger(r).Error("making the two-factor secret gone wrong.", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		layout.TOTPPage(data).Render(r.Context(), w)
+		return
+	}
+	h.renderTOTP(w, r, admin, nil, false)
+}
+
+// totpEnablePOSTHTMX enables the two-factor authentication of the logged in admin with the
+// first code of the secret being set up.
+func (h *Handler) totpEnablePOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	ip := clientip.FromRequest(r).String()
+	admin, err := h.Admins.Get(h.Sessions.GetString(r.Context(), utils.AdminField))
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if admin.TOTPEnabled() {
+		h.renderTOTP(w, r, admin, nil, false)
+		return
+	}
+
+	secret := h.Sessions.GetString(r.Context(), utils.TOTPSecretField)
+	step, ok := utils.ValidTOTP(secret, strings.TrimSpace(r.FormValue("code")), time.Now())
+	if secret == "" || !ok {
+		h.renderTOTP(w, r, admin, nil, true)
+		return
+	}
+
+	codes, err := h.enableTOTP(admin.Username, ip, secret, step)
+	if err != nil {
+		logger(r).Error("enabling the two-factor authentication gone wrong.", "err", err)
+		components.ErrorToast("Enabling the two-factor authentication failed.").Render(r.Context(), w)
+		return
+	}
+	h.Sessions.Remove(r.Context(), utils.TOTPSecretField)
+
+	admin, err = h.Admins.Get(admin.Username)
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.renderTOTP(w, r, admin, codes, false)
+	components.NotiToast("Two-factor authentication is enabled.").Render(r.Context(), w)
+}
+
+// totpRecoveryPOSTHTMX replaces the recovery codes of the logged in admin with new ones.
+func (h *Handler) totpRecoveryPOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	ip := clientip.FromRequest(r).String()
+	admin, ok := h.verifiedAdmin(w, r, ip)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := utils.NewRecoveryCodes()
+	if err == nil {
+		err = h.Admins.SetRecoveryCodes(admin.Username, hashes)
+	}
+	if err != nil {
+		logger(r).Error("making the recovery codes gone wrong.", "err", err)
+		components.ErrorToast("Making new recovery codes failed.").Render(r.Context(), w)
+		return
+	}
+	admin.RecoveryCodes = hashes
+	h.audit(database.AuditEntry{Actor: admin.Username, IP: ip, Action: database.AuditRecoveryCodes, Target: admin.Username})
+
+	h.renderTOTP(w, r, admin, codes, false)
+	components.NotiToast("New recovery codes are made.").Render(r.Context(), w)
+}
+
+// totpDisablePOSTHTMX disables the two-factor authentication of the logged in admin,
+// unless it's required by the configuration.
+func (h *Handler) totpDisablePOSTHTMX(w http.ResponseWriter, r *http.Request) {
+	if config.Get().RequireTOTP {
+		components.ErrorToast("Two-factor authentication is required for every admin.").Render(r.Context(), w)
+		return
+	}
+
+	ip := clientip.FromRequest(r).String()
+	admin, ok := h.verifiedAdmin(w, r, ip)
+	if !ok {
+		return
+	}
+
+	err := h.Admins.SetTOTP(admin.Username, "", nil)
+	if err != nil {
+		logger(r).Error("disabling the two-factor authentication gone wrong.", "err", err)
+		components.ErrorToast("Disabling the two-factor authentication failed.").Render(r.Context(), w)
+		return
+	}
+	logger(r).Info("Two-factor authentication is disabled.", "admin", admin.Username)
+	h.audit(database.AuditEntry{Actor: admin.Username, IP: ip, Action: database.AuditTOTPDisabled, Target: admin.Username})
+
+	admin.TOTPSecret = ""
+	h.renderTOTP(w, r, admin, nil, false)
+	components.NotiToast("Two-factor authentication is disabled.").Render(r.Context(), w)
+}
+
+// verifiedAdmin returns the logged in admin after verifying the two-factor code of the request,
+// the response is already written when it's not ok.
+func (h *Handler) verifiedAdmin(w http.ResponseWriter, r *http.Request, ip string) (database.Admin, bool) {
+	admin, err := h.Admins.Get(h.Sessions.GetString(r.Context(), utils.AdminField))
+	if err != nil {
+		logger(r).Error("getting the admin gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return admin, false
+	}
+	if !admin.TOTPEnabled() {
+		h.renderTOTP(w, r, admin, nil, false)
+		return admin, false
+	}
+
+	// the codes can't be guessed here either, the same lockouts as the login are used.
+	until, err := h.Lockouts.Check(database.UserKey(admin.Username), database.IPKey(ip))
+	if err != nil {
+		logger(r).Error("checking the lockouts gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return admin, false
+	}
+	if !until.IsZero() {
+		logger(r).Warn("Two-factor attempt while locked out.")
+		h.renderTOTP(w, r, admin, nil, true)
+		components.ErrorToast("Too many failed attempts, try again in "+time.Until(until).Round(time.Second).String()+".").Render(r.Context(), w)
+		return admin, false
+	}
+
+	_, err = h.verifyTOTP(admin, ip, strings.TrimSpace(r.FormValue("code")))
+	if wrongCode(err) {
+		logger(r).Warn("Two-factor attempt with wrong code.")
+		h.loginFailed("totp", admin.Username, ip)
+		h.renderTOTP(w, r, admin, nil, true)
+		return admin, false
+	}
+	if err != nil {
+		logger(r).Error("verifying the two-factor code gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return admin, false
+	}
+	return admin, true
+}
+
+// renderTOTP renders the two-factor authentication dashboard of the admin, with the recovery
+// codes if they're just made.
+func (h *Handler) renderTOTP(w http.ResponseWriter, r *http.Request, admin database.Admin, codes []string, hasError bool) {
+	data, err := h.totpData(w, r, admin, codes, hasError)
+	if err != nil {
+		logger(r).Error("making the two-factor secret gone wrong.", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	layout.TOTPDashboard(data).Render(r.Context(), w)
+}
+
+// totpData is the two-factor authentication dashboard of the admin, with a new secret to be
+// set up when it's not enabled.
+func (h *Handler) totpData(w http.ResponseWriter, r *http.Request, admin database.Admin, codes []string, hasError bool) (layout.TOTPData, error) {
+	data := layout.TOTPData{
+		Account:   admin.Username,
+		Enabled:   admin.TOTPEnabled(),
+		Required:  config.Get().RequireTOTP,
+		Recovery:  len(admin.RecoveryCodes),
+		CSRFToken: h.CSRF.Generate(w, "/totp", h.Sessions.Token(r.Context())),
+		NewCodes:  codes,
+		HasError:  hasError,
+	}
+
+	if !data.Enabled {
+		secret, err := h.setupSecret(r.Context())
+		if err != nil {
+			return data, err
+		}
+		data.Secret = secret
+		data.URI = utils.TOTPURI(config.Get().WebHost, admin.Username, secret)
+	}
+	return data, nil
+}
+
+// mustEnroll reports whether the admin has to set up the two-factor authentication before using
+// the panel, the passkeys are enough like at the login.
+func (h *Handler) mustEnroll(admin database.Admin) (bool, error) {
+	if !config.Get().RequireTOTP || admin.TOTPEnabled() {
+		return false, nil
+	}
+	passkeys, err := h.hasPasskeys(admin.Username)
+	return !passkeys, err
+}
+
+// totpExempt are the pages the admins without the two-factor authentication can still use
+// when it's required, to set it up or to log out.
+var totpExempt = []string{"/logout", "/totp", "/passkeys"}
+
+// withoutTOTP reports whether the logged in admin of the r has to set up the two-factor authentication
+// before being let in, the api tokens and the totpExempt pages don't need it.
+func (h *Handler) withoutTOTP(r *http.Request) (bool, error) {
+	if auth.FromToken(r.Context()) || !config.Get().RequireTOTP {
+		return false, nil
+	}
+	for _, p := range totpExempt {
+		if r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/") {
+			return false, nil
+		}
+	}
+
+	admin, err := h.Admins.Get(auth.AdminFromContext(r.Context()))
+	if err != nil {
+		return false, err
+	}
+	return h.mustEnroll(admin)
+}
+
+// requireTOTP sends the logged in admins to set up the two-factor authentication while it's
+// required and they don't have it, as when the requirement is turned on after their login.
+func (h *Handler) requireTOTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enroll, err := h.withoutTOTP(r)
+		if err != nil {
+			logger(r).Error("checking the two-factor authentication of the admin gone wrong.", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !enroll {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger(r).Info("Admin without the required two-factor authentication redirecting to its setup.")
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", "/totp")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, "/totp", http.StatusFound)
+	})
+}
+
+// apiRequireTOTP is the requireTOTP of the api, refusing the sessions of the admins without the
+// required two-factor authentication with an apiError.
+func (h *Handler) apiRequireTOTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enroll, err := h.withoutTOTP(r)
+		if err != nil {
+			logger(r).Error("checking the two-factor authentication of the admin gone wrong.", "err", err)
+			apiFail(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if enroll {
+			apiFail(w, http.StatusForbidden, "Set up the two-factor authentication in the panel first.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/go-chi/httprate"
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+	"github.com/htetmyatthar/lothone/middleware/secure"
+	"github.com/htetmyatthar/lothone/web/layout"
+)
+
+// trustedOnly lets only the trusted ips use the routes when the trusted routes of the configuration
+// are the routes, the others are served by the forbidden. The configuration is read on every
+// request, so the reloads apply at once.
+func (h *Handler) trustedOnly(routes string, forbidden http.HandlerFunc) func(next http.Handler) http.Handler {
+	allowed := func(ip netip.Addr) bool {
+		c := config.Get()
+		return c.TrustedRoutes != routes || c.Trusted(ip)
+	}
+	return clientip.Allow(allowed, forbidden)
+}
+
+// untrustedPage tells the client that its ip address is not allowed.
+func untrustedPage(w http.ResponseWriter, r *http.Request) {
+	ip := clientip.FromRequest(r)
+	w.WriteHeader(http.StatusForbidden)
+	layout.ForbiddenPage(ip.String()).Render(r.Context(), w)
+}
+
+// apiUntrusted is the untrustedPage of the api.
+func apiUntrusted(w http.ResponseWriter, r *http.Request) {
+	apiFail(w, http.StatusForbidden, "Your ip address is not allowed to use the api.")
+}
+
+// ResolveClientIP is the resolver of the clientip.Middleware with the trusted proxies and the client ip headers
+// of the configuration.
+func ResolveClientIP(r *http.Request) netip.Addr {
+	c := config.Get()
+	return clientip.Resolve(r, c.TrustedProxy, c.ClientIPHeaders)
+}
+
+// SecurityOptions are the options of the secure.Middleware from the configuration.
+func SecurityOptions() secure.Options {
+	c := config.Get()
+	return secure.Options{
+		Off:        !c.SecurityHeaders,
+		Sources:    c.CSPSources,
+		ReportOnly: c.CSPReportOnly,
+		HSTS:       time.Duration(c.HSTSDays) * 24 * time.Hour,
+	}
+}
+
+// limitByClientIP limits the requests of every client ip to the n in the window, the ipv6 ones by their /64 range.
+func limitByClientIP(n int, window time.Duration) func(next http.Handler) http.Handler {
+	return httprate.LimitBy(n, window, func(r *http.Request) (string, error) {
+		return httprate.CanonicalizeIP(clientip.FromRequest(r).String()), nil
+	})
+}
@@ -13,14 +13,16 @@ package handler
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
 
 	json "github.com/goccy/go-json"
 	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/logging"
 	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
 )
 
 // for differentiating the type of the request.
@@ -67,7 +69,7 @@ func (m *muxHandler) AddHandler(handler http.HandlerFunc) {
 func (m *muxHandler) CreateHandler() http.HandlerFunc {
 
 	if m.selector == nil {
-		log.Println("Using default selector for handling requests.")
+		slog.Debug("Using default selector for handling requests.")
 		m.selector = defaultSelector
 	}
 
@@ -96,16 +98,17 @@ func defaultSelector(req *http.Request) int {
 
 // GenerateURI generates a usable URI for v2box application.
 func GenerateURI(data utils.Client, t utils.AccountType) (key string, remarks string, err error) {
+	cfg := config.Get()
 	key, remarks, err = "", "", errors.New("Not implemented")
 
 	if t == utils.VmessAccountType {
 		key, err = utils.GenerateVmessURI(data)
-		remarks = strings.Split(*config.WebHost, ".")[0] + " " + data.Id[len(data.Id)-4:]
+		remarks = strings.Split(cfg.WebHost, ".")[0] + " " + data.Id[len(data.Id)-4:]
 		return
 
 	} else if t == utils.ShadowsocksAccountType {
 		key, err = utils.GenerateShadowsocksURI(data)
-		remarks = strings.Split(*config.WebHost, ".")[0] + " " + data.Password[len(data.Password)-4:]
+		remarks = strings.Split(cfg.WebHost, ".")[0] + " " + data.Password[len(data.Password)-4:]
 		return
 	}
 	return
@@ -113,16 +116,17 @@ func GenerateURI(data utils.Client, t utils.AccountType) (key string, remarks st
 
 // GenerateURI generates a usable device id locked URI for v2box application.
 func GenerateLockedURI(data utils.Client, t utils.AccountType) (key string, remarks string, err error) {
+	cfg := config.Get()
 	key, remarks, err = "", "", errors.New("Not implemented")
 
 	if t == utils.VmessAccountType {
 		key, err = utils.GenerateVmessLockedURI(data)
-		remarks = strings.Split(*config.WebHost, ".")[0] + " " + data.Id[len(data.Id)-4:]
+		remarks = strings.Split(cfg.WebHost, ".")[0] + " " + data.Id[len(data.Id)-4:]
 		return
 
 	} else if t == utils.ShadowsocksAccountType {
 		key, err = utils.GenerateShadowsocksLockedURI(data)
-		remarks = strings.Split(*config.WebHost, ".")[0] + " " + data.Password[len(data.Password)-4:]
+		remarks = strings.Split(cfg.WebHost, ".")[0] + " " + data.Password[len(data.Password)-4:]
 		return
 	}
 	return
@@ -146,7 +150,7 @@ func loadAndGetUsers(f string) ([]utils.Client, error) {
 	// load the users file.
 	userData, err := os.ReadFile(f)
 	if err != nil {
-		log.Println("Error reading user data file: ", err)
+		slog.Error("Error reading user data file.", "err", err)
 		return nil, errors.New("Internal Server Error.")
 	}
 
@@ -154,7 +158,7 @@ func loadAndGetUsers(f string) ([]utils.Client, error) {
 	var userResult map[string]json.RawMessage
 	err = json.Unmarshal(userData, &userResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in users:", err)
+		slog.Error("Error unmarshalling JSON to map in users.", "err", err)
 		return nil, errors.New("Internal Server Error.")
 	}
 
@@ -162,8 +166,21 @@ func loadAndGetUsers(f string) ([]utils.Client, error) {
 	var users []utils.Client
 	err = json.Unmarshal(userResult["clients"], &users)
 	if err != nil {
-		log.Println("Error unmarshalling 'users': ", err)
+		slog.Error("Error unmarshalling 'users'.", "err", err)
 		return nil, errors.New("Internal Server Error.")
 	}
 	return users, nil
 }
+
+// logger returns the logger of the request r.
+func logger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context())
+}
+
+// logAdmin adds the logged in admin to the logger of the request, it must be used after the auth.
+func logAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.With(r, "admin", auth.AdminFromContext(r.Context()))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,269 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/auth"
+	"golang.org/x/term"
+)
+
+// seedAdmins adds the admins in the form of username~password to the store as owners, unless
+// they already exist there. Existing admins are changed with the admin commands only.
+func seedAdmins(store *database.AdminStore, admins []string) error {
+	for _, admin := range admins {
+		username, password, _ := strings.Cut(admin, "~")
+		_, err := store.Get(username)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, database.ErrAdminNotFound) {
+			return err
+		}
+
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		err = store.Add(username, hash, string(auth.RoleOwner))
+		if err != nil {
+			return err
+		}
+		slog.Info("Admin is added from the configuration.", "admin", username)
+	}
+	return nil
+}
+
+// inConfig tells if the admin with the username is in the admins setting or the admins file of the c.
+func inConfig(c *config.Config, username string) bool {
+	for _, admin := range c.Admins {
+		name, _, _ := strings.Cut(admin, "~")
+		if name == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Admin commands of the command line.
+const (
+	AdminAdd    = "add"
+	AdminRemove = "remove"
+	AdminReset  = "reset"
+	AdminList   = "list"
+	// AdminTOTPReset disables the two-factor authentication and removes the passkeys of the
+	// admin who lost the authenticator app and the recovery codes.
+	AdminTOTPReset = "reset-totp"
+	AdminSetRole   = "set-role"
+)
+
+// AuditActor is the actor of the audit log for the commands.
+const AuditActor = "command line"
+
+// AdminCommand adds, removes, resets the password or the two-factor authentication, sets the role of the
+// admin with the username or lists the admins in the database of the c. The passwords are read from the standard input.
+// The role is used by the AdminAdd and the AdminSetRole only.
+func AdminCommand(c *config.Config, command, username, roleName string) error {
+	role, err := auth.ParseRole(roleName)
+	if err != nil && (command == AdminAdd || command == AdminSetRole) {
+		return err
+	}
+	// the admins of the configuration are added back as owners by the next start or reload.
+	if command == AdminRemove && inConfig(c, username) {
+		return fmt.Errorf("admin %q is in the admins or the admins_file setting, remove it from there first", username)
+	}
+
+	db, err := database.Open(c.DatabasePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	store, err := database.NewAdminStore(db)
+	if err != nil {
+		return err
+	}
+	passkeys, err := database.NewPasskeyStore(db)
+	if err != nil {
+		return err
+	}
+	tokens, err := database.NewTokenStore(db)
+	if err != nil {
+		return err
+	}
+	// the expired sessions are cleaned up by the running panel.
+	sessions, err := database.NewBoltSessionStore(db, 0)
+	if err != nil {
+		return err
+	}
+	audit, err := database.NewAuditStore(db)
+	if err != nil {
+		return err
+	}
+	// record is the audit of the command, done by whoever can run the panel.
+	record := func(action, detail string) error {
+		return audit.Record(database.AuditEntry{Actor: AuditActor, Action: action, Target: username, Detail: detail})
+	}
+
+	switch command {
+	case AdminList:
+		admins, err := store.All()
+		if err != nil {
+			return err
+		}
+		for _, a := range admins {
+			fmt.Println(a.Username, auth.SavedRole(a.Role))
+		}
+		return nil
+
+	case AdminSetRole:
+		err = store.SetRole(username, string(role))
+		if err != nil {
+			return err
+		}
+		err = record(database.AuditRoleChanged, string(role))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Admin is now %s: %s\n", role, username)
+		return nil
+
+	case AdminRemove:
+		err = store.Remove(username)
+		if err != nil {
+			return err
+		}
+		_, err = passkeys.RemoveAll(username)
+		if err != nil {
+			return err
+		}
+		_, err = tokens.RemoveAll(username)
+		if err != nil {
+			return err
+		}
+		_, err = sessions.RevokeAll(username, "")
+		if err != nil {
+			return err
+		}
+		err = record(database.AuditAdminRemoved, "")
+		if err != nil {
+			return err
+		}
+		fmt.Println("Admin is removed:", username)
+		return nil
+
+	case AdminTOTPReset:
+		err = store.SetTOTP(username, "", nil)
+		if err != nil {
+			return err
+		}
+		n, err := passkeys.RemoveAll(username)
+		if err != nil {
+			return err
+		}
+		err = record(database.AuditTOTPReset, strconv.Itoa(n)+" passkeys")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Two-factor authentication and %d passkey(s) are reset, it's set up again at the next login if it's required: %s\n", n, username)
+		return nil
+
+	case AdminAdd, AdminReset:
+		password, err := readPassword(os.Stdin)
+		if err != nil {
+			return err
+		}
+		err = utils.CheckCredentials(username, password)
+		if err != nil {
+			return err
+		}
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		if command == AdminAdd {
+			err = store.Add(username, hash, string(role))
+			if err == nil {
+				err = record(database.AuditAdminAdded, string(role))
+			}
+		} else {
+			err = store.SetHash(username, hash)
+			if err == nil {
+				err = record(database.AuditPasswordReset, "")
+			}
+		}
+		if err != nil {
+			return err
+		}
+		// the old password is logged out of everywhere.
+		if command == AdminReset {
+			_, err = sessions.RevokeAll(username, "")
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Println("Admin is saved:", username)
+		return nil
+	}
+	return fmt.Errorf("unknown admin command %q", command)
+}
+
+// readPassword reads the password from the terminal without echoing it, asking for it twice,
+// or reads the first line if the in is not a terminal, eg. piped from a file.
+func readPassword(in *os.File) (string, error) {
+	if !term.IsTerminal(int(in.Fd())) {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Password again: ")
+	again, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if string(password) != string(again) {
+		return "", errors.New("passwords don't match")
+	}
+	return string(password), nil
+}
+
+// AuditVerify checks the hash chain of the audit log in the database of the c.
+func AuditVerify(c *config.Config) error {
+	db, err := database.Open(c.DatabasePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	audit, err := database.NewAuditStore(db)
+	if err != nil {
+		return err
+	}
+	n, err := audit.Verify()
+	if err != nil {
+		return fmt.Errorf("the audit log is tampered with after %d entries: %v", n, err)
+	}
+	fmt.Printf("The audit log of %d entries is intact.\n", n)
+	return nil
+}
@@ -0,0 +1,136 @@
+// The application is the whole server manager wired together, so the parts of it are made
+// once by the main and given to the handlers instead of being made when the packages load.
+package app
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/database"
+	"github.com/htetmyatthar/lothone/internal/utils"
+	"github.com/htetmyatthar/lothone/middleware/csrf"
+	"github.com/htetmyatthar/lothone/middleware/session"
+)
+
+// App holds the dependencies of the handlers.
+type App struct {
+	DB       *database.DB
+	Sessions *scs.SessionManager
+	CSRF     *csrf.CSRF
+	Admins   *database.AdminStore
+	Lockouts *database.LockoutStore
+	Audit    *database.AuditStore
+	Passkeys *database.PasskeyStore
+	Owners   *database.OwnerStore
+	Credits  *database.CreditStore
+	Tokens   *database.TokenStore
+	// Suspended are the accounts taken off the servers by the api.
+	Suspended *database.SuspendedStore
+	Notifier  *utils.Notifier
+	// SessionStore keeps the sessions of the Sessions and where they are logged in from.
+	SessionStore *database.BoltSessionStore
+}
+
+// sessionCleanupInterval is how often the expired sessions are removed from the database.
+const sessionCleanupInterval = 5 * time.Minute
+
+// New builds the App from the c, which must be the running configuration.
+// The parts that can be changed by the configuration reloads are registered to follow them.
+func New(c *config.Config) (a *App, err error) {
+	db, err := database.Open(c.DatabasePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			db.Close()
+		}
+	}()
+
+	a = &App{
+		DB:       db,
+		Notifier: utils.NewNotifier(),
+	}
+
+	a.Admins, err = database.NewAdminStore(db)
+	if err != nil {
+		return nil, err
+	}
+	err = seedAdmins(a.Admins, c.Admins)
+	if err != nil {
+		return nil, err
+	}
+	usernames, err := a.Admins.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(usernames) == 0 {
+		return nil, errors.New("There's no admin to log in with, add one using -admin-add or the admins setting.")
+	}
+
+	a.Lockouts, err = database.NewLockoutStore(db)
+	if err != nil {
+		return nil, err
+	}
+	a.Audit, err = database.NewAuditStore(db)
+	if err != nil {
+		return nil, err
+	}
+	a.Passkeys, err = database.NewPasskeyStore(db)
+	if err != nil {
+		return nil, err
+	}
+	a.Owners, err = database.NewOwnerStore(db)
+	if err != nil {
+		return nil, err
+	}
+	a.Credits, err = database.NewCreditStore(db)
+	if err != nil {
+		return nil, err
+	}
+	a.Tokens, err = database.NewTokenStore(db)
+	if err != nil {
+		return nil, err
+	}
+	a.Suspended, err = database.NewSuspendedStore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	a.SessionStore, err = database.NewBoltSessionStore(db, sessionCleanupInterval)
+	if err != nil {
+		return nil, err
+	}
+	a.Sessions = session.New(c, a.SessionStore)
+	csrfKeys, err := database.NewCSRFKeyStore(db)
+	if err != nil {
+		return nil, err
+	}
+	a.CSRF, err = csrf.New(a.Sessions, csrfKeys, time.Duration(c.CSRFRotation)*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// new admins of the reloaded configuration are added, the existing ones are kept as they are, which
+	// the config.Diff tells. The csrf rotation and the session durations follow the reload.
+	config.OnReload(func(old, c *config.Config) (func(), error) {
+		return func() {
+			err := seedAdmins(a.Admins, c.Admins)
+			if err != nil {
+				slog.Error("Error adding the admins of the reloaded configuration.", "err", err)
+			}
+			a.CSRF.SetRotation(time.Duration(c.CSRFRotation) * time.Hour)
+			session.SetTimeouts(a.Sessions, c)
+		}, nil
+	})
+	return a, nil
+}
+
+// Close releases what the App holds.
+func (a *App) Close() error {
+	a.SessionStore.StopCleanup()
+	return a.DB.Close()
+}
@@ -3,31 +3,18 @@
 package config
 
 import (
-	"flag"
 	"fmt"
-	"os"
+	"log/slog"
+	"net/netip"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/template"
 )
 
 var (
-	gotifyAPIKeys *string
-	WebHost       *string
-	WebHostRegion *string
-	WebHostIP     *string
-	Admins        *string
-	WebPort       *string
-	WebCert       *string
-	WebKey        *string
-	V2rayPort     *string
-
-	SessionDuration *int
-	LockOutDuration *int
-
-	GotifyServer  *string
-	GotifyAPIKeys []string
-
-	TrustedIPs    []string
-	TrustedIPsMap map[string]struct{} = make(map[string]struct{}, 20)
+	// current is the configuration the panel is running with.
+	current atomic.Pointer[Config]
 
 	TemplateBasePath string = "web/templates/"
 )
@@ -59,46 +46,210 @@ const (
 	Version string = "v1.0.0"
 )
 
-func init() {
-	WebHost = flag.String("hostname", "127.0.0.1", "fully qualify domain name of the server")
-	WebHostRegion = flag.String("region", "127.0.0.1", "geo location region of the physical server")
-	WebHostIP = flag.String("hostip", "127.0.0.1", "ipv4 or ipv6 address of the server")
-	WebPort = flag.String("webport", ":8888", "port number of the control panel web server")
-	WebCert = flag.String("webcert", "localhost.crt", "ssl/tls certificate for the web server")
-	WebKey = flag.String("webkey", "localhost.key", "ssl/tls certificate key for the web server")
+// Config is the whole configuration of the server manager.
+// It's loaded from the configuration file, the environment variables and the flags in that order.
+type Config struct {
+	// Path is the configuration file the config is loaded from, empty if there's none.
+	Path string `yaml:"-"`
+
+	WebHost       string `yaml:"hostname"`   // fully qualify domain name of the server.
+	WebHostRegion string `yaml:"region"`     // geo location region of the physical server.
+	WebHostIP     string `yaml:"host_ip"`    // ipv4 or ipv6 address of the server.
+	WebPort       string `yaml:"web_port"`   // port of the control panel web server, in the form of ":8888".
+	WebCert       string `yaml:"web_cert"`   // ssl/tls certificate for the web server.
+	WebKey        string `yaml:"web_key"`    // ssl/tls certificate key for the web server.
+	AdminMail     string `yaml:"admin_mail"` // mail used for requesting the certificates.
+
+	V2rayPort        string `yaml:"v2ray_port"`
+	ConfigFilePrefix string `yaml:"config_file_prefix"` // directory of the v2ray config files, with the trailing slash.
+	UserFilePrefix   string `yaml:"user_file_prefix"`   // directory of the v2ray users files, with the trailing slash.
+
+	SSTPServerURL         string `yaml:"sstp_server_url"`
+	SSTPHub               string `yaml:"sstp_hub"`
+	SSTPAdminPassword     string `yaml:"sstp_admin_password"`
+	SSTPAdminPasswordFile string `yaml:"sstp_admin_password_file"`
+
+	// DatabasePath is the file the panel keeps its persistent stores in.
+	DatabasePath string `yaml:"database"`
+
+	// Admins are the panel users in the form of username~password, added to the admins
+	// store if they don't exist there yet.
+	Admins     []string `yaml:"admins"`
+	AdminsFile string   `yaml:"admins_file"` // one username~password per line.
+
+	GotifyServer      string   `yaml:"gotify_server"`
+	GotifyAPIKeys     []string `yaml:"gotify_api_keys"`
+	GotifyAPIKeysFile string   `yaml:"gotify_api_keys_file"` // one key per line.
+
+	// TrustedIPs are the ip addresses or the cidr ranges, ipv4 or ipv6, allowed to use the
+	// TrustedRoutes. Everyone is allowed if there's none.
+	TrustedIPs []string `yaml:"trusted_ips"`
+	// TrustedRoutes are the routes limited to the TrustedIPs, TrustedPrivate or TrustedServer.
+	TrustedRoutes string `yaml:"trusted_routes"`
+	// TrustedProxies are the reverse proxies, ip addresses or cidr ranges, whose ClientIPHeaders
+	// are believed for the ip address of the client.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// ClientIPHeaders are the headers the trusted proxies give the ip address of the client with,
+	// X-Forwarded-For, X-Real-IP or CF-Connecting-IP, the first one a request has is used.
+	ClientIPHeaders []string `yaml:"client_ip_headers"`
+
+	// RemarkTemplate is the text/template the remarks of the account keys are made with.
+	// It's executed with the RemarkData.
+	RemarkTemplate string `yaml:"remark_template"`
+
+	// CSRFRotation is how often the secret key of the csrf tokens is changed in hours, never if it's 0.
+	// The tokens of the previous key are still valid for a while, so the open pages keep working.
+	CSRFRotation int `yaml:"csrf_rotation"`
+
+	// SecurityHeaders sends the Content-Security-Policy, the HSTS and the other security headers.
+	SecurityHeaders bool `yaml:"security_headers"`
+	// CSPSources are the origins other than the panel the pages load the scripts, the styles and
+	// the images from, like the cdn.
+	CSPSources []string `yaml:"csp_sources"`
+	// CSPReportOnly only reports the violations of the Content-Security-Policy to the browser console,
+	// to try the CSPSources out without breaking the pages.
+	CSPReportOnly bool `yaml:"csp_report_only"`
+	// HSTSDays is how long the browsers only use https for the panel, it's not sent if it's 0.
+	HSTSDays int `yaml:"hsts_days"`
+
+	// LogFormat is the format of the logs, text or json.
+	LogFormat string `yaml:"log_format"`
+	// LogLevel is the least important logs that are written, debug, info, warn or error.
+	LogLevel string `yaml:"log_level"`
+
+	// Metrics serves the prometheus metrics at /metrics, to the api tokens of the server:read scope.
+	Metrics bool `yaml:"metrics"`
+	// MetricsPublic serves the Metrics without the api token, to the trusted ip ranges only if the
+	// private routes are limited to them.
+	MetricsPublic bool `yaml:"metrics_public"`
+	// MetricsExpiringDays is how soon the accounts counted as expiring expire, in days.
+	MetricsExpiringDays int `yaml:"metrics_expiring_days"`
+
+	SessionDuration int `yaml:"session_duration"` // loggedin session remembered duration in minutes.
+	SessionIdle     int `yaml:"session_idle"`     // loggedin session is logged out after being unused for this long in minutes.
+	LockOutDuration int `yaml:"lockout_duration"` // locking out time for wrong password in minutes.
+
+	// MaxSessions is how many sessions an admin can be logged in with at once, the least recently
+	// used ones are logged out by the new logins. There's no limit if it's 0.
+	MaxSessions int `yaml:"max_sessions"`
+
+	// RequireTOTP makes every admin set up the two-factor authentication before using the panel.
+	RequireTOTP bool `yaml:"require_totp"`
+
+	// CreditPrices are the credits the resellers pay for 30 days of an account, in the form of
+	// protocol~credits. The protocols that are not given are free.
+	CreditPrices []string `yaml:"credit_prices"`
+
+	// remarks is the parsed RemarkTemplate.
+	remarks *template.Template
+	// trusted and proxies are the parsed TrustedIPs and TrustedProxies.
+	trusted []netip.Prefix
+	proxies []netip.Prefix
+
+	// flags are the raw values of the flags that were set on the command line,
+	// kept to apply them again on top of a reloaded configuration.
+	flags map[string]string
+}
 
-	V2rayPort = flag.String("v2rayport", "443", "port number of the v2ray proxy server")
-	Admins = flag.String("admins", "lothoneadmin~lothoneadmin0,lothoneadmin1~lothoneadmin1,h~h", "panel users with username and passwords seperated by tilde(~) and for each user seperated by comma(,)")
+// Get returns the configuration the panel is running with.
+func Get() *Config {
+	return current.Load()
+}
 
-	GotifyServer = flag.String("gotifyserver", "noti.localhost:11111", "push nofication server domain name")
-	gotifyAPIKeys = flag.String("gotifyapikeys", "somekey,somekey", "keys for using with push notification system seperated by comma(,)")
+// Set replaces the configuration the panel is running with.
+// c must be already validated.
+func Set(c *Config) {
+	current.Store(c)
+}
 
-	ipList := flag.String("trusted", "127.0.0.1,192.168.100.0", "used for preventing unwanted access to the server.")
-	SessionDuration = flag.Int("sessionduration", 10, "loggedin session remembered duration in minutes")
-	LockOutDuration = flag.Int("lockoutduration", 30, "locking out time for wrong password in minutes")
+// Credits returns the credits of an account of the protocol for the days, a started 30 days are
+// paid in part and rounded up.
+func (c *Config) Credits(protocol string, days int) int {
+	if days <= 0 {
+		return 0
+	}
+	for _, price := range c.CreditPrices {
+		p, credits, _ := strings.Cut(price, "~")
+		if p != protocol {
+			continue
+		}
+		perMonth, _ := strconv.Atoi(credits)
+		return (perMonth*days + 29) / 30
+	}
+	return 0
+}
 
-	versionFlag := flag.Bool("version", false, "Show verion number.")
-	installFlag := flag.Bool("install", false, "Install server manager, get cert using certbot, setup vpn protocols")
+// The routes the TrustedIPs are limited to.
+const (
+	TrustedPrivate = "private" // every page of the logged in admins and the api.
+	TrustedServer  = "server"  // only the /server/ pages.
+)
 
-	// parse the flags
-	flag.Parse()
+// Trusted reports whether the ip is one of the TrustedIPs, every ip is if there's none.
+func (c *Config) Trusted(ip netip.Addr) bool {
+	return len(c.trusted) == 0 || containsIP(c.trusted, ip)
+}
 
-	TrustedIPs = strings.Split(*ipList, ",")
-	for _, ip := range TrustedIPs {
-		TrustedIPsMap[ip] = struct{}{}
+// TrustedProxy reports whether the ip is one of the TrustedProxies.
+func (c *Config) TrustedProxy(ip netip.Addr) bool {
+	return containsIP(c.proxies, ip)
+}
+
+func containsIP(prefixes []netip.Prefix, ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Check if the version flag was set
-	if *versionFlag {
-		fmt.Printf("LoThone V2ray VPN server Panel.%s\nVmess protocol.\n", Version)
-		os.Exit(0) // Exit after showing the version
+// parsePrefixes parses the ip addresses and the cidr ranges, the addresses are the ranges of themselves only.
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if ip, err := netip.ParseAddr(s); err == nil {
+			ip = ip.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither an ip address nor a cidr range", s)
+		}
+		// the ipv4-mapped ranges are matched as the ipv4 ones, the ips are unmapped.
+		if p.Addr().Is4In6() && p.Bits() >= 96 {
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		prefixes = append(prefixes, p.Masked())
 	}
+	return prefixes, nil
+}
+
+// RemarkData is what the RemarkTemplate is executed with.
+type RemarkData struct {
+	ExpireDate string
+	Host       string // first label of the hostname.
+	Region     string
+	Suffix     string // last 4 characters of the account id.
+}
 
-	if *installFlag {
-		// install all the things.
-		Install()
-		os.Exit(0) // Exit after installing the programs.
+// Remarks makes the remarks of an account key with the RemarkTemplate.
+// id is the id of the account, only the last 4 characters of it are used.
+func (c *Config) Remarks(id, expireDate string) string {
+	data := RemarkData{
+		ExpireDate: expireDate,
+		Host:       strings.Split(c.WebHost, ".")[0],
+		Region:     c.WebHostRegion,
+		Suffix:     id[max(len(id)-4, 0):],
 	}
 
-	GotifyAPIKeys = strings.Split(*gotifyAPIKeys, ",")
+	var b strings.Builder
+	err := c.remarks.Execute(&b, data)
+	if err != nil {
+		slog.Error("Error executing the remark template.", "err", err)
+		return fmt.Sprintf("valid before (%s) %s-%s-%s", data.ExpireDate, data.Host, data.Region, data.Suffix)
+	}
+	return b.String()
 }
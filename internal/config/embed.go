@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templatesFS holds the default v2ray configs, the users file skeletons, the systemd units
+// and the nginx config that the installation writes, so a single binary can install from anywhere.
+//
+//go:embed templates/*/*
+var templatesFS embed.FS
+
+// installTemplates are the parsed templates inside the templatesFS, named after their filenames.
+var installTemplates = template.Must(template.New("").ParseFS(templatesFS, "templates/*/*"))
+
+// SoftetherDir is where the softether vpn server is installed.
+const SoftetherDir string = "/opt/softether/"
+
+// installData is the values the install templates are rendered with.
+type installData struct {
+	AdminMail        string
+	Hostname         string
+	WebRoot          string
+	V2rayPort        string
+	ConfigFilePrefix string
+	UserFilePrefix   string
+	SoftetherDir     string
+	SSTPPassword     string
+
+	// Binary and Args are the command line the server manager service runs with.
+	Binary string
+	Args   []string
+}
+
+// newInstallData collects the values of the current configuration for the install templates.
+func newInstallData(c *Config) (installData, error) {
+	binary, err := os.Executable()
+	if err != nil {
+		return installData{}, fmt.Errorf("Failed to find the server manager executable: %v", err)
+	}
+	binary, err = filepath.EvalSymlinks(binary)
+	if err != nil {
+		return installData{}, fmt.Errorf("Failed to resolve the server manager executable: %v", err)
+	}
+
+	return installData{
+		AdminMail:        c.AdminMail,
+		Hostname:         c.WebHost,
+		WebRoot:          WebRoot,
+		V2rayPort:        c.V2rayPort,
+		ConfigFilePrefix: c.ConfigFilePrefix,
+		UserFilePrefix:   c.UserFilePrefix,
+		SoftetherDir:     SoftetherDir,
+		SSTPPassword:     c.SSTPAdminPassword,
+		Binary:           binary,
+		Args:             serviceArgs(os.Args[1:]),
+	}, nil
+}
+
+// serviceArgs removes the install flags from the args, so the service runs the panel
+// with the same configuration the installation was started with.
+func serviceArgs(args []string) []string {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "install" {
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
+// writeTemplate renders the named template into the dst file, creating the parent directories.
+// An existing dst file is overwritten.
+func writeTemplate(name, dst string, data installData) error {
+	var buf bytes.Buffer
+	err := installTemplates.ExecuteTemplate(&buf, name, data)
+	if err != nil {
+		return fmt.Errorf("Failed to render the %s template: %v", name, err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return fmt.Errorf("Failed to create the directory for %s: %v", dst, err)
+	}
+
+	err = os.WriteFile(dst, buf.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %v", dst, err)
+	}
+	return nil
+}
+
+// writeTemplateOnce is like writeTemplate but keeps the dst file if it already exists,
+// to use with the v2ray configs and users files that hold the accounts.
+func writeTemplateOnce(name, dst string, data installData) error {
+	_, err := os.Stat(dst)
+	if err == nil {
+		fmt.Println("Keeping the existing", dst)
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to check %s: %v", dst, err)
+	}
+	return writeTemplate(name, dst, data)
+}
@@ -2,42 +2,47 @@ package config
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
 )
 
 const WebRoot string = "/var/www/html"
 
 // we should use it or not? still deciding.
-// var NginxConf string = "/etc/nginx/conf.d" + *WebHost
+// var NginxConf string = "/etc/nginx/conf.d" + WebHost
 
 // Installs the whole panel in one go.
 func Install() {
+	data, err := newInstallData(Get())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
 	//BUG: need some more ERROR handling
-	err := certsInstall()
+	err = certsInstall(data)
 	if err != nil {
 		fmt.Println(err)
 	}
-	err = vmessInstall()
+	err = vmessInstall(data)
 	if err != nil {
 		fmt.Println(err)
 	}
-	err = shadowsocksInstall()
+	err = shadowsocksInstall(data)
 	if err != nil {
 		fmt.Println(err)
 	}
-	err = sstpInstall()
+	err = sstpInstall(data)
 	if err != nil {
 		fmt.Println(err)
 	}
-	err = serverManagerInstall()
+	err = serverManagerInstall(data)
 	if err != nil {
 		fmt.Println(err)
 	}
 	fmt.Println("The whole panel successfully installed.")
 }
 
-func serverManagerInstall() error {
+func serverManagerInstall(data installData) error {
 	// BUG: make sure the nginx is not running.
 	cmd := exec.Command("sudo", "systemctl", "stop", "nginx")
 	output, err := cmd.CombinedOutput()
@@ -45,10 +50,9 @@ func serverManagerInstall() error {
 		return fmt.Errorf("Failed to stop the nginx service before the server manager service initialiation: %s, %v", string(output), err)
 	}
 
-	cmd = exec.Command("cp", "v2ray/server-manager.service", "/etc/systemd/system/server-manager.service")
-	output, err = cmd.CombinedOutput()
+	err = writeTemplate("server-manager.service", "/etc/systemd/system/server-manager.service", data)
 	if err != nil {
-		return fmt.Errorf("Failed to copy the server-manager service file for the vpn services: %s, %v", string(output), err)
+		return err
 	}
 
 	cmd = exec.Command("sudo", "systemctl", "daemon-reload")
@@ -68,24 +72,15 @@ func serverManagerInstall() error {
 }
 
 // BUG: there's still more bug in this, certificates are not being requested after run, check this after.
-func certsInstall() error {
+func certsInstall(data installData) error {
 	// todo: install nginx and get the certs from letsencrypt
+	if data.AdminMail == "" {
+		return fmt.Errorf("Failed to request a certificate from letsencrypt: adminmail must be given")
+	}
 
-	var nginxServerConfig string = `server {
-			listen 80;
-			server_name "` + *WebHost + `";
-
-			root "/var/www/html";
-
-			location ~ /.well-known/acme-challenge {
-				allow all;
-			}
-		}
-	`
-
-	err := os.WriteFile("/etc/nginx/conf.d/"+*WebHost, []byte(nginxServerConfig), 0644)
+	err := writeTemplate("acme.conf", "/etc/nginx/conf.d/"+data.Hostname+".conf", data)
 	if err != nil {
-		return fmt.Errorf("Failed to overwrite the v2ray service file: %v", err)
+		return err
 	}
 
 	cmd := exec.Command("mkdir", "-p", "/var/www/html")
@@ -107,7 +102,7 @@ func certsInstall() error {
 	}
 
 	// Obtain SSL certificate with Certbot using webroot
-	cmd = exec.Command("certbot", "certonly", "--webroot", "--agree-tos", "--key-type", "rsa", "--email", *AdminMail, "-d", *WebHost, "-w", "/var/www/html")
+	cmd = exec.Command("certbot", "certonly", "--webroot", "--agree-tos", "--key-type", "rsa", "--email", data.AdminMail, "-d", data.Hostname, "-w", "/var/www/html")
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Failed to request a certificate from letsencrypt: %s, %v", string(output), err)
@@ -129,56 +124,34 @@ func certsInstall() error {
 	return nil
 }
 
-func vmessInstall() error {
-	const serviceFile string = `[Unit]
-		Description=V2Ray Vmess Service
-		Documentation=https://www.v2ray.com/ https://www.v2fly.org/
-		After=network-online.target nss-lookup.target
-
-		[Service]
-		Type=simple
-		CapabilityBoundingSet=CAP_NET_ADMIN CAP_NET_BIND_SERVICE
-		AmbientCapabilities=CAP_NET_ADMIN CAP_NET_BIND_SERVICE
-		DynamicUser=true
-		NoNewPrivileges=true
-		Environment=V2RAY_LOCATION_ASSET=/etc/v2ray
-		ExecStart=/usr/bin/v2ray -config /etc/v2ray/vmess.json
-		Restart=on-failure
-
-		[Install]
-		WantedBy=multi-user.target`
-
+func vmessInstall(data installData) error {
 	cmd := exec.Command("sudo", "apt", "install", "v2ray")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Failed to restart service: %s, %v", string(output), err)
 	}
 
-	cmd = exec.Command("touch", "/usr/lib/systemd/system/v2ray.service")
-	output, err = cmd.CombinedOutput()
+	err = writeTemplate("v2ray.service", "/usr/lib/systemd/system/v2ray.service", data)
 	if err != nil {
-		return fmt.Errorf("Failed to find the v2ray service file: %s, %v", string(output), err)
+		return err
 	}
 
-	err = os.WriteFile("/usr/lib/systemd/system/v2ray.service", []byte(serviceFile), 0644)
+	err = writeTemplateOnce("vmess.json", data.ConfigFilePrefix+"vmess.json", data)
 	if err != nil {
-		return fmt.Errorf("Failed to overwrite the v2ray service file: %v", err)
+		return err
 	}
 
-	cmd = exec.Command("cp", "v2ray/vmess.json", "/etc/v2ray/vmess.json")
-	output, err = cmd.CombinedOutput()
+	err = writeTemplateOnce("vmess_users.json", data.UserFilePrefix+"vmess_users.json", data)
 	if err != nil {
-		return fmt.Errorf("Failed to copy the vmess config default file for the vmess service: %s, %v", string(output), err)
+		return err
 	}
 
-	// BUG: can't create the file, why? I touched it and still can't use this?
-	cmd = exec.Command("cp", "v2ray/vmess_users.json", "/etc/v2ray_users/vmess_users.json")
+	cmd = exec.Command("sudo", "systemctl", "daemon-reload")
 	output, err = cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("Failed to copy the vmess users default file for the vmess service: %s, %v", string(output), err)
+		return fmt.Errorf("Failed to restart the systemd daemon: %s, %v", string(output), err)
 	}
 
-	// BUG: executable file not found in $PATH
 	cmd = exec.Command("sudo", "systemctl", "restart", "v2ray.service")
 	output, err = cmd.CombinedOutput()
 	if err != nil {
@@ -188,53 +161,32 @@ func vmessInstall() error {
 	return nil
 }
 
-func shadowsocksInstall() error {
-	const serviceFile = `[Unit]
-		Description=V2Ray Shadowsocks Service
-		Documentation=https://www.v2ray.com/ https://www.v2fly.org/
-		After=network-online.target nss-lookup.target
-
-		[Service]
-		Type=simple
-		CapabilityBoundingSet=CAP_NET_ADMIN CAP_NET_BIND_SERVICE
-		AmbientCapabilities=CAP_NET_ADMIN CAP_NET_BIND_SERVICE
-		DynamicUser=true
-		NoNewPrivileges=true
-		Environment=V2RAY_LOCATION_ASSET=/etc/v2ray
-		ExecStart=/usr/bin/v2ray -config /etc/v2ray/shadowsocks.json
-		Restart=on-failure
-
-		[Install]
-		WantedBy=multi-user.target`
-
+func shadowsocksInstall(data installData) error {
 	cmd := exec.Command("sudo", "apt", "install", "v2ray")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Failed to restart service: %s, %v", string(output), err)
 	}
 
-	cmd = exec.Command("touch", "/usr/lib/systemd/system/shadowsocks.service")
-	output, err = cmd.CombinedOutput()
+	err = writeTemplate("shadowsocks.service", "/usr/lib/systemd/system/shadowsocks.service", data)
 	if err != nil {
-		return fmt.Errorf("Failed to find the v2ray service file: %s, %v", string(output), err)
+		return err
 	}
 
-	err = os.WriteFile("/usr/lib/systemd/system/shadowsocks.service", []byte(serviceFile), 0644)
+	err = writeTemplateOnce("shadowsocks.json", data.ConfigFilePrefix+"shadowsocks.json", data)
 	if err != nil {
-		return fmt.Errorf("Failed to overwrite the v2ray service file: %s, %v", string(output), err)
+		return err
 	}
 
-	cmd = exec.Command("cp", "v2ray/shadowsocks.json", "/etc/v2ray/shadowsocks.json")
-	output, err = cmd.CombinedOutput()
+	err = writeTemplateOnce("shadowsocks_users.json", data.UserFilePrefix+"shadowsocks_users.json", data)
 	if err != nil {
-		return fmt.Errorf("Failed to copy the shadowsocks config default file for the shadowsocks service: %s, %v", string(output), err)
+		return err
 	}
 
-	// BUG: don't have any file even if I touched it. I think its due to the directory
-	cmd = exec.Command("cp", "v2ray/shadowsocks_users.json", "/etc/v2ray_users/shadowsocks_users.json")
+	cmd = exec.Command("sudo", "systemctl", "daemon-reload")
 	output, err = cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("Failed to copy the shadowsocks users default file for the shadowsocks service: %s, %v", string(output), err)
+		return fmt.Errorf("Failed to restart the systemd daemon: %s, %v", string(output), err)
 	}
 
 	cmd = exec.Command("sudo", "systemctl", "restart", "shadowsocks.service")
@@ -246,20 +198,7 @@ func shadowsocksInstall() error {
 	return nil
 }
 
-func sstpInstall() error {
-	const serviceFile string = `[Unit]
-		Description=SoftEther VPN server
-		After=network-online.target
-		After=dbus.service
-
-		[Service]
-		Type=forking
-		ExecStart=/opt/softether/vpnserver start
-		ExecReload=/bin/kill -HUP $MAINPID
-
-		[Install]
-		WantedBy=multi-user.target`
-
+func sstpInstall(data installData) error {
 	cmd := exec.Command("sudo", "apt", "install", "unzip")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -272,25 +211,36 @@ func sstpInstall() error {
 		return fmt.Errorf("Failed to unzip softether service zip file: %s, %v", string(output), err)
 	}
 
-	cmd = exec.Command("mkdir", "-p", "/opt/softether/")
+	cmd = exec.Command("mkdir", "-p", data.SoftetherDir)
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Failed to move softether service files to opt directory: %s, %v", string(output), err)
 	}
 
-	cmd = exec.Command("mv", "softether/", "/opt/softether/")
+	cmd = exec.Command("mv", "softether/", data.SoftetherDir)
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Failed to move softether service files to opt directory: %s, %v", string(output), err)
 	}
 
-	cmd = exec.Command("/opt/softether/vpncmd", "127.0.0.1:5555", "/server", "/password:htetmyatthar", "<<EOF", "\nservercertset", "\n/etc/letsencrypt/live/"+*WebHost+"/fullchain.pem", "\n/etc/letsencrypt/live/"+*WebHost+"/privkey.pem", "\nEOF")
+	cmd = exec.Command(data.SoftetherDir+"vpncmd", "127.0.0.1:5555", "/server", "/password:"+data.SSTPPassword, "<<EOF", "\nservercertset", "\n/etc/letsencrypt/live/"+data.Hostname+"/fullchain.pem", "\n/etc/letsencrypt/live/"+data.Hostname+"/privkey.pem", "\nEOF")
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Failed to start the softether service: %s, %v", string(output), err)
 	}
 
-	cmd = exec.Command("sudo systemctl", "restart", "softether-vpnserver.service")
+	err = writeTemplate("softether-vpnserver.service", "/etc/systemd/system/softether-vpnserver.service", data)
+	if err != nil {
+		return err
+	}
+
+	cmd = exec.Command("sudo", "systemctl", "daemon-reload")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to restart the systemd daemon: %s, %v", string(output), err)
+	}
+
+	cmd = exec.Command("sudo", "systemctl", "restart", "softether-vpnserver.service")
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Failed to start the softether service: %s, %v", string(output), err)
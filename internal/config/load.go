@@ -0,0 +1,454 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/htetmyatthar/lothone/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultConfigPath is the configuration file used when none is given and the file exists.
+	DefaultConfigPath string = "/etc/lothone/config.yaml"
+
+	// DefaultDatabasePath is the database file used when none is given.
+	DefaultDatabasePath string = "/var/lib/lothone/lothone.db"
+
+	// EnvPrefix prefixes the environment variable of each setting, eg. LOTHONE_HOSTNAME.
+	EnvPrefix string = "LOTHONE_"
+
+	// DefaultRemarkTemplate makes the remarks like "valid before (2025-01-02) host-region-1a2b".
+	DefaultRemarkTemplate string = "valid before ({{.ExpireDate}}) {{.Host}}-{{.Region}}-{{.Suffix}}"
+
+	// DefaultSSTPServerURL and DefaultSSTPHub are of the softether server the install sets up.
+	DefaultSSTPServerURL string = "https://localhost:5555/api"
+	DefaultSSTPHub       string = "default"
+)
+
+// Default returns the configuration with the default values.
+func Default() *Config {
+	return &Config{
+		WebHost:             "127.0.0.1",
+		WebHostRegion:       "127.0.0.1",
+		WebHostIP:           "127.0.0.1",
+		WebPort:             ":8888",
+		WebCert:             "localhost.crt",
+		WebKey:              "localhost.key",
+		V2rayPort:           "443",
+		ConfigFilePrefix:    "/etc/v2ray/",
+		UserFilePrefix:      "/etc/v2ray_users/",
+		DatabasePath:        DefaultDatabasePath,
+		SSTPServerURL:       DefaultSSTPServerURL,
+		SSTPHub:             DefaultSSTPHub,
+		TrustedRoutes:       TrustedPrivate,
+		ClientIPHeaders:     []string{"X-Forwarded-For"},
+		RemarkTemplate:      DefaultRemarkTemplate,
+		CSRFRotation:        24,
+		SecurityHeaders:     true,
+		CSPSources:          []string{"https://cdn.lothone.shop"},
+		HSTSDays:            365,
+		LogFormat:           logging.FormatText,
+		LogLevel:            "info",
+		MetricsExpiringDays: 7,
+		SessionDuration:     10,
+		SessionIdle:         10,
+		LockOutDuration:     30,
+		CreditPrices:        []string{"vmess~1", "shadowsocks~1", "sstp~1"},
+	}
+}
+
+// setting is a configuration value that can be overridden by the environment
+// variable EnvPrefix+NAME and the flag -name.
+type setting struct {
+	name  string
+	usage string
+	// secret settings are warned about when they are given on the command line.
+	secret bool
+	set    func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{name: "hostname", usage: "fully qualify domain name of the server", set: setString(func(c *Config) *string { return &c.WebHost })},
+	{name: "region", usage: "geo location region of the physical server", set: setString(func(c *Config) *string { return &c.WebHostRegion })},
+	{name: "hostip", usage: "ipv4 or ipv6 address of the server", set: setString(func(c *Config) *string { return &c.WebHostIP })},
+	{name: "webport", usage: "port number of the control panel web server", set: setString(func(c *Config) *string { return &c.WebPort })},
+	{name: "webcert", usage: "ssl/tls certificate for the web server", set: setString(func(c *Config) *string { return &c.WebCert })},
+	{name: "webkey", usage: "ssl/tls certificate key for the web server", set: setString(func(c *Config) *string { return &c.WebKey })},
+	{name: "adminmail", usage: "mail address used for requesting the certificates", set: setString(func(c *Config) *string { return &c.AdminMail })},
+	{name: "v2rayport", usage: "port number of the v2ray proxy server", set: setString(func(c *Config) *string { return &c.V2rayPort })},
+	{name: "configprefix", usage: "directory of the v2ray config files with the trailing slash", set: setString(func(c *Config) *string { return &c.ConfigFilePrefix })},
+	{name: "userprefix", usage: "directory of the v2ray users files with the trailing slash", set: setString(func(c *Config) *string { return &c.UserFilePrefix })},
+	{name: "sstpserver", usage: "json-rpc api url of the softether server", set: setString(func(c *Config) *string { return &c.SSTPServerURL })},
+	{name: "sstphub", usage: "softether hub the sstp accounts are created in", set: setString(func(c *Config) *string { return &c.SSTPHub })},
+	{name: "sstppassword", usage: "softether server admin password, prefer -sstppasswordfile", secret: true, set: setString(func(c *Config) *string { return &c.SSTPAdminPassword })},
+	{name: "sstppasswordfile", usage: "file containing the softether server admin password", set: setString(func(c *Config) *string { return &c.SSTPAdminPasswordFile })},
+	{name: "database", usage: "file the panel keeps the admins and the other persistent data in", set: setString(func(c *Config) *string { return &c.DatabasePath })},
+	{name: "admins", usage: "panel users with username and passwords seperated by tilde(~) and for each user seperated by comma(,), prefer -adminsfile", secret: true, set: setList(func(c *Config) *[]string { return &c.Admins })},
+	{name: "adminsfile", usage: "file containing the panel users, one username~password per line", set: setString(func(c *Config) *string { return &c.AdminsFile })},
+	{name: "gotifyserver", usage: "push nofication server domain name", set: setString(func(c *Config) *string { return &c.GotifyServer })},
+	{name: "gotifyapikeys", usage: "keys for using with push notification system seperated by comma(,), prefer -gotifyapikeysfile", secret: true, set: setList(func(c *Config) *[]string { return &c.GotifyAPIKeys })},
+	{name: "gotifyapikeysfile", usage: "file containing the push notification keys, one key per line", set: setString(func(c *Config) *string { return &c.GotifyAPIKeysFile })},
+	{name: "trusted", usage: "ip addresses or cidr ranges seperated by comma(,) allowed to use the trustedroutes, everyone if it's empty", set: setList(func(c *Config) *[]string { return &c.TrustedIPs })},
+	{name: "trustedroutes", usage: "routes limited to the trusted ip addresses, private or server", set: setString(func(c *Config) *string { return &c.TrustedRoutes })},
+	{name: "trustedproxies", usage: "reverse proxy ip addresses or cidr ranges seperated by comma(,) whose clientipheaders are believed", set: setList(func(c *Config) *[]string { return &c.TrustedProxies })},
+	{name: "clientipheaders", usage: "headers of the client ip address set by the trusted proxies in the order of preference seperated by comma(,), X-Forwarded-For, X-Real-IP or CF-Connecting-IP", set: setList(func(c *Config) *[]string { return &c.ClientIPHeaders })},
+	{name: "remark", usage: "text/template of the account key remarks, with .ExpireDate, .Host, .Region and .Suffix", set: setString(func(c *Config) *string { return &c.RemarkTemplate })},
+	{name: "csrfrotation", usage: "how often the secret key of the csrf tokens is changed in hours, 0 for never", set: setInt(func(c *Config) *int { return &c.CSRFRotation })},
+	{name: "securityheaders", usage: "send the content security policy, the hsts and the other security headers, true or false", set: setBool(func(c *Config) *bool { return &c.SecurityHeaders })},
+	{name: "cspsources", usage: "origins seperated by comma(,) the pages load the scripts, the styles and the images from other than the panel", set: setList(func(c *Config) *[]string { return &c.CSPSources })},
+	{name: "cspreportonly", usage: "only report the content security policy violations, true or false", set: setBool(func(c *Config) *bool { return &c.CSPReportOnly })},
+	{name: "hstsdays", usage: "how long the browsers only use https for the panel in days, 0 for not sending it", set: setInt(func(c *Config) *int { return &c.HSTSDays })},
+	{name: "logformat", usage: "format of the logs, text or json", set: setString(func(c *Config) *string { return &c.LogFormat })},
+	{name: "loglevel", usage: "least important logs that are written, debug, info, warn or error", set: setString(func(c *Config) *string { return &c.LogLevel })},
+	{name: "metrics", usage: "serve the prometheus metrics at /metrics, true or false", set: setBool(func(c *Config) *bool { return &c.Metrics })},
+	{name: "metricspublic", usage: "serve the prometheus metrics without an api token, true or false", set: setBool(func(c *Config) *bool { return &c.MetricsPublic })},
+	{name: "metricsexpiringdays", usage: "how soon the accounts counted as expiring in the metrics expire in days", set: setInt(func(c *Config) *int { return &c.MetricsExpiringDays })},
+	{name: "sessionduration", usage: "loggedin session remembered duration in minutes", set: setInt(func(c *Config) *int { return &c.SessionDuration })},
+	{name: "sessionidle", usage: "minutes the loggedin session is logged out after being unused", set: setInt(func(c *Config) *int { return &c.SessionIdle })},
+	{name: "lockoutduration", usage: "locking out time for wrong password in minutes", set: setInt(func(c *Config) *int { return &c.LockOutDuration })},
+	{name: "maxsessions", usage: "how many sessions an admin can be logged in with at once, 0 for no limit", set: setInt(func(c *Config) *int { return &c.MaxSessions })},
+	{name: "requiretotp", usage: "make every admin set up the two-factor authentication, true or false", set: setBool(func(c *Config) *bool { return &c.RequireTOTP })},
+	{name: "creditprices", usage: "credits the resellers pay for 30 days of an account, protocol~credits seperated by comma(,), eg. vmess~1,sstp~2", set: setList(func(c *Config) *[]string { return &c.CreditPrices })},
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setList(field func(c *Config) *[]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = splitList(value, ",")
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// Load registers the configuration flags on the fs, parses the args and builds the
+// configuration from the defaults, the configuration file, the environment variables
+// and the flags, each one overriding the previous one. The result is validated.
+//
+// The configuration file is given by the -config flag or the LOTHONE_CONFIG environment
+// variable, falling back to the DefaultConfigPath if it exists.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	flags := make(map[string]string)
+	for _, s := range settings {
+		fs.Func(s.name, s.usage, func(value string) error {
+			flags[s.name] = value
+			return nil
+		})
+	}
+	path := fs.String("config", "", "yaml configuration file of the panel, also read from "+EnvPrefix+"CONFIG")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range settings {
+		if _, ok := flags[s.name]; ok && s.secret {
+			slog.Warn("The flag is visible to every user of the server, use the file or the environment variable instead.", "flag", "-"+s.name)
+		}
+	}
+
+	if *path == "" {
+		*path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if *path == "" {
+		if _, err := os.Stat(DefaultConfigPath); err == nil {
+			*path = DefaultConfigPath
+		}
+	}
+
+	return build(*path, flags)
+}
+
+// Reload builds the configuration again from the same configuration file, the current
+// environment variables and the flags the c was loaded with. The result is validated
+// and c is left untouched.
+func (c *Config) Reload() (*Config, error) {
+	return build(c.Path, c.flags)
+}
+
+// build builds and validates the configuration.
+func build(path string, flags map[string]string) (*Config, error) {
+	c := Default()
+	c.Path = path
+	c.flags = flags
+
+	if path != "" {
+		err := c.loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		value, ok := os.LookupEnv(EnvPrefix + strings.ToUpper(s.name))
+		if !ok {
+			continue
+		}
+		if err := s.set(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %v", EnvPrefix, strings.ToUpper(s.name), err))
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := flags[s.name]
+		if !ok {
+			continue
+		}
+		if err := s.set(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %v", s.name, err))
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	err := c.readSecretFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	c.remarks = template.Must(template.New("remark").Parse(c.RemarkTemplate))
+	c.trusted, _ = parsePrefixes(c.TrustedIPs)
+	c.proxies, _ = parsePrefixes(c.TrustedProxies)
+	return c, nil
+}
+
+// loadFile decodes the yaml configuration file into c, unknown keys are errors.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading the configuration file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing the configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// readSecretFiles reads the secrets that are given as files, so they don't have to be
+// written in the configuration file or given on the command line.
+func (c *Config) readSecretFiles() error {
+	if c.SSTPAdminPasswordFile != "" {
+		data, err := os.ReadFile(c.SSTPAdminPasswordFile)
+		if err != nil {
+			return fmt.Errorf("reading the sstp admin password file: %w", err)
+		}
+		c.SSTPAdminPassword = strings.TrimSpace(string(data))
+	}
+
+	if c.AdminsFile != "" {
+		data, err := os.ReadFile(c.AdminsFile)
+		if err != nil {
+			return fmt.Errorf("reading the admins file: %w", err)
+		}
+		c.Admins = append(c.Admins, splitList(string(data), "\n")...)
+	}
+
+	if c.GotifyAPIKeysFile != "" {
+		data, err := os.ReadFile(c.GotifyAPIKeysFile)
+		if err != nil {
+			return fmt.Errorf("reading the gotify api keys file: %w", err)
+		}
+		c.GotifyAPIKeys = append(c.GotifyAPIKeys, splitList(string(data), "\n")...)
+	}
+	return nil
+}
+
+// Validate reports every invalid value of the configuration at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	if c.WebHost == "" {
+		invalid("hostname must not be empty")
+	}
+	if c.WebHostRegion == "" {
+		invalid("region must not be empty")
+	}
+	if net.ParseIP(c.WebHostIP) == nil {
+		invalid("hostip %q is not an ip address", c.WebHostIP)
+	}
+
+	host, port, err := net.SplitHostPort(c.WebPort)
+	if err != nil || host != "" || !validPort(port) {
+		invalid("webport %q must be in the form of \":8888\"", c.WebPort)
+	}
+	if c.WebCert == "" || c.WebKey == "" {
+		invalid("webcert and webkey must not be empty")
+	}
+	if c.AdminMail != "" && !strings.Contains(c.AdminMail, "@") {
+		invalid("adminmail %q is not a mail address", c.AdminMail)
+	}
+
+	if !validPort(c.V2rayPort) {
+		invalid("v2rayport %q is not a port number", c.V2rayPort)
+	}
+	if !strings.HasSuffix(c.ConfigFilePrefix, "/") {
+		invalid("configprefix %q must end with a slash", c.ConfigFilePrefix)
+	}
+	if !strings.HasSuffix(c.UserFilePrefix, "/") {
+		invalid("userprefix %q must end with a slash", c.UserFilePrefix)
+	}
+
+	if u, err := url.Parse(c.SSTPServerURL); err != nil || u.Scheme == "" || u.Host == "" {
+		invalid("sstpserver %q is not an absolute url", c.SSTPServerURL)
+	}
+	if c.SSTPHub == "" {
+		invalid("sstphub must not be empty")
+	}
+
+	if c.DatabasePath == "" {
+		invalid("database must not be empty")
+	}
+
+	for i, admin := range c.Admins {
+		username, password, ok := strings.Cut(admin, "~")
+		// NOTE: the username isn't printed for every error, it might be a password of a badly formatted line.
+		if !ok || username == "" || password == "" {
+			invalid("admin #%d must be in the form of username~password", i+1)
+			continue
+		}
+		if len(username) > 30 || len(password) > 72 {
+			invalid("admin %q: usernames should not be more than 30 characters and passwords 72", username)
+		}
+	}
+
+	if len(c.GotifyAPIKeys) != 0 && c.GotifyServer == "" {
+		invalid("gotifyserver must be given to use the gotify api keys")
+	}
+
+	if _, err := parsePrefixes(c.TrustedIPs); err != nil {
+		invalid("trusted %v", err)
+	}
+	if c.TrustedRoutes != TrustedPrivate && c.TrustedRoutes != TrustedServer {
+		invalid("trustedroutes %q must be %s or %s", c.TrustedRoutes, TrustedPrivate, TrustedServer)
+	}
+	if _, err := parsePrefixes(c.TrustedProxies); err != nil {
+		invalid("trustedproxies %v", err)
+	}
+	for _, header := range c.ClientIPHeaders {
+		if !slices.ContainsFunc([]string{"X-Forwarded-For", "X-Real-IP", "CF-Connecting-IP"}, func(h string) bool { return strings.EqualFold(h, header) }) {
+			invalid("clientipheaders %q must be X-Forwarded-For, X-Real-IP or CF-Connecting-IP", header)
+		}
+	}
+
+	if _, err := template.New("remark").Parse(c.RemarkTemplate); err != nil {
+		invalid("remark: %v", err)
+	}
+
+	if c.CSRFRotation < 0 {
+		invalid("csrfrotation must not be negative, 0 for never")
+	}
+	for _, source := range c.CSPSources {
+		u, err := url.Parse(source)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || strings.ContainsAny(source, "; ") {
+			invalid("cspsources %q must be an origin like https://cdn.example.com", source)
+		}
+	}
+	if c.HSTSDays < 0 {
+		invalid("hstsdays must not be negative, 0 for not sending it")
+	}
+	if c.LogFormat != logging.FormatText && c.LogFormat != logging.FormatJSON {
+		invalid("logformat %q must be %s or %s", c.LogFormat, logging.FormatText, logging.FormatJSON)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		invalid("loglevel %v", err)
+	}
+	if c.MetricsExpiringDays <= 0 {
+		invalid("metricsexpiringdays must be more than 0 days")
+	}
+	if c.SessionDuration <= 0 {
+		invalid("sessionduration must be more than 0 minutes")
+	}
+	if c.SessionIdle <= 0 {
+		invalid("sessionidle must be more than 0 minutes")
+	}
+	if c.LockOutDuration <= 0 {
+		invalid("lockoutduration must be more than 0 minutes")
+	}
+	if c.MaxSessions < 0 {
+		invalid("maxsessions must not be negative, 0 for no limit")
+	}
+
+	for _, price := range c.CreditPrices {
+		protocol, credits, ok := strings.Cut(price, "~")
+		n, err := strconv.Atoi(credits)
+		if !ok || err != nil || n < 0 || !slices.Contains([]string{"vmess", "shadowsocks", "sstp"}, protocol) {
+			invalid("creditprices %q must be in the form of protocol~credits, with vmess, shadowsocks or sstp", price)
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validPort reports whether the s is a port number.
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port > 0 && port < 65536
+}
+
+// splitList splits the s by the sep, trimming the spaces and dropping the empty values.
+func splitList(s, sep string) []string {
+	var result []string
+	for _, v := range strings.Split(s, sep) {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"flag"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+hostname: sg1.example.com
+region: sg
+host_ip: 10.0.0.1
+admins:
+  - admin~password
+session_duration: 20
+`
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadOrder(t *testing.T) {
+	path := writeTestFile(t, "config.yaml", testConfig)
+	t.Setenv(EnvPrefix+"REGION", "jp")
+	t.Setenv(EnvPrefix+"SESSIONDURATION", "30")
+
+	c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path, "-sessionduration", "40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.WebHost != "sg1.example.com" {
+		t.Errorf("hostname from the file: got %q", c.WebHost)
+	}
+	if c.WebHostRegion != "jp" {
+		t.Errorf("region from the environment: got %q", c.WebHostRegion)
+	}
+	if c.SessionDuration != 40 {
+		t.Errorf("sessionduration from the flag: got %d", c.SessionDuration)
+	}
+	if c.WebPort != ":8888" {
+		t.Errorf("webport from the defaults: got %q", c.WebPort)
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	admins := writeTestFile(t, "admins", "second~password\n\nthird~password\n")
+	password := writeTestFile(t, "password", "secret\n")
+
+	c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{
+		"-admins", "first~password",
+		"-adminsfile", admins,
+		"-sstppasswordfile", password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Admins) != 3 {
+		t.Errorf("expected 3 admins, got %d", len(c.Admins))
+	}
+	if c.SSTPAdminPassword != "secret" {
+		t.Errorf("expected the trimmed password from the file, got %q", c.SSTPAdminPassword)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"bad admin", []string{"-admins", "a"}, "admin #1"},
+		{"bad port", []string{"-admins", "a~b", "-webport", "8888"}, "webport"},
+		{"bad trusted", []string{"-admins", "a~b", "-trusted", "10.0.0.0/33"}, "trusted"},
+		{"bad trusted routes", []string{"-admins", "a~b", "-trustedroutes", "public"}, "trustedroutes"},
+		{"bad client ip header", []string{"-admins", "a~b", "-clientipheaders", "True-Client-IP"}, "clientipheaders"},
+		{"bad number", []string{"-admins", "a~b", "-lockoutduration", "ten"}, "-lockoutduration"},
+		{"bad bool", []string{"-admins", "a~b", "-requiretotp", "maybe"}, "-requiretotp"},
+		{"bad price", []string{"-admins", "a~b", "-creditprices", "vless~1"}, "creditprices"},
+		{"bad sessions", []string{"-admins", "a~b", "-maxsessions", "-1"}, "maxsessions"},
+		{"bad csrf rotation", []string{"-admins", "a~b", "-csrfrotation", "-1"}, "csrfrotation"},
+		{"bad csp source", []string{"-admins", "a~b", "-cspsources", "cdn.example.com"}, "cspsources"},
+		{"bad log format", []string{"-admins", "a~b", "-logformat", "xml"}, "logformat"},
+		{"bad log level", []string{"-admins", "a~b", "-loglevel", "loud"}, "loglevel"},
+		{"bad metrics expiring days", []string{"-admins", "a~b", "-metricsexpiringdays", "0"}, "metricsexpiringdays"},
+		{"bad session idle", []string{"-admins", "a~b", "-sessionidle", "0"}, "sessionidle"},
+		{"bad remark", []string{"-admins", "a~b", "-remark", "{{.Host"}, "remark"},
+	}
+
+	for _, tt := range tests {
+		_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error about %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	path := writeTestFile(t, "config.yaml", testConfig+"unknown: true\n")
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path})
+	if err == nil {
+		t.Error("expected an error for the unknown key")
+	}
+}
+
+func TestReloadRejected(t *testing.T) {
+	path := writeTestFile(t, "config.yaml", testConfig)
+	c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Set(c)
+
+	err = os.WriteFile(path, []byte(testConfig+"web_port: \"9999\"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Reload()
+	if err == nil || Get() != c {
+		t.Errorf("expected the invalid configuration to be rejected and the current one kept, got %v", err)
+	}
+
+	err = os.WriteFile(path, []byte(testConfig+"region: jp\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved []func(old, c *Config) (func(), error)) { reloaders = saved }(reloaders)
+	OnReload(func(old, c *Config) (func(), error) {
+		return nil, os.ErrPermission
+	})
+	_, err = Reload()
+	if err == nil || Get() != c || Get().WebHostRegion != "sg" {
+		t.Errorf("expected the reload rejected by the reloader to keep the current configuration, got %v", err)
+	}
+}
+
+func TestReloadDiff(t *testing.T) {
+	path := writeTestFile(t, "config.yaml", testConfig)
+	old, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := strings.ReplaceAll(testConfig, "admin~password", "admin~changed")
+	next = strings.ReplaceAll(next, "session_duration: 20", "session_duration: 30")
+	err = os.WriteFile(path, []byte(next+"web_port: \":9999\"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := old.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := strings.Join(old.Diff(c), "\n")
+	if strings.Contains(changes, "admin~") {
+		t.Errorf("the admins must not be in the diff: %s", changes)
+	}
+	if !strings.Contains(changes, "admins: changed (only the new admins are added") {
+		t.Errorf("expected the admins to be changed: %s", changes)
+	}
+	if !strings.Contains(changes, "session_duration: 20 -> 30") || strings.Contains(changes, "session_duration: 20 -> 30 (") {
+		t.Errorf("expected the session_duration to be applied by the reload: %s", changes)
+	}
+	if !strings.Contains(changes, `web_port: :8888 -> :9999 (applied after a restart)`) {
+		t.Errorf("expected the web_port to be changed: %s", changes)
+	}
+}
+
+func TestCredits(t *testing.T) {
+	c := Default()
+	c.CreditPrices = []string{"vmess~3", "sstp~0"}
+	tests := []struct {
+		protocol string
+		days     int
+		want     int
+	}{
+		{"vmess", 30, 3},
+		{"vmess", 31, 4},
+		{"vmess", 10, 1},
+		{"vmess", 0, 0},
+		{"sstp", 30, 0},
+		{"shadowsocks", 30, 0},
+	}
+	for _, tt := range tests {
+		if got := c.Credits(tt.protocol, tt.days); got != tt.want {
+			t.Errorf("%s for %d days: expected %d credits, got %d", tt.protocol, tt.days, tt.want, got)
+		}
+	}
+}
+
+func TestTrusted(t *testing.T) {
+	c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-admins", "a~b", "-trusted", "10.0.0.0/8,192.168.100.1,2001:db8::/32", "-trustedproxies", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"192.168.100.1", true},
+		{"192.168.100.2", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, tt := range tests {
+		if got := c.Trusted(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("%s: expected trusted %v, got %v", tt.ip, tt.want, got)
+		}
+	}
+	if !c.TrustedProxy(netip.MustParseAddr("127.0.0.1")) || c.TrustedProxy(netip.MustParseAddr("10.1.2.3")) {
+		t.Error("unexpected trusted proxies")
+	}
+
+	if !Default().Trusted(netip.MustParseAddr("8.8.8.8")) {
+		t.Error("expected every ip to be trusted without the trusted ips")
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// restartSettings are the yaml keys of the settings that are only applied when
+// the panel is restarted, the listener and the log format are made once.
+var restartSettings = []string{"hostname", "host_ip", "web_port", "log_format"}
+
+// adminSettings are the yaml keys of the settings of the admins, only the new admins of them are
+// added on the reloads. The passwords and the removals are applied by the admin commands only, as
+// the admins are kept in the database.
+var adminSettings = []string{"admins", "admins_file"}
+
+// secretSettings are the yaml keys of the settings whose values are never logged.
+var secretSettings = []string{"sstp_admin_password", "admins", "gotify_api_keys"}
+
+var (
+	// reloadMu makes sure only one reload is running at a time.
+	reloadMu sync.Mutex
+
+	// reloaders are the functions that prepare the parts of the panel for a reloaded configuration.
+	reloaders []func(old, c *Config) (apply func(), err error)
+)
+
+// OnReload registers the prepare function to be called with the current and the reloaded
+// configuration before the reloaded one replaces the current one.
+// prepare returns the function applying the reloaded configuration, or an error to reject the reload.
+// The apply functions are only called after every prepare function succeeded.
+func OnReload(prepare func(old, c *Config) (apply func(), err error)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloaders = append(reloaders, prepare)
+}
+
+// Reload reads the configuration again and replaces the current one with it, returning
+// the changes that were made. If the reloaded configuration is invalid or any of the
+// registered reloaders rejects it, the current configuration is kept as is.
+func Reload() ([]string, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	old := Get()
+	c, err := old.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	applies := make([]func(), 0, len(reloaders))
+	for _, prepare := range reloaders {
+		apply, err := prepare(old, c)
+		if err != nil {
+			return nil, err
+		}
+		if apply != nil {
+			applies = append(applies, apply)
+		}
+	}
+
+	Set(c)
+	for _, apply := range applies {
+		apply()
+	}
+
+	changes := old.Diff(c)
+	for _, change := range changes {
+		slog.Info("config reloaded.", "change", change)
+	}
+	if len(changes) == 0 {
+		slog.Info("config reloaded: nothing changed.")
+	}
+	return changes, nil
+}
+
+// Diff describes the settings that are different in the next, one line for each setting.
+// The values of the secret settings are not included.
+func (c *Config) Diff(next *Config) []string {
+	var changes []string
+
+	ov, nv := reflect.ValueOf(c).Elem(), reflect.ValueOf(next).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		o, n := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+
+		change := fmt.Sprintf("%s: %v -> %v", name, o, n)
+		if slices.Contains(secretSettings, name) {
+			change = name + ": changed"
+		}
+		if slices.Contains(adminSettings, name) {
+			change += " (only the new admins are added, the others are changed by the admin commands)"
+		}
+		if slices.Contains(restartSettings, name) {
+			change += " (applied after a restart)"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// panelUnit is a systemd unit that is written by one of the install steps.
+type panelUnit struct {
+	name string // unit name used with systemctl.
+	path string // location of the unit file written during the installation.
+}
+
+// panelUnits are the units the installation writes, in the order they should be torn down.
+// server-manager goes first so the panel stops touching the vpn services while they are removed.
+var panelUnits = []panelUnit{
+	{name: "server-manager.service", path: "/etc/systemd/system/server-manager.service"},
+	{name: "v2ray.service", path: "/usr/lib/systemd/system/v2ray.service"},
+	{name: "shadowsocks.service", path: "/usr/lib/systemd/system/shadowsocks.service"},
+	{name: "softether-vpnserver.service", path: "/etc/systemd/system/softether-vpnserver.service"},
+}
+
+// UninstallReport records what the uninstallation did and what it failed to do.
+type UninstallReport struct {
+	Done   []string
+	Failed []string
+}
+
+func (r *UninstallReport) done(format string, a ...any) {
+	r.Done = append(r.Done, fmt.Sprintf(format, a...))
+}
+
+func (r *UninstallReport) failed(format string, a ...any) {
+	r.Failed = append(r.Failed, fmt.Sprintf(format, a...))
+}
+
+// Print writes the report to the standard output.
+func (r *UninstallReport) Print() {
+	for _, d := range r.Done {
+		fmt.Println("[done]  ", d)
+	}
+	for _, f := range r.Failed {
+		fmt.Println("[failed]", f)
+	}
+	if len(r.Failed) != 0 {
+		fmt.Printf("Uninstallation finished with %d failed step(s).\n", len(r.Failed))
+		return
+	}
+	fmt.Println("The whole panel successfully uninstalled.")
+}
+
+// Uninstall removes what the Install wrote in one go.
+// Every step is attempted even if the previous one failed so that a half
+// installed panel can still be cleaned up.
+//
+// If archiveDir is not empty, the users files are archived into that directory
+// before anything is removed.
+func Uninstall(archiveDir string) *UninstallReport {
+	report := &UninstallReport{}
+
+	if archiveDir != "" {
+		archive, err := archiveUsersFiles(archiveDir, Get().UserFilePrefix)
+		if err != nil {
+			report.failed("archiving the users files: %v", err)
+		} else {
+			report.done("archived the users files into %s", archive)
+		}
+	}
+
+	// read the ports before the units are gone, the users file is the only record of them.
+	ports, err := shadowsocksPorts(Get().UserFilePrefix)
+	if err != nil {
+		report.failed("reading the shadowsocks ports: %v", err)
+	}
+
+	for _, unit := range panelUnits {
+		removeUnit(unit, report)
+	}
+
+	cmd := exec.Command("sudo", "systemctl", "daemon-reload")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		report.failed("restarting the systemd daemon: %s, %v", string(output), err)
+	} else {
+		report.done("reloaded the systemd daemon")
+	}
+
+	for _, port := range ports {
+		err := deletePortRules(port)
+		if err != nil {
+			report.failed("deleting the firewall rules of port %d: %v", port, err)
+			continue
+		}
+		report.done("deleted the firewall rules of port %d", port)
+	}
+
+	return report
+}
+
+// removeUnit stops, disables and deletes the unit file of the given unit.
+// Units that were never installed are skipped.
+func removeUnit(unit panelUnit, report *UninstallReport) {
+	if _, err := os.Stat(unit.path); os.IsNotExist(err) {
+		report.done("skipped %s, it is not installed", unit.name)
+		return
+	}
+
+	cmd := exec.Command("sudo", "systemctl", "stop", unit.name)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		report.failed("stopping %s: %s, %v", unit.name, string(output), err)
+	} else {
+		report.done("stopped %s", unit.name)
+	}
+
+	cmd = exec.Command("sudo", "systemctl", "disable", unit.name)
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		report.failed("disabling %s: %s, %v", unit.name, string(output), err)
+	} else {
+		report.done("disabled %s", unit.name)
+	}
+
+	err = os.Remove(unit.path)
+	if err != nil {
+		report.failed("removing %s: %v", unit.path, err)
+		return
+	}
+	report.done("removed %s", unit.path)
+}
+
+// shadowsocksPorts returns the ports that were opened for each shadowsocks user.
+// These are the only ports the panel opens using the firewall.
+func shadowsocksPorts(userFilePrefix string) ([]int, error) {
+	usersFile := userFilePrefix + "shadowsocks_users.json"
+	userData, err := os.ReadFile(usersFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var userResult struct {
+		Clients []struct {
+			Port int `json:"port"`
+		} `json:"clients"`
+	}
+	err = json.Unmarshal(userData, &userResult)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]int, 0, len(userResult.Clients))
+	for _, c := range userResult.Clients {
+		if c.Port != 0 {
+			ports = append(ports, c.Port)
+		}
+	}
+	return ports, nil
+}
+
+// deletePortRules removes the UFW rules that were added for the port.
+// NOTE: this mirrors utils.DeletePort, config can't import utils.
+func deletePortRules(port int) error {
+	for _, proto := range []string{"tcp", "udp"} {
+		cmd := exec.Command("ufw", "delete", "allow", fmt.Sprintf("%d/%s", port, proto))
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %s, %v", proto, string(output), err)
+		}
+	}
+	return nil
+}
+
+// archiveUsersFiles writes the users files of every v2ray protocol into a gzipped tarball
+// inside the dir, returning the path of the archive.
+func archiveUsersFiles(dir, userFilePrefix string) (string, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	name := filepath.Join(dir, "lothone-users-"+time.Now().Format("20060102-150405")+".tar.gz")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	for _, usersFile := range []string{"vmess_users.json", "shadowsocks_users.json"} {
+		err = addToArchive(tw, userFilePrefix+usersFile)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return "", err
+	}
+	if err = gw.Close(); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// addToArchive copies the file into the archive, files that don't exist are skipped.
+func addToArchive(tw *tar.Writer, path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.Base(path)
+
+	err = tw.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrAdminNotFound = errors.New("Admin not found.")
+	ErrAdminExists   = errors.New("Admin already exists.")
+	ErrLastAdmin     = errors.New("The last admin can't be removed.")
+	ErrLastOwner     = errors.New("The last owner can't be removed or changed.")
+	ErrTOTPReused    = errors.New("The code is already used.")
+	ErrRecoveryCode  = errors.New("Invalid recovery code.")
+)
+
+var adminsBucket = []byte("admins")
+
+// Admin is a user of the panel.
+type Admin struct {
+	Username string `json:"username"`
+	// Hash is the encoded password hash made by utils.HashPassword, never to be logged.
+	Hash string `json:"hash"`
+	// Role is the name of the auth.Role of the admin, the admins saved before the roles are owners.
+	Role string `json:"role,omitempty"`
+	// AccountLimit is the most active accounts a reseller can have, zero for no limit.
+	AccountLimit int `json:"account_limit,omitempty"`
+	// TOTPSecret is the two-factor secret, the two-factor authentication is enabled when it's set.
+	TOTPSecret string `json:"totp_secret,omitempty"`
+	// TOTPStep is the period of the last accepted code, so a code can't be used twice.
+	TOTPStep int64 `json:"totp_step,omitempty"`
+	// RecoveryCodes are the hashes of the unused recovery codes made by utils.NewRecoveryCodes.
+	RecoveryCodes []string  `json:"recovery_codes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ownerRole is the auth.RoleOwner, the admins without a role are owners too.
+const ownerRole = "owner"
+
+// IsOwner reports whether the admin is an owner.
+func (a Admin) IsOwner() bool {
+	return a.Role == ownerRole || a.Role == ""
+}
+
+// TOTPEnabled reports whether the admin has to give a two-factor code to log in.
+func (a Admin) TOTPEnabled() bool {
+	return a.TOTPSecret != ""
+}
+
+// AdminStore keeps the panel admins in the database.
+type AdminStore struct {
+	db *DB
+}
+
+// NewAdminStore returns the AdminStore of the db.
+func NewAdminStore(db *DB) (*AdminStore, error) {
+	err := db.createBucket(adminsBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminStore{db: db}, nil
+}
+
+// Get returns the admin with the username.
+func (s *AdminStore) Get(username string) (Admin, error) {
+	var admin Admin
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(adminsBucket).Get([]byte(username))
+		if data == nil {
+			return ErrAdminNotFound
+		}
+		return json.Unmarshal(data, &admin)
+	})
+	return admin, err
+}
+
+// Add adds a new admin with the password hash and the role.
+func (s *AdminStore) Add(username, hash, role string) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(adminsBucket)
+		if b.Get([]byte(username)) != nil {
+			return ErrAdminExists
+		}
+		now := time.Now()
+		return putAdmin(b, Admin{Username: username, Hash: hash, Role: role, CreatedAt: now, UpdatedAt: now})
+	})
+}
+
+// SetHash replaces the password hash of the admin.
+func (s *AdminStore) SetHash(username, hash string) error {
+	return s.update(username, func(admin *Admin) error {
+		admin.Hash = hash
+		return nil
+	})
+}
+
+// SetRole changes the role of the admin, the last owner is kept so the admins can always be managed.
+func (s *AdminStore) SetRole(username, role string) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(adminsBucket)
+		admin, err := getAdmin(b, username)
+		if err != nil {
+			return err
+		}
+		if admin.IsOwner() && role != ownerRole {
+			err := lastOwner(b)
+			if err != nil {
+				return err
+			}
+		}
+		admin.Role = role
+		admin.UpdatedAt = time.Now()
+		return putAdmin(b, admin)
+	})
+}
+
+// SetAccountLimit sets the most active accounts the admin can have, zero for no limit.
+func (s *AdminStore) SetAccountLimit(username string, limit int) error {
+	return s.update(username, func(admin *Admin) error {
+		admin.AccountLimit = limit
+		return nil
+	})
+}
+
+// SetTOTP enables the two-factor authentication of the admin with the secret and the
+// hashes of the recovery codes, an empty secret disables it.
+func (s *AdminStore) SetTOTP(username, secret string, recoveryCodes []string) error {
+	return s.update(username, func(admin *Admin) error {
+		admin.TOTPSecret = secret
+		admin.TOTPStep = 0
+		admin.RecoveryCodes = recoveryCodes
+		return nil
+	})
+}
+
+// SetRecoveryCodes replaces the hashes of the recovery codes of the admin.
+func (s *AdminStore) SetRecoveryCodes(username string, recoveryCodes []string) error {
+	return s.update(username, func(admin *Admin) error {
+		admin.RecoveryCodes = recoveryCodes
+		return nil
+	})
+}
+
+// UseTOTPStep records the step of the accepted code, refusing the steps that are not
+// newer than the last one.
+func (s *AdminStore) UseTOTPStep(username string, step int64) error {
+	return s.update(username, func(admin *Admin) error {
+		if step <= admin.TOTPStep {
+			return ErrTOTPReused
+		}
+		admin.TOTPStep = step
+		return nil
+	})
+}
+
+// UseRecoveryCode removes the recovery code of the hash, so it can be used only once.
+func (s *AdminStore) UseRecoveryCode(username, hash string) error {
+	return s.update(username, func(admin *Admin) error {
+		i := slices.Index(admin.RecoveryCodes, hash)
+		if i < 0 {
+			return ErrRecoveryCode
+		}
+		admin.RecoveryCodes = slices.Delete(admin.RecoveryCodes, i, i+1)
+		return nil
+	})
+}
+
+// update changes the admin with the fn in a single transaction.
+func (s *AdminStore) update(username string, fn func(admin *Admin) error) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(adminsBucket)
+		admin, err := getAdmin(b, username)
+		if err != nil {
+			return err
+		}
+		err = fn(&admin)
+		if err != nil {
+			return err
+		}
+		admin.UpdatedAt = time.Now()
+		return putAdmin(b, admin)
+	})
+}
+
+// Remove removes the admin, the last admin and the last owner are kept so the panel is never locked.
+func (s *AdminStore) Remove(username string) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(adminsBucket)
+		admin, err := getAdmin(b, username)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		if first, _ := c.First(); first != nil {
+			if second, _ := c.Next(); second == nil {
+				return ErrLastAdmin
+			}
+		}
+		if admin.IsOwner() {
+			err := lastOwner(b)
+			if err != nil {
+				return err
+			}
+		}
+		return b.Delete([]byte(username))
+	})
+}
+
+// lastOwner returns the ErrLastOwner if there's only one owner.
+func lastOwner(b *bolt.Bucket) error {
+	owners := 0
+	err := b.ForEach(func(_, v []byte) error {
+		var admin Admin
+		err := json.Unmarshal(v, &admin)
+		if err != nil {
+			return err
+		}
+		if admin.IsOwner() {
+			owners++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// List returns the usernames of every admin in order.
+func (s *AdminStore) List() ([]string, error) {
+	var usernames []string
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(adminsBucket).ForEach(func(k, _ []byte) error {
+			usernames = append(usernames, string(k))
+			return nil
+		})
+	})
+	return usernames, err
+}
+
+// All returns every admin in order.
+func (s *AdminStore) All() ([]Admin, error) {
+	var admins []Admin
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(adminsBucket).ForEach(func(_, v []byte) error {
+			var admin Admin
+			err := json.Unmarshal(v, &admin)
+			if err != nil {
+				return err
+			}
+			admins = append(admins, admin)
+			return nil
+		})
+	})
+	return admins, err
+}
+
+func getAdmin(b *bolt.Bucket, username string) (Admin, error) {
+	var admin Admin
+	data := b.Get([]byte(username))
+	if data == nil {
+		return admin, ErrAdminNotFound
+	}
+	err := json.Unmarshal(data, &admin)
+	return admin, err
+}
+
+func putAdmin(b *bolt.Bucket, admin Admin) error {
+	data, err := json.Marshal(admin)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(admin.Username), data)
+}
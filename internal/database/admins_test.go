@@ -0,0 +1,82 @@
+package database
+
+import "testing"
+
+func TestAdminTOTP(t *testing.T) {
+	s, err := NewAdminStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Add("admin", "hash", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.SetTOTP("admin", "SECRET", []string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UseTOTPStep("admin", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UseTOTPStep("admin", 10); err != ErrTOTPReused {
+		t.Errorf("expected the used step to be refused, got %v", err)
+	}
+	if err := s.UseTOTPStep("admin", 9); err != ErrTOTPReused {
+		t.Errorf("expected the older step to be refused, got %v", err)
+	}
+
+	if err := s.UseRecoveryCode("admin", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UseRecoveryCode("admin", "first"); err != ErrRecoveryCode {
+		t.Errorf("expected the used recovery code to be refused, got %v", err)
+	}
+
+	admin, err := s.Get("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !admin.TOTPEnabled() || len(admin.RecoveryCodes) != 1 || admin.Hash != "hash" {
+		t.Errorf("unexpected admin %+v", admin)
+	}
+
+	err = s.SetTOTP("admin", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _ = s.Get("admin")
+	if admin.TOTPEnabled() {
+		t.Error("expected the two-factor authentication to be disabled")
+	}
+}
+
+func TestAdminLastOwner(t *testing.T) {
+	s, err := NewAdminStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the admins saved before the roles have no role and are owners.
+	for username, role := range map[string]string{"legacy": "", "reseller": "reseller"} {
+		err = s.Add(username, "hash", role)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.SetRole("legacy", "admin"); err != ErrLastOwner {
+		t.Errorf("expected the last owner to be kept, got %v", err)
+	}
+	if err := s.Remove("legacy"); err != ErrLastOwner {
+		t.Errorf("expected the last owner to be kept, got %v", err)
+	}
+	if err := s.SetRole("reseller", "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetRole("legacy", "read-only"); err != nil {
+		t.Errorf("expected the owner to be changed with another owner, got %v", err)
+	}
+	if err := s.Remove("reseller"); err != ErrLastOwner {
+		t.Errorf("expected the last owner to be kept, got %v", err)
+	}
+}
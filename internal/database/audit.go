@@ -0,0 +1,304 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var auditBucket = []byte("audit")
+
+// Audit actions.
+const (
+	AuditLoginFailed = "login.failed"
+	AuditLoginLocked = "login.locked"
+	AuditUnlocked    = "login.unlocked"
+
+	AuditTOTPEnabled      = "totp.enabled"
+	AuditTOTPDisabled     = "totp.disabled"
+	AuditRecoveryCodes    = "totp.recovery_codes"
+	AuditRecoveryCodeUsed = "totp.recovery_code_used"
+
+	AuditPasskeyAdded   = "passkey.added"
+	AuditPasskeyRemoved = "passkey.removed"
+
+	AuditRoleChanged = "admin.role_changed"
+
+	AuditAccountTransferred = "account.transferred"
+
+	AuditCredited     = "credits.credited"
+	AuditLimitChanged = "credits.limit_changed"
+
+	AuditTokenCreated = "token.created"
+	AuditTokenRevoked = "token.revoked"
+
+	AuditSessionRevoked  = "session.revoked"
+	AuditSessionsRevoked = "session.revoked_all"
+
+	AuditAccountSuspended = "account.suspended"
+	AuditAccountResumed   = "account.resumed"
+
+	AuditLogin  = "login.succeeded"
+	AuditLogout = "logout"
+
+	AuditAccountCreated = "account.created"
+	AuditAccountEdited  = "account.edited"
+	AuditAccountDeleted = "account.deleted"
+
+	AuditAdminAdded    = "admin.added"
+	AuditAdminRemoved  = "admin.removed"
+	AuditPasswordReset = "admin.password_reset"
+	AuditTOTPReset     = "admin.totp_reset"
+
+	AuditServerRestarted = "server.restarted"
+	AuditConfigReloaded  = "server.config_reloaded"
+)
+
+// AuditMask is shown instead of the values of the secret fields of the changes.
+const AuditMask = "••••••"
+
+// AuditEntry is an event of the audit log.
+type AuditEntry struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"` // admin username, or the attempted username for the logins.
+	IP     string    `json:"ip"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail"`
+
+	Changes []AuditChange `json:"changes,omitempty"`
+
+	// Prev is the Hash of the entry before, the Hash is of the entry with the Prev. Changing or
+	// removing an entry breaks the chain of the ones after it.
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+// AuditChange is a field of the target changed by the action.
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// AuditDiff returns the fields changed from the before to the after, by the field names.
+// The values of the secrets are masked, it's only shown that they're changed.
+func AuditDiff(before, after map[string]string, secrets ...string) []AuditChange {
+	var changes []AuditChange
+	for field, value := range after {
+		if before[field] != value {
+			changes = append(changes, AuditChange{Field: field, Before: before[field], After: value})
+		}
+	}
+	for field, value := range before {
+		if _, ok := after[field]; !ok {
+			changes = append(changes, AuditChange{Field: field, Before: value})
+		}
+	}
+	for i, c := range changes {
+		if slices.Contains(secrets, c.Field) {
+			changes[i].Before, changes[i].After = mask(c.Before), mask(c.After)
+		}
+	}
+	slices.SortFunc(changes, func(a, b AuditChange) int { return strings.Compare(a.Field, b.Field) })
+	return changes
+}
+
+// mask hides the secret value, the empty ones are kept to show it's set or unset.
+func mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	return AuditMask
+}
+
+// hash returns the hash of the e chained to its Prev.
+func (e AuditEntry) hash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// matches reports whether the e has the query in any of its fields, case insensitively.
+func (e AuditEntry) matches(query string) bool {
+	if query == "" {
+		return true
+	}
+	for _, field := range []string{e.Actor, e.IP, e.Action, e.Target, e.Detail} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditStore is the append only audit log in the database.
+type AuditStore struct {
+	db *DB
+}
+
+// NewAuditStore returns the AuditStore of the db. The entries recorded before the hash chain
+// are chained once, from the first one.
+func NewAuditStore(db *DB) (*AuditStore, error) {
+	err := db.createBucket(auditBucket)
+	if err != nil {
+		return nil, err
+	}
+	err = db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+		prev := ""
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var e AuditEntry
+			err := json.Unmarshal(v, &e)
+			if err != nil {
+				return err
+			}
+			// NOTE: only the entries before the first chained one, the unchained ones after it are tampered.
+			if e.Hash != "" {
+				return nil
+			}
+			e.Prev = prev
+			e.Hash, err = e.hash()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			err = b.Put(bytes.Clone(k), data)
+			if err != nil {
+				return err
+			}
+			prev = e.Hash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &AuditStore{db: db}, nil
+}
+
+// Record appends the entry to the audit log, setting its Seq and Time and chaining it to the last one.
+func (s *AuditStore) Record(e AuditEntry) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+		e.Prev = ""
+		if _, v := b.Cursor().Last(); v != nil {
+			var last AuditEntry
+			err := json.Unmarshal(v, &last)
+			if err != nil {
+				return err
+			}
+			e.Prev = last.Hash
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.Seq = seq
+		e.Time = time.Now()
+		e.Hash, err = e.hash()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return b.Put(seqKey(seq), data)
+	})
+}
+
+// List returns the latest entries of the audit log, up to the limit, newest first.
+func (s *AuditStore) List(limit int) ([]AuditEntry, error) {
+	return s.Search("", limit)
+}
+
+// Search returns the latest entries with the query in the actor, the ip, the action, the target
+// or the detail, up to the limit, newest first.
+func (s *AuditStore) Search(query string, limit int) ([]AuditEntry, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var entries []AuditEntry
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(auditBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(entries) < limit; k, v = c.Prev() {
+			var e AuditEntry
+			err := json.Unmarshal(v, &e)
+			if err != nil {
+				return err
+			}
+			if e.matches(query) {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Verify checks the hash chain of the whole audit log and returns the number of the entries.
+// The error tells the first entry that is changed, removed or out of the chain.
+func (s *AuditStore) Verify() (int, error) {
+	n := 0
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+		var prev AuditEntry
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var e AuditEntry
+			err := json.Unmarshal(v, &e)
+			if err != nil {
+				return fmt.Errorf("audit entry %x is unreadable: %v", k, err)
+			}
+			if !bytes.Equal(k, seqKey(e.Seq)) {
+				return fmt.Errorf("audit entry #%d is saved as %x", e.Seq, k)
+			}
+			if e.Seq != prev.Seq+1 {
+				return fmt.Errorf("audit entries #%d to #%d are removed", prev.Seq+1, e.Seq-1)
+			}
+			if e.Prev != prev.Hash {
+				return fmt.Errorf("audit entry #%d is not chained to #%d", e.Seq, prev.Seq)
+			}
+			hash, err := e.hash()
+			if err != nil {
+				return err
+			}
+			if hash != e.Hash {
+				return fmt.Errorf("audit entry #%d is changed", e.Seq)
+			}
+			prev = e
+			n++
+		}
+		// the removed last entries leave the sequence behind.
+		if b.Sequence() != prev.Seq {
+			return fmt.Errorf("audit entries #%d to #%d are removed", prev.Seq+1, b.Sequence())
+		}
+		return nil
+	})
+	return n, err
+}
+
+// seqKey is the big endian key of the seq, so the keys are in order.
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestAuditChain(t *testing.T) {
+	db := openTestDB(t)
+	s, err := NewAuditStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []AuditEntry{
+		{Actor: "alice", IP: "1.2.3.4", Action: AuditLogin, Target: "alice"},
+		{Actor: "alice", IP: "1.2.3.4", Action: AuditAccountCreated, Target: "vmess/1"},
+		{Actor: "bob", IP: "5.6.7.8", Action: AuditAccountDeleted, Target: "vmess/1"},
+	} {
+		err := s.Record(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := s.Verify()
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 verified entries, got %d, %v", n, err)
+	}
+
+	entries, err := s.Search("VMESS/1", 10)
+	if err != nil || len(entries) != 2 || entries[0].Actor != "bob" {
+		t.Fatalf("expected the 2 entries of the target newest first, got %v, %v", entries, err)
+	}
+
+	// changing an entry breaks the chain.
+	err = db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+		var e AuditEntry
+		err := json.Unmarshal(b.Get(seqKey(2)), &e)
+		if err != nil {
+			return err
+		}
+		e.Actor = "mallory"
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return b.Put(seqKey(2), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(); err == nil {
+		t.Error("expected the changed entry to fail the verification")
+	}
+}
+
+func TestAuditRemoved(t *testing.T) {
+	db := openTestDB(t)
+	s, err := NewAuditStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		err := s.Record(AuditEntry{Actor: "alice", Action: AuditLogout})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(auditBucket).Delete(seqKey(2))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(); err == nil {
+		t.Error("expected the removed last entry to fail the verification")
+	}
+}
+
+func TestAuditUnchained(t *testing.T) {
+	db := openTestDB(t)
+	err := db.createBucket(auditBucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the entries recorded before the hash chain.
+	err = db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+		for range 2 {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(AuditEntry{Seq: seq, Actor: "alice", Action: AuditLoginFailed})
+			if err != nil {
+				return err
+			}
+			err = b.Put(seqKey(seq), data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewAuditStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Record(AuditEntry{Actor: "alice", Action: AuditLogin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Verify()
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 verified entries, got %d, %v", n, err)
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	changes := AuditDiff(
+		map[string]string{"username": "a", "password": "old", "device_id": "d"},
+		map[string]string{"username": "b", "password": "new", "device_id": "d"},
+		"password",
+	)
+	want := []AuditChange{{Field: "password", Before: AuditMask, After: AuditMask}, {Field: "username", Before: "a", After: "b"}}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("expected %v, got %v", want[i], changes[i])
+		}
+	}
+}
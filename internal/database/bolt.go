@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DB is the persistent store of the panel, a single bbolt file holding a bucket for each store.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Open opens the database file at the path, creating it and its directory if they don't exist.
+// Only one process can open the file at a time.
+func Open(path string) (*DB, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the database directory: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("Failed to open the database %s: it's used by another process, is the panel running?", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open the database %s: %v", path, err)
+	}
+	return &DB{bolt: db}, nil
+}
+
+// Close closes the database file.
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// createBucket makes sure the bucket exists, to be used by the constructors of the stores.
+func (db *DB) createBucket(name []byte) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(name)
+		return err
+	})
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNoCredits = errors.New("Not enough credits.")
+
+var (
+	creditsBucket  = []byte("credits")
+	balancesBucket = []byte("credit_balances")
+)
+
+// Kinds of the credit entries.
+const (
+	CreditDebit  = "debit"
+	CreditTopUp  = "top-up"
+	CreditRefund = "refund"
+)
+
+// CreditEntry is a change of the credit balance of a reseller.
+type CreditEntry struct {
+	Seq   uint64    `json:"seq"`
+	Time  time.Time `json:"time"`
+	Admin string    `json:"admin"` // the reseller the credits are of.
+	Kind  string    `json:"kind"`
+	// Amount is added to the balance, negative for the debits.
+	Amount  int    `json:"amount"`
+	Balance int    `json:"balance"` // after the entry.
+	Account string `json:"account"` // key of the account paid for, if any.
+	Actor   string `json:"actor"`
+	Note    string `json:"note"`
+	// Pending is the debit whose change isn't done yet, it's left if the panel stops in the middle.
+	Pending bool `json:"pending,omitempty"`
+}
+
+// CreditStore is the credit ledger of the resellers in the database, the balances are kept
+// next to it and changed in the same transaction as the entries.
+type CreditStore struct {
+	db *DB
+}
+
+// NewCreditStore returns the CreditStore of the db.
+func NewCreditStore(db *DB) (*CreditStore, error) {
+	for _, bucket := range [][]byte{creditsBucket, balancesBucket} {
+		err := db.createBucket(bucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &CreditStore{db: db}, nil
+}
+
+// Balance returns the credits of the admin.
+func (s *CreditStore) Balance(admin string) (int, error) {
+	var balance int
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		var err error
+		balance, err = getBalance(tx.Bucket(balancesBucket), admin)
+		return err
+	})
+	return balance, err
+}
+
+// Balances returns the credits of every admin that has a ledger.
+func (s *CreditStore) Balances() (map[string]int, error) {
+	balances := map[string]int{}
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(balancesBucket).ForEach(func(k, v []byte) error {
+			balance, err := strconv.Atoi(string(v))
+			balances[string(k)] = balance
+			return err
+		})
+	})
+	return balances, err
+}
+
+// Credit adds the positive Amount of the top-up or the refund e to the balance of its admin.
+func (s *CreditStore) Credit(e CreditEntry) (CreditEntry, error) {
+	if e.Amount <= 0 {
+		return e, errors.New("The credits must be more than 0.")
+	}
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return record(tx, &e)
+	})
+	return e, err
+}
+
+// Debit takes the amount from the balance of the admin of the e and makes the change of the
+// account paid for, so the change is refused without the credits. The debit is reserved before
+// the change and confirmed after it, or refunded if the change failed, the change is made outside
+// of the transactions so the other writes of the database don't wait for it.
+func (s *CreditStore) Debit(e CreditEntry, amount int, change func() error) (CreditEntry, error) {
+	e.Kind = CreditDebit
+	e.Amount = -amount
+	e.Pending = true
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return record(tx, &e)
+	})
+	if err != nil {
+		return e, err
+	}
+
+	changeErr := change()
+	e.Pending = false
+	err = s.db.bolt.Update(func(tx *bolt.Tx) error {
+		err := putEntry(tx.Bucket(creditsBucket), &e)
+		if err != nil || changeErr == nil {
+			return err
+		}
+		return record(tx, &CreditEntry{
+			Admin:   e.Admin,
+			Kind:    CreditRefund,
+			Amount:  amount,
+			Account: e.Account,
+			Actor:   e.Actor,
+			Note:    "failed change of the debit " + strconv.FormatUint(e.Seq, 10),
+		})
+	})
+	if changeErr != nil {
+		return e, changeErr
+	}
+	return e, err
+}
+
+// History returns the latest entries of the admin, or of everyone if it's empty, up to the limit, newest first.
+func (s *CreditStore) History(admin string, limit int) ([]CreditEntry, error) {
+	var entries []CreditEntry
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(creditsBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(entries) < limit; k, v = c.Prev() {
+			var e CreditEntry
+			err := json.Unmarshal(v, &e)
+			if err != nil {
+				return err
+			}
+			if admin == "" || e.Admin == admin {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// record appends the e to the ledger and changes the balance of its admin by its Amount,
+// refusing to go below zero.
+func record(tx *bolt.Tx, e *CreditEntry) error {
+	balances := tx.Bucket(balancesBucket)
+	balance, err := getBalance(balances, e.Admin)
+	if err != nil {
+		return err
+	}
+	if balance+e.Amount < 0 {
+		return ErrNoCredits
+	}
+	e.Balance = balance + e.Amount
+	err = balances.Put([]byte(e.Admin), []byte(strconv.Itoa(e.Balance)))
+	if err != nil {
+		return err
+	}
+
+	b := tx.Bucket(creditsBucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	e.Seq = seq
+	e.Time = time.Now()
+	return putEntry(b, e)
+}
+
+// putEntry saves the e at its Seq in the ledger b.
+func putEntry(b *bolt.Bucket, e *CreditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.Put(seqKey(e.Seq), data)
+}
+
+func getBalance(b *bolt.Bucket, admin string) (int, error) {
+	data := b.Get([]byte(admin))
+	if data == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(data))
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCredits(t *testing.T) {
+	s, err := NewCreditStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Credit(CreditEntry{Admin: "reseller", Kind: CreditTopUp, Amount: 5, Actor: "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := false
+	e, err := s.Debit(CreditEntry{Admin: "reseller", Account: "1:id"}, 3, func() error {
+		changed = true
+		// the debit is reserved while the change is made, without holding the database.
+		balance, err := s.Balance("reseller")
+		if err != nil || balance != 2 {
+			t.Errorf("expected the reserved balance of 2, got %d, %v", balance, err)
+		}
+		return nil
+	})
+	if err != nil || !changed || e.Balance != 2 || e.Pending {
+		t.Fatalf("unexpected debit %+v, %v", e, err)
+	}
+
+	// the failed changes are refunded.
+	_, err = s.Debit(CreditEntry{Admin: "reseller"}, 1, func() error { return errors.New("failed") })
+	if err == nil {
+		t.Fatal("expected the failed change to fail the debit")
+	}
+	// the changes are not made without the credits.
+	_, err = s.Debit(CreditEntry{Admin: "reseller"}, 3, func() error {
+		t.Error("expected the change not to be made")
+		return nil
+	})
+	if err != ErrNoCredits {
+		t.Errorf("expected ErrNoCredits, got %v", err)
+	}
+
+	balance, err := s.Balance("reseller")
+	if err != nil || balance != 2 {
+		t.Errorf("expected the balance of 2, got %d, %v", balance, err)
+	}
+	history, err := s.History("reseller", 10)
+	if err != nil || len(history) != 4 || history[0].Kind != CreditRefund || history[0].Amount != 1 ||
+		history[1].Amount != -1 || history[2].Amount != -3 || history[3].Kind != CreditTopUp {
+		t.Fatalf("unexpected history %+v, %v", history, err)
+	}
+	for _, e := range history {
+		if e.Pending {
+			t.Errorf("expected the debit %d to be confirmed", e.Seq)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	csrfBucket = []byte("csrf")
+	csrfKeys   = []byte("keys")
+)
+
+// CSRFKeyStore keeps the secret keys of the csrf tokens in the database, so the tokens of the
+// open pages are still valid after a restart. The keys are kept as they are given.
+type CSRFKeyStore struct {
+	db *DB
+}
+
+// NewCSRFKeyStore returns the CSRFKeyStore of the db.
+func NewCSRFKeyStore(db *DB) (*CSRFKeyStore, error) {
+	err := db.createBucket(csrfBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &CSRFKeyStore{db: db}, nil
+}
+
+// Load returns the saved keys, nil if there's none.
+func (s *CSRFKeyStore) Load() ([]byte, error) {
+	var data []byte
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		data = bytes.Clone(tx.Bucket(csrfBucket).Get(csrfKeys))
+		return nil
+	})
+	return data, err
+}
+
+// Save replaces the saved keys with the data.
+func (s *CSRFKeyStore) Save(data []byte) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(csrfBucket).Put(csrfKeys, data)
+	})
+}
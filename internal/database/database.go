@@ -3,7 +3,7 @@ package database
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"strconv"
 	"sync"
 	"time"
@@ -61,6 +61,7 @@ func NewMemSessionStore() *MemSessionStore {
 // CreateSession creates a new session. If the data string is ""(empty), the default
 // user id is added using the sessionCount value of the session store.
 func (store *MemSessionStore) CreateSession(id string, data string) error {
+	cfg := config.Get()
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
@@ -68,13 +69,13 @@ func (store *MemSessionStore) CreateSession(id string, data string) error {
 	if data != "" {
 		store.sessions[id] = Session{
 			Data:      strconv.Itoa(store.sessionCount),
-			ExpiresAt: time.Now().Add(time.Duration(*config.SessionDuration) * time.Minute),
+			ExpiresAt: time.Now().Add(time.Duration(cfg.SessionDuration) * time.Minute),
 		}
 		store.sessionCount++
 	} else {
 		store.sessions[id] = Session{
 			Data:      data,
-			ExpiresAt: time.Now().Add(time.Duration(*config.SessionDuration) * time.Minute),
+			ExpiresAt: time.Now().Add(time.Duration(cfg.SessionDuration) * time.Minute),
 		}
 	}
 
@@ -84,19 +85,21 @@ func (store *MemSessionStore) CreateSession(id string, data string) error {
 // GetSession retrieves a session by ID returning error if the session is invalid or expired.
 func (store *MemSessionStore) GetSession(id string) (Session, error) {
 	store.mu.RLock()
-	defer store.mu.RUnlock()
-
 	session, exists := store.sessions[id]
+	store.mu.RUnlock()
 	if !exists {
 		return Session{}, ErrSessionNotFound
 	}
 
 	// Check if session has expired
 	if time.Now().After(session.ExpiresAt) {
-		// Session expired, delete it.
+		// Session expired, delete it. The read lock is released before, since it can't be upgraded,
+		// and the session is checked again in case it's renewed in between.
 		store.mu.Lock()
-		delete(store.sessions, id)
-		store.mu.Unlock()
+		defer store.mu.Unlock()
+		if current, exists := store.sessions[id]; exists && time.Now().After(current.ExpiresAt) {
+			delete(store.sessions, id)
+		}
 		return Session{}, ErrSessionExpired
 	}
 
@@ -133,19 +136,20 @@ func (store *MemSessionStore) CleanupExpiredSessions() error {
 
 // periodicCleanup runs CleanupExpiredSessions at regular intervals.
 func (store *MemSessionStore) periodicCleanup() {
+	cfg := config.Get()
 	// Interval is fourth of the configured session duration.
 	var ticker *time.Ticker
-	if *config.SessionDuration < 4 {
-		ticker = time.NewTicker(time.Duration(*config.SessionDuration) * time.Minute)
+	if cfg.SessionDuration < 4 {
+		ticker = time.NewTicker(time.Duration(cfg.SessionDuration) * time.Minute)
 	} else {
-		ticker = time.NewTicker((time.Duration(*config.SessionDuration) / 4) * time.Minute)
+		ticker = time.NewTicker((time.Duration(cfg.SessionDuration) / 4) * time.Minute)
 	}
 	defer ticker.Stop()
 
 	for {
 		<-ticker.C
 		if err := store.CleanupExpiredSessions(); err != nil {
-			log.Println("ERROR: Cleaning expired session gone wrong.")
+			slog.Error("ERROR: Cleaning expired session gone wrong.")
 			continue
 		}
 	}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var lockoutsBucket = []byte("lockouts")
+
+// maxBackOff caps the wait between the failed attempts before the lockout.
+const maxBackOff = 5 * time.Minute
+
+// Lockout is the failed login attempts of a username or an ip address.
+type Lockout struct {
+	// Key is made by the UserKey or the IPKey.
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	// Until is the time the next attempt is allowed after.
+	Until time.Time `json:"until"`
+	// Locked is true when the max attempts are reached, otherwise Until is only a back-off.
+	Locked bool `json:"locked"`
+}
+
+// UserKey is the lockout key of the username.
+func UserKey(username string) string {
+	return "user:" + username
+}
+
+// IPKey is the lockout key of the ip address.
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LockoutStore tracks the failed login attempts in the database.
+type LockoutStore struct {
+	db *DB
+}
+
+// NewLockoutStore returns the LockoutStore of the db.
+func NewLockoutStore(db *DB) (*LockoutStore, error) {
+	err := db.createBucket(lockoutsBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &LockoutStore{db: db}, nil
+}
+
+// Check returns the time the next attempt is allowed after for all of the keys,
+// the zero time if it's allowed now.
+func (s *LockoutStore) Check(keys ...string) (time.Time, error) {
+	var until time.Time
+	now := time.Now()
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(lockoutsBucket)
+		for _, key := range keys {
+			l, err := getLockout(b, key)
+			if err != nil {
+				return err
+			}
+			if l.Until.After(now) && l.Until.After(until) {
+				until = l.Until
+			}
+		}
+		return nil
+	})
+	return until, err
+}
+
+// Fail records a failed attempt of the key. The wait before the next attempt doubles with each
+// failure until the maxAttempts is reached, then the key is locked for the lockout duration.
+// The failures older than the lockout duration are forgotten.
+func (s *LockoutStore) Fail(key string, maxAttempts int, lockout time.Duration) (Lockout, error) {
+	var l Lockout
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(lockoutsBucket)
+		var err error
+		l, err = getLockout(b, key)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if now.Sub(l.LastFailure) > lockout && now.After(l.Until) {
+			l = Lockout{Key: key}
+		}
+
+		l.Failures++
+		l.LastFailure = now
+		if l.Failures >= maxAttempts {
+			l.Locked = true
+			l.Until = now.Add(lockout)
+		} else {
+			l.Until = now.Add(backOff(l.Failures))
+		}
+		return putLockout(b, l)
+	})
+	return l, err
+}
+
+// Reset forgets the failed attempts of the key, unlocking it.
+func (s *LockoutStore) Reset(key string) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(lockoutsBucket).Delete([]byte(key))
+	})
+}
+
+// List returns the keys that are locked out or waiting for the back-off right now.
+func (s *LockoutStore) List() ([]Lockout, error) {
+	var lockouts []Lockout
+	now := time.Now()
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(lockoutsBucket).ForEach(func(_, v []byte) error {
+			var l Lockout
+			err := json.Unmarshal(v, &l)
+			if err != nil {
+				return err
+			}
+			if l.Until.After(now) {
+				lockouts = append(lockouts, l)
+			}
+			return nil
+		})
+	})
+	return lockouts, err
+}
+
+// backOff is the wait after the failures, 1s, 2s, 4s, ...
+func backOff(failures int) time.Duration {
+	if failures > 16 {
+		return maxBackOff
+	}
+	return min(time.Second<<(failures-1), maxBackOff)
+}
+
+func getLockout(b *bolt.Bucket, key string) (Lockout, error) {
+	l := Lockout{Key: key}
+	data := b.Get([]byte(key))
+	if data == nil {
+		return l, nil
+	}
+	err := json.Unmarshal(data, &l)
+	return l, err
+}
+
+func putLockout(b *bolt.Bucket, l Lockout) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(l.Key), data)
+}
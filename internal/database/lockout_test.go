@@ -0,0 +1,72 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLockout(t *testing.T) {
+	s, err := NewLockoutStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := UserKey("admin")
+
+	for i := 1; i < 3; i++ {
+		l, err := s.Fail(key, 3, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Locked {
+			t.Fatalf("locked after %d failures", i)
+		}
+		if wait := time.Until(l.Until); wait > backOff(i) || wait < backOff(i)-time.Second {
+			t.Errorf("expected the back-off of %v after %d failures, got %v", backOff(i), i, wait)
+		}
+	}
+
+	l, err := s.Fail(key, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Locked || time.Until(l.Until) < 59*time.Minute {
+		t.Errorf("expected to be locked for the lockout duration, got %+v", l)
+	}
+
+	until, err := s.Check(IPKey("10.0.0.1"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !until.Equal(l.Until) {
+		t.Errorf("expected the check to be locked until %v, got %v", l.Until, until)
+	}
+
+	err = s.Reset(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	until, err = s.Check(key)
+	if err != nil || !until.IsZero() {
+		t.Errorf("expected to be unlocked after the reset, got %v, %v", until, err)
+	}
+}
+
+func TestBackOff(t *testing.T) {
+	if backOff(1) != time.Second || backOff(3) != 4*time.Second {
+		t.Errorf("unexpected back-off: %v, %v", backOff(1), backOff(3))
+	}
+	if backOff(100) != maxBackOff {
+		t.Errorf("expected the back-off to be capped, got %v", backOff(100))
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+var ownersBucket = []byte("owners")
+
+// OwnerStore keeps the admin who owns each account in the database. The accounts are kept by the
+// vpn services themselves, so they are known by the AccountKey only. The accounts made before
+// the owners were kept have no owner.
+type OwnerStore struct {
+	db *DB
+}
+
+// NewOwnerStore returns the OwnerStore of the db.
+func NewOwnerStore(db *DB) (*OwnerStore, error) {
+	err := db.createBucket(ownersBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &OwnerStore{db: db}, nil
+}
+
+// AccountKey is the key of the account of the type with the id, the server id of the vmess,
+// the password of the shadowsocks and the username of the sstp accounts.
+func AccountKey(accountType, id string) string {
+	return accountType + ":" + id
+}
+
+// Get returns the owner of the account with the key, empty if it has none.
+func (s *OwnerStore) Get(key string) (string, error) {
+	var owner string
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		owner = string(tx.Bucket(ownersBucket).Get([]byte(key)))
+		return nil
+	})
+	return owner, err
+}
+
+// Set makes the admin the owner of the account with the key, returning the previous owner.
+func (s *OwnerStore) Set(key, admin string) (string, error) {
+	var previous string
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(ownersBucket)
+		previous = string(b.Get([]byte(key)))
+		return b.Put([]byte(key), []byte(admin))
+	})
+	return previous, err
+}
+
+// Remove forgets the owner of the deleted account with the key.
+func (s *OwnerStore) Remove(key string) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(ownersBucket).Delete([]byte(key))
+	})
+}
+
+// Owned returns the keys of the accounts owned by the admin.
+func (s *OwnerStore) Owned(admin string) (map[string]bool, error) {
+	owned := map[string]bool{}
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(ownersBucket).ForEach(func(k, v []byte) error {
+			if string(v) == admin {
+				owned[string(k)] = true
+			}
+			return nil
+		})
+	})
+	return owned, err
+}
@@ -0,0 +1,40 @@
+package database
+
+import "testing"
+
+func TestOwners(t *testing.T) {
+	s, err := NewOwnerStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vmess, sstp := AccountKey("vmess", "id"), AccountKey("sstp", "user")
+	for key, admin := range map[string]string{vmess: "reseller", sstp: "other"} {
+		if _, err := s.Set(key, admin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	owned, err := s.Owned("reseller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 1 || !owned[vmess] {
+		t.Errorf("unexpected owned accounts %v", owned)
+	}
+
+	previous, err := s.Set(vmess, "other")
+	if err != nil || previous != "reseller" {
+		t.Errorf("unexpected previous owner %q, %v", previous, err)
+	}
+	if owner, _ := s.Get(vmess); owner != "other" {
+		t.Errorf("expected the account to be transferred, got %q", owner)
+	}
+
+	if err := s.Remove(vmess); err != nil {
+		t.Fatal(err)
+	}
+	if owner, _ := s.Get(vmess); owner != "" {
+		t.Errorf("expected no owner after the removal, got %q", owner)
+	}
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrPasskeyNotFound = errors.New("Passkey not found.")
+
+var passkeysBucket = []byte("passkeys")
+
+// Passkey is a security key or a platform passkey of an admin.
+type Passkey struct {
+	ID    []byte `json:"id"` // credential id.
+	Admin string `json:"admin"`
+	Name  string `json:"name"`
+	// PublicKey is the COSE encoded public key of the credential.
+	PublicKey []byte    `json:"public_key"`
+	SignCount uint32    `json:"sign_count"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// PasskeyStore keeps the passkeys of the admins in the database.
+type PasskeyStore struct {
+	db *DB
+}
+
+// NewPasskeyStore returns the PasskeyStore of the db.
+func NewPasskeyStore(db *DB) (*PasskeyStore, error) {
+	err := db.createBucket(passkeysBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyStore{db: db}, nil
+}
+
+// Add adds the new passkey.
+func (s *PasskeyStore) Add(p Passkey) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(passkeysBucket)
+		if b.Get(p.ID) != nil {
+			return errors.New("Passkey already exists.")
+		}
+		p.CreatedAt = time.Now()
+		return putPasskey(b, p)
+	})
+}
+
+// Get returns the passkey with the credential id.
+func (s *PasskeyStore) Get(id []byte) (Passkey, error) {
+	var p Passkey
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(passkeysBucket).Get(id)
+		if data == nil {
+			return ErrPasskeyNotFound
+		}
+		return json.Unmarshal(data, &p)
+	})
+	return p, err
+}
+
+// List returns the passkeys of the admin.
+func (s *PasskeyStore) List(admin string) ([]Passkey, error) {
+	var passkeys []Passkey
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(passkeysBucket).ForEach(func(_, v []byte) error {
+			var p Passkey
+			err := json.Unmarshal(v, &p)
+			if err != nil {
+				return err
+			}
+			if p.Admin == admin {
+				passkeys = append(passkeys, p)
+			}
+			return nil
+		})
+	})
+	return passkeys, err
+}
+
+// Used saves the signature counter of the passkey after a login.
+func (s *PasskeyStore) Used(id []byte, signCount uint32) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(passkeysBucket)
+		data := b.Get(id)
+		if data == nil {
+			return ErrPasskeyNotFound
+		}
+		var p Passkey
+		err := json.Unmarshal(data, &p)
+		if err != nil {
+			return err
+		}
+		p.SignCount = signCount
+		p.LastUsed = time.Now()
+		return putPasskey(b, p)
+	})
+}
+
+// Remove removes the passkey of the admin.
+func (s *PasskeyStore) Remove(admin string, id []byte) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(passkeysBucket)
+		data := b.Get(id)
+		if data == nil {
+			return ErrPasskeyNotFound
+		}
+		var p Passkey
+		err := json.Unmarshal(data, &p)
+		if err != nil {
+			return err
+		}
+		if p.Admin != admin {
+			return ErrPasskeyNotFound
+		}
+		return b.Delete(id)
+	})
+}
+
+// RemoveAll removes every passkey of the admin, returning how many are removed.
+func (s *PasskeyStore) RemoveAll(admin string) (int, error) {
+	var n int
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(passkeysBucket)
+		// the keys are deleted after the iteration, deleting with the cursor skips the next key.
+		var ids [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var p Passkey
+			err := json.Unmarshal(v, &p)
+			if err != nil {
+				return err
+			}
+			if p.Admin == admin {
+				ids = append(ids, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			err := b.Delete(id)
+			if err != nil {
+				return err
+			}
+		}
+		n = len(ids)
+		return nil
+	})
+	return n, err
+}
+
+func putPasskey(b *bolt.Bucket, p Passkey) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return b.Put(p.ID, data)
+}
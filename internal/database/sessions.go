@@ -0,0 +1,353 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sessionsBucket    = []byte("sessions")
+	sessionInfoBucket = []byte("session_info")
+)
+
+// sessionSeenInterval is how often the last seen time of a session is saved, not to write on every request.
+const sessionSeenInterval = time.Minute
+
+// SessionInfo is where and when an admin logged in with the session.
+type SessionInfo struct {
+	// ID is the key of the session in the store, not the token of its cookie.
+	ID        string    `json:"id"`
+	Admin     string    `json:"admin"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SessionKey returns the key of the session with the token in the store, the same way the
+// session manager keeps it with the HashTokenInStore.
+func SessionKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// BoltSessionStore is the scs.Store of the sessions of the admins in the database, so they are
+// kept over the restarts. Each session is kept as its expiry in unix nanoseconds followed by its data.
+type BoltSessionStore struct {
+	db       *DB
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewBoltSessionStore returns the BoltSessionStore of the db, removing the expired sessions
+// every cleanupInterval until the StopCleanup, never if it's 0. The expired sessions are never found anyway.
+func NewBoltSessionStore(db *DB, cleanupInterval time.Duration) (*BoltSessionStore, error) {
+	for _, bucket := range [][]byte{sessionsBucket, sessionInfoBucket} {
+		err := db.createBucket(bucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s := &BoltSessionStore{db: db, stop: make(chan struct{})}
+	if cleanupInterval > 0 {
+		go s.cleanup(cleanupInterval)
+	}
+	return s, nil
+}
+
+// Find returns the data of the session with the token, found is false if it doesn't exist or it's expired.
+func (s *BoltSessionStore) Find(token string) (data []byte, found bool, err error) {
+	err = s.db.bolt.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(sessionsBucket).Get([]byte(token))
+		if v == nil || sessionExpired(v, time.Now()) {
+			return nil
+		}
+		data, found = bytes.Clone(v[8:]), true
+		return nil
+	})
+	return data, found, err
+}
+
+// Commit saves the data of the session with the token until the expiry.
+func (s *BoltSessionStore) Commit(token string, data []byte, expiry time.Time) error {
+	v := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(v, uint64(expiry.UnixNano()))
+	v = append(v, data...)
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(token), v)
+	})
+}
+
+// Delete removes the session with the token and its info.
+func (s *BoltSessionStore) Delete(token string) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return deleteSession(tx, []byte(token))
+	})
+}
+
+// All returns the data of every session that is not expired by the tokens, for the scs.IterableStore.
+func (s *BoltSessionStore) All() (map[string][]byte, error) {
+	sessions := map[string][]byte{}
+	now := time.Now()
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			if !sessionExpired(v, now) {
+				sessions[string(k)] = bytes.Clone(v[8:])
+			}
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+// Cleanup removes the expired sessions and the info of the sessions that are gone, returning
+// how many sessions are removed.
+func (s *BoltSessionStore) Cleanup() (int, error) {
+	var n int
+	now := time.Now()
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		// the keys are deleted after the iteration, deleting with the cursor skips the next key.
+		var tokens [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if sessionExpired(v, now) {
+				tokens = append(tokens, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			err = b.Delete(token)
+			if err != nil {
+				return err
+			}
+		}
+		n = len(tokens)
+
+		infos := tx.Bucket(sessionInfoBucket)
+		var gone [][]byte
+		err = infos.ForEach(func(k, _ []byte) error {
+			if b.Get(k) == nil {
+				gone = append(gone, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, token := range gone {
+			err = infos.Delete(token)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Track saves the info of the new session with the key of the info.ID.
+func (s *BoltSessionStore) Track(info SessionInfo) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return putSessionInfo(tx.Bucket(sessionInfoBucket), info)
+	})
+}
+
+// Seen saves that the session with the key is used from the ip at the now, at most once in
+// a sessionSeenInterval. The sessions of the admin logged in before they are tracked get their info here.
+func (s *BoltSessionStore) Seen(key, admin, ip, userAgent string, now time.Time) error {
+	var info SessionInfo
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		var err error
+		info, err = getSessionInfo(tx.Bucket(sessionInfoBucket), key)
+		return err
+	})
+	if err == nil && info.IP == ip && now.Sub(info.LastSeen) < sessionSeenInterval {
+		return nil
+	}
+	if err != nil && err != ErrSessionNotFound {
+		return err
+	}
+
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionInfoBucket)
+		info, err := getSessionInfo(b, key)
+		if err == ErrSessionNotFound {
+			info = SessionInfo{ID: key, Admin: admin, UserAgent: userAgent, CreatedAt: now}
+		} else if err != nil {
+			return err
+		}
+		info.IP = ip
+		info.LastSeen = now
+		return putSessionInfo(b, info)
+	})
+}
+
+// Sessions returns the sessions of the admin that are not expired, the last seen one first.
+func (s *BoltSessionStore) Sessions(admin string) ([]SessionInfo, error) {
+	var infos []SessionInfo
+	now := time.Now()
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		return tx.Bucket(sessionInfoBucket).ForEach(func(k, v []byte) error {
+			var info SessionInfo
+			err := json.Unmarshal(v, &info)
+			if err != nil {
+				return err
+			}
+			// the new sessions are tracked before they are saved at the end of the login request.
+			data := sessions.Get(k)
+			if info.Admin != admin || (data != nil && sessionExpired(data, now)) {
+				return nil
+			}
+			infos = append(infos, info)
+			return nil
+		})
+	})
+	sort.Slice(infos, func(i, j int) bool { return infos[i].LastSeen.After(infos[j].LastSeen) })
+	return infos, err
+}
+
+// Revoke logs the admin out of the session with the key.
+func (s *BoltSessionStore) Revoke(admin, key string) (SessionInfo, error) {
+	var info SessionInfo
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		var err error
+		info, err = getSessionInfo(tx.Bucket(sessionInfoBucket), key)
+		if err != nil {
+			return err
+		}
+		if info.Admin != admin {
+			return ErrSessionNotFound
+		}
+		return deleteSession(tx, []byte(key))
+	})
+	return info, err
+}
+
+// RevokeAll logs the admin out of every session except the one with the except key,
+// returning how many are revoked.
+func (s *BoltSessionStore) RevokeAll(admin, except string) (int, error) {
+	infos, err := s.Sessions(admin)
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	for _, info := range infos {
+		if info.ID != except {
+			keys = append(keys, info.ID)
+		}
+	}
+	return len(keys), s.revoke(keys)
+}
+
+// Limit logs the admin out of the least recently seen sessions over the max, keeping the
+// one with the keep key. There's no limit if the max is 0. The revoked sessions are returned.
+func (s *BoltSessionStore) Limit(admin, keep string, max int) ([]SessionInfo, error) {
+	if max <= 0 {
+		return nil, nil
+	}
+	infos, err := s.Sessions(admin)
+	if err != nil || len(infos) <= max {
+		return nil, err
+	}
+
+	// the kept session counts as the first one, the rest are kept by the last seen order.
+	var revoked []SessionInfo
+	kept := 1
+	var keys []string
+	for _, info := range infos {
+		if info.ID == keep {
+			continue
+		}
+		if kept < max {
+			kept++
+			continue
+		}
+		revoked = append(revoked, info)
+		keys = append(keys, info.ID)
+	}
+	return revoked, s.revoke(keys)
+}
+
+func (s *BoltSessionStore) revoke(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			err := deleteSession(tx, []byte(key))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func deleteSession(tx *bolt.Tx, key []byte) error {
+	err := tx.Bucket(sessionInfoBucket).Delete(key)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(sessionsBucket).Delete(key)
+}
+
+func getSessionInfo(b *bolt.Bucket, key string) (SessionInfo, error) {
+	var info SessionInfo
+	v := b.Get([]byte(key))
+	if v == nil {
+		return info, ErrSessionNotFound
+	}
+	err := json.Unmarshal(v, &info)
+	return info, err
+}
+
+func putSessionInfo(b *bolt.Bucket, info SessionInfo) error {
+	v, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(info.ID), v)
+}
+
+// StopCleanup stops removing the expired sessions, to be called before closing the db.
+func (s *BoltSessionStore) StopCleanup() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *BoltSessionStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			n, err := s.Cleanup()
+			if err != nil {
+				slog.Error("ERROR: Cleaning expired session gone wrong.", "err", err)
+				continue
+			}
+			if n != 0 {
+				slog.Debug("Expired sessions are removed.", "count", n)
+			}
+		}
+	}
+}
+
+// sessionExpired reports whether the saved session v is expired at the now, the invalid ones are expired.
+func sessionExpired(v []byte, now time.Time) bool {
+	return len(v) < 8 || now.UnixNano() > int64(binary.BigEndian.Uint64(v[:8]))
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoltSessionStore(t *testing.T) {
+	s, err := NewBoltSessionStore(openTestDB(t), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.StopCleanup()
+
+	if err := s.Commit("live", []byte("data"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit("expired", []byte("old"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, found, err := s.Find("live")
+	if err != nil || !found || string(data) != "data" {
+		t.Errorf("unexpected session %q %v, %v", data, found, err)
+	}
+	if _, found, _ := s.Find("expired"); found {
+		t.Error("expected the expired session not to be found")
+	}
+	if all, _ := s.All(); len(all) != 1 {
+		t.Errorf("unexpected sessions %v", all)
+	}
+
+	if n, err := s.Cleanup(); err != nil || n != 1 {
+		t.Errorf("unexpected removed sessions %d, %v", n, err)
+	}
+	if err := s.Delete("live"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Find("live"); found {
+		t.Error("expected the deleted session not to be found")
+	}
+}
+
+func TestSessionInfo(t *testing.T) {
+	s, err := NewBoltSessionStore(openTestDB(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i, key := range []string{"a", "b", "c"} {
+		if err := s.Commit(key, []byte("data"), now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		seen := now.Add(time.Duration(i) * time.Second)
+		if err := s.Track(SessionInfo{ID: key, Admin: "admin", IP: "1.1.1.1", CreatedAt: seen, LastSeen: seen}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Seen("other", "other", "2.2.2.2", "agent", now); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := s.Sessions("admin")
+	if err != nil || len(infos) != 3 || infos[0].ID != "c" {
+		t.Fatalf("unexpected sessions %v, %v", infos, err)
+	}
+	if _, err := s.Revoke("other", "a"); err != ErrSessionNotFound {
+		t.Errorf("expected the session of another admin not to be revoked, got %v", err)
+	}
+
+	revoked, err := s.Limit("admin", "a", 2)
+	if err != nil || len(revoked) != 1 || revoked[0].ID != "b" {
+		t.Errorf("unexpected revoked sessions %v, %v", revoked, err)
+	}
+	if _, found, _ := s.Find("b"); found {
+		t.Error("expected the revoked session not to be found")
+	}
+
+	if n, err := s.RevokeAll("admin", "c"); err != nil || n != 1 {
+		t.Errorf("unexpected revoked sessions %d, %v", n, err)
+	}
+	if infos, _ := s.Sessions("admin"); len(infos) != 1 || infos[0].ID != "c" {
+		t.Errorf("unexpected sessions %v", infos)
+	}
+
+	// the info of the sessions that are gone is cleaned up.
+	if infos, _ := s.Sessions("other"); len(infos) != 1 {
+		t.Errorf("unexpected sessions %v", infos)
+	}
+	if _, err := s.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if infos, _ := s.Sessions("other"); len(infos) != 0 {
+		t.Errorf("expected the info of the missing session to be removed, got %v", infos)
+	}
+}
+
+func TestMemSessionExpired(t *testing.T) {
+	store := &MemSessionStore{sessions: map[string]Session{"id": {ExpiresAt: time.Now().Add(-time.Second)}}}
+
+	done := make(chan error)
+	go func() {
+		_, err := store.GetSession("id")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != ErrSessionExpired {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("getting the expired session is deadlocked")
+	}
+	if _, exists := store.sessions["id"]; exists {
+		t.Error("expected the expired session to be deleted")
+	}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/htetmyatthar/lothone/internal/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNotSuspended = errors.New("Account is not suspended.")
+
+var suspendedBucket = []byte("suspended_accounts")
+
+// SuspendedAccount is a v2ray account taken off the server, kept to be put back as it was.
+type SuspendedAccount struct {
+	Key         string       `json:"key"`
+	Type        string       `json:"type"`
+	Client      utils.Client `json:"client"`
+	Actor       string       `json:"actor"`
+	SuspendedAt time.Time    `json:"suspended_at"`
+}
+
+// SuspendedStore keeps the suspended accounts in the database, by their AccountKey.
+type SuspendedStore struct {
+	db *DB
+}
+
+// NewSuspendedStore returns the SuspendedStore of the db.
+func NewSuspendedStore(db *DB) (*SuspendedStore, error) {
+	err := db.createBucket(suspendedBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &SuspendedStore{db: db}, nil
+}
+
+// Add keeps the suspended account a.
+func (s *SuspendedStore) Add(a SuspendedAccount) error {
+	a.SuspendedAt = time.Now()
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(suspendedBucket)
+		if b.Get([]byte(a.Key)) != nil {
+			return errors.New("Account is already suspended.")
+		}
+		return b.Put([]byte(a.Key), data)
+	})
+}
+
+// Get returns the suspended account with the key.
+func (s *SuspendedStore) Get(key string) (SuspendedAccount, error) {
+	var a SuspendedAccount
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(suspendedBucket).Get([]byte(key))
+		if data == nil {
+			return ErrNotSuspended
+		}
+		return json.Unmarshal(data, &a)
+	})
+	return a, err
+}
+
+// List returns the suspended accounts of the type.
+func (s *SuspendedStore) List(accountType string) ([]SuspendedAccount, error) {
+	var accounts []SuspendedAccount
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(suspendedBucket).ForEach(func(_, v []byte) error {
+			var a SuspendedAccount
+			err := json.Unmarshal(v, &a)
+			if err != nil {
+				return err
+			}
+			if a.Type == accountType {
+				accounts = append(accounts, a)
+			}
+			return nil
+		})
+	})
+	return accounts, err
+}
+
+// Remove forgets the account with the key after it's put back or deleted.
+func (s *SuspendedStore) Remove(key string) error {
+	return s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(suspendedBucket).Delete([]byte(key))
+	})
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/htetmyatthar/lothone/internal/utils"
+)
+
+func TestSuspended(t *testing.T) {
+	s, err := NewSuspendedStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := AccountKey("vmess", "id")
+	a := SuspendedAccount{Key: key, Type: "vmess", Client: utils.Client{Id: "id", Username: "user"}}
+	if err := s.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(a); err == nil {
+		t.Error("expected the suspended account not to be suspended again")
+	}
+
+	accounts, err := s.List("vmess")
+	if err != nil || len(accounts) != 1 || accounts[0].Client.Username != "user" {
+		t.Errorf("unexpected suspended accounts %v, %v", accounts, err)
+	}
+	if accounts, _ := s.List("shadowsocks"); len(accounts) != 0 {
+		t.Errorf("unexpected suspended accounts of another type %v", accounts)
+	}
+
+	if err := s.Remove(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(key); err != ErrNotSuspended {
+		t.Errorf("expected the removed account not to be suspended, %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrTokenNotFound = errors.New("API token not found.")
+	ErrTokenExpired  = errors.New("API token expired.")
+)
+
+var tokensBucket = []byte("api_tokens")
+
+// tokenPrefix starts every api token, so the leaked ones can be found by the secret scanners.
+const tokenPrefix = "lt_"
+
+// tokenUsedInterval is how often the LastUsed of a token is saved, so every api request doesn't write the database.
+const tokenUsedInterval = time.Minute
+
+// APIToken is a named api token of an admin, only the hash of the secret is kept.
+type APIToken struct {
+	ID     string   `json:"id"`
+	Admin  string   `json:"admin"`
+	Name   string   `json:"name"`
+	Hash   string   `json:"hash"` // hex sha256 of the secret.
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is zero for the tokens that don't expire.
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// Expired reports whether the token is expired at the now.
+func (t APIToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt)
+}
+
+// TokenStore keeps the api tokens of the admins in the database.
+type TokenStore struct {
+	db *DB
+}
+
+// NewTokenStore returns the TokenStore of the db.
+func NewTokenStore(db *DB) (*TokenStore, error) {
+	err := db.createBucket(tokensBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenStore{db: db}, nil
+}
+
+// Create makes a new api token of the admin, returning the token to be shown once.
+func (s *TokenStore) Create(admin, name string, scopes []string, expiresAt time.Time) (string, APIToken, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", APIToken{}, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", APIToken{}, err
+	}
+
+	t := APIToken{
+		ID:        id,
+		Admin:     admin,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	err = s.db.bolt.Update(func(tx *bolt.Tx) error {
+		return putToken(tx.Bucket(tokensBucket), t)
+	})
+	if err != nil {
+		return "", APIToken{}, err
+	}
+	return tokenPrefix + id + "_" + secret, t, nil
+}
+
+// Authenticate returns the admin and the scopes of the valid token, and saves when it's used.
+func (s *TokenStore) Authenticate(token string) (string, []string, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), "_")
+	if !ok || !strings.HasPrefix(token, tokenPrefix) {
+		return "", nil, ErrTokenNotFound
+	}
+
+	var t APIToken
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		var err error
+		t, err = getToken(tx.Bucket(tokensBucket), id)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hashSecret(secret))) != 1 {
+		return "", nil, ErrTokenNotFound
+	}
+	now := time.Now()
+	if t.Expired(now) {
+		return "", nil, ErrTokenExpired
+	}
+
+	if now.Sub(t.LastUsed) >= tokenUsedInterval {
+		err = s.db.bolt.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(tokensBucket)
+			t, err := getToken(b, id)
+			if err != nil {
+				return err
+			}
+			t.LastUsed = now
+			return putToken(b, t)
+		})
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return t.Admin, t.Scopes, nil
+}
+
+// List returns the api tokens of the admin.
+func (s *TokenStore) List(admin string) ([]APIToken, error) {
+	var tokens []APIToken
+	err := s.db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).ForEach(func(_, v []byte) error {
+			var t APIToken
+			err := json.Unmarshal(v, &t)
+			if err != nil {
+				return err
+			}
+			if t.Admin == admin {
+				tokens = append(tokens, t)
+			}
+			return nil
+		})
+	})
+	return tokens, err
+}
+
+// Revoke removes the api token of the admin, returning the removed token.
+func (s *TokenStore) Revoke(admin, id string) (APIToken, error) {
+	var t APIToken
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokensBucket)
+		var err error
+		t, err = getToken(b, id)
+		if err != nil {
+			return err
+		}
+		if t.Admin != admin {
+			return ErrTokenNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+	return t, err
+}
+
+// RemoveAll removes every api token of the admin, returning how many are removed.
+func (s *TokenStore) RemoveAll(admin string) (int, error) {
+	var n int
+	err := s.db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokensBucket)
+		// the keys are deleted after the iteration, deleting with the cursor skips the next key.
+		var ids [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var t APIToken
+			err := json.Unmarshal(v, &t)
+			if err != nil {
+				return err
+			}
+			if t.Admin == admin {
+				ids = append(ids, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			err = b.Delete(id)
+			if err != nil {
+				return err
+			}
+		}
+		n = len(ids)
+		return nil
+	})
+	return n, err
+}
+
+func getToken(b *bolt.Bucket, id string) (APIToken, error) {
+	var t APIToken
+	data := b.Get([]byte(id))
+	if data == nil {
+		return t, ErrTokenNotFound
+	}
+	return t, json.Unmarshal(data, &t)
+}
+
+func putToken(b *bolt.Bucket, t APIToken) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(t.ID), data)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
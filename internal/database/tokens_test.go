@@ -0,0 +1,46 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	s, err := NewTokenStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, created, err := s.Create("admin", "ci", []string{"accounts:read"}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, scopes, err := s.Authenticate(token)
+	if err != nil || admin != "admin" || len(scopes) != 1 {
+		t.Fatalf("unexpected authentication %q %v, %v", admin, scopes, err)
+	}
+	if _, _, err := s.Authenticate(token + "x"); err != ErrTokenNotFound {
+		t.Errorf("wrong secret is accepted, %v", err)
+	}
+
+	expired, _, err := s.Create("admin", "old", nil, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Authenticate(expired); err != ErrTokenExpired {
+		t.Errorf("expired token is accepted, %v", err)
+	}
+
+	if _, err := s.Revoke("other", created.ID); err != ErrTokenNotFound {
+		t.Errorf("token of another admin is revoked, %v", err)
+	}
+	if _, err := s.Revoke("admin", created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Authenticate(token); err != ErrTokenNotFound {
+		t.Errorf("revoked token is accepted, %v", err)
+	}
+	if n, err := s.RemoveAll("admin"); err != nil || n != 1 {
+		t.Errorf("unexpected removed tokens %d, %v", n, err)
+	}
+}
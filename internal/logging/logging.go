@@ -0,0 +1,120 @@
+// Package logging sets up the log/slog logger of the panel, with the secrets redacted, and
+// gives the handlers the logger of their request.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/htetmyatthar/lothone/middleware/clientip"
+)
+
+// The formats of the logs.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// level is the level of the logger of the Setup, changed without making the logger again.
+var level slog.LevelVar
+
+// ParseLevel returns the level of the name, debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(name))
+	if err != nil {
+		return 0, fmt.Errorf("unknown log level %q, must be debug, info, warn or error", name)
+	}
+	return l, nil
+}
+
+// Setup makes the logger writing to the w in the format, from the l, the default one. The logs
+// of the log package are also written by it.
+func Setup(w io.Writer, format string, l slog.Level) error {
+	level.Set(l)
+	options := &slog.HandlerOptions{Level: &level}
+	var h slog.Handler
+	switch format {
+	case FormatText:
+		h = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format %q, must be %s or %s", format, FormatText, FormatJSON)
+	}
+	slog.SetDefault(slog.New(NewRedactHandler(h)))
+	return nil
+}
+
+// SetLevel changes the level of the logger of the Setup.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+type loggerKey struct{}
+
+// requestLogger is the logger of a request, the ones put in later by the With are also used by the
+// Middleware for the log of the finished request.
+type requestLogger struct {
+	logger *slog.Logger
+}
+
+// FromContext returns the logger of the request of the ctx, the default one if there's none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*requestLogger); ok {
+		return l.logger
+	}
+	return slog.Default()
+}
+
+// With adds the args to the logger of the request r, for the rest of the request.
+func With(r *http.Request, args ...any) {
+	if l, ok := r.Context().Value(loggerKey{}).(*requestLogger); ok {
+		l.logger = l.logger.With(args...)
+	}
+}
+
+// Middleware gives every request a logger with its own id, which is also sent back as the
+// X-Request-Id header, and logs the request when it's finished. It must be used after the
+// clientip.Middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := newRequestID()
+		w.Header().Set("X-Request-Id", id)
+
+		l := &requestLogger{logger: slog.Default().With("request_id", id)}
+		r = r.WithContext(context.WithValue(r.Context(), loggerKey{}, l))
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			l.logger.Info("request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"ip", clientip.FromRequest(r).String(),
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"duration", time.Since(start),
+			)
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// newRequestID returns a random id of 64 bits.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactHandler(slog.NewTextHandler(&buf, nil)))
+
+	id := "0b6c7e4e-3f1a-4c2d-9e8f-1a2b3c4d5e6f"
+	logger.With("api_key", "lk_abcdef").Info("account "+id+" is made.",
+		"password", "hunter2",
+		"empty_password", "",
+		"server_id", id,
+		"err", errors.New("no account "+id),
+		slog.Group("form", "csrf_token", "abc"),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "lk_abcdef", "abc ", "0b6c7e4e"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q redacted, got %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "5e6f") || !strings.Contains(out, "password="+Redacted) {
+		t.Errorf("expected the masked uuids and the redacted password, got %s", out)
+	}
+	if !strings.Contains(out, `empty_password=""`) {
+		t.Errorf("expected the empty secret kept, got %s", out)
+	}
+}
+
+func TestRedactHandlerStructs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactHandler(slog.NewTextHandler(&buf, nil)))
+
+	type params struct {
+		Name         string
+		AuthPassword string
+	}
+	type response struct {
+		ID     string
+		Result params
+	}
+	logger.Info("user is created.", "response", &response{ID: "1", Result: params{Name: "alice", AuthPassword: "hunter2"}})
+
+	out := buf.String()
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("expected the password of the struct redacted, got %s", out)
+	}
+	if !strings.Contains(out, "response.Result.Name=alice") || !strings.Contains(out, "response.Result.AuthPassword="+Redacted) {
+		t.Errorf("expected the fields of the struct logged, got %s", out)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	l, err := ParseLevel("warn")
+	if err != nil || l != slog.LevelWarn {
+		t.Errorf("expected the warn level, got %v, %v", l, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected an error for the unknown level")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		With(r, "admin", "alice")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+	id := w.Header().Get("X-Request-Id")
+	out := buf.String()
+	if id == "" || !strings.Contains(out, "request_id="+id) {
+		t.Errorf("expected the request id %q in the log, got %s", id, out)
+	}
+	if !strings.Contains(out, "admin=alice") || !strings.Contains(out, "status=418") {
+		t.Errorf("expected the admin and the status in the log, got %s", out)
+	}
+}
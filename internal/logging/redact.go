@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted is logged instead of the values of the secrets.
+const Redacted = "[REDACTED]"
+
+// secretKeys are the parts of the keys of the attributes whose values are secrets.
+var secretKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "authorization", "cookie"}
+
+// uuidPattern matches the uuids, the ids and the keys of the accounts.
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{8}([0-9a-fA-F]{4})`)
+
+// RedactHandler hides the secrets of the logs of the handler it wraps. The attributes with the
+// secretKeys, and the fields of the structs logged with them, are Redacted, and the uuids in the messages and the values are masked except the
+// last 4 characters, enough to tell them apart.
+type RedactHandler struct {
+	handler slog.Handler
+}
+
+// NewRedactHandler returns the RedactHandler of the h.
+func NewRedactHandler(h slog.Handler) *RedactHandler {
+	return &RedactHandler{handler: h}
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.handler.Enabled(ctx, l)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, MaskUUIDs(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redact(a))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redact(a)
+	}
+	return &RedactHandler{handler: h.handler.WithAttrs(redacted)}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{handler: h.handler.WithGroup(name)}
+}
+
+// MaskUUIDs masks the uuids in the s except their last 4 characters.
+func MaskUUIDs(s string) string {
+	return uuidPattern.ReplaceAllString(s, "********-****-****-****-********$1")
+}
+
+// redact returns the a with its secrets hidden.
+func redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	// NOTE: the empty ones are kept to tell that the secret is not given.
+	if secretKey(a.Key) && (a.Value.Kind() != slog.KindString || a.Value.String() != "") {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, MaskUUIDs(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, g := range group {
+			redacted[i] = redact(g)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, MaskUUIDs(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, MaskUUIDs(v.String()))
+		}
+		if fields, ok := structFields(a.Value.Any()); ok {
+			return redact(slog.Attr{Key: a.Key, Value: slog.GroupValue(fields...)})
+		}
+	}
+	return a
+}
+
+// structFields returns the exported fields of the struct, or of the struct the pointer points to,
+// as the attributes named by the fields, so their secrets are redacted like the other attributes.
+func structFields(v any) ([]slog.Attr, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	var fields []slog.Attr
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fields = append(fields, slog.Any(f.Name, rv.Field(i).Interface()))
+	}
+	return fields, true
+}
+
+// secretKey reports whether the values of the key are secrets.
+func secretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+// Package metrics keeps the counters and the histograms of the panel and writes them in the
+// text format of prometheus, for the /metrics endpoint.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry of the metrics of the panel.
+var Default = &Registry{}
+
+// The metrics of the panel, the accounts are counted by the handler when they're scraped.
+var (
+	HTTPRequests           = Default.Counter("lothone_http_requests_total", "Count of the http requests by the route and the status.", "method", "route", "status")
+	HTTPDuration           = Default.Histogram("lothone_http_request_duration_seconds", "Latency of the http requests by the route.", DefaultBuckets, "method", "route")
+	LoginFailures          = Default.Counter("lothone_login_failures_total", "Count of the failed logins by the method, password, totp or passkey.", "method")
+	NotificationFailures   = Default.Counter("lothone_notification_failures_total", "Count of the push notifications the gotify server didn't take.")
+	ServiceRestarts        = Default.Counter("lothone_service_restarts_total", "Count of the restarts of the vpn services by the result, succeeded or failed.", "service", "result")
+	ServiceRestartDuration = Default.Histogram("lothone_service_restart_duration_seconds", "Time taken by the restarts of the vpn services.", RestartBuckets, "service")
+	SoftEtherErrors        = Default.Counter("lothone_softether_rpc_errors_total", "Count of the failed json-rpc calls to the softether server by the method.", "method")
+)
+
+// DefaultBuckets are the upper bounds of the histograms of the http latencies in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// RestartBuckets are the upper bounds of the histograms of the restarts in seconds.
+var RestartBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metric is a metric a Registry can write.
+type Metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metrics written together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []Metric
+}
+
+// Register adds the metrics to the r.
+func (r *Registry) Register(metrics ...Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, metrics...)
+}
+
+// Counter registers the counter with the name and the names of its labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := NewCounter(name, help, labels...)
+	r.Register(c)
+	return c
+}
+
+// Histogram registers the histogram with the buckets and the names of its labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := NewHistogram(name, help, buckets, labels...)
+	r.Register(h)
+	return h
+}
+
+// Write writes the metrics of the r and the extra ones to the w in the prometheus text format.
+func (r *Registry) Write(w io.Writer, extra ...Metric) error {
+	r.mu.Lock()
+	metrics := slices.Concat(r.metrics, extra)
+	r.mu.Unlock()
+
+	b := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(b)
+	}
+	return b.Flush()
+}
+
+// desc is the name, the help and the label names of a metric.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) header(b *bufio.Writer, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", d.name, escape(d.help, false), d.name, kind)
+}
+
+// key is the key of the series of the values of the labels, panicking if they don't match the names.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// sample writes the sample of the name with the values of the labels, and the extra label.
+func (d desc) sample(b *bufio.Writer, name string, values []string, extra string, v float64) {
+	b.WriteString(name)
+	if len(values) > 0 || extra != "" {
+		b.WriteByte('{')
+		for i, value := range values {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, d.labels[i], escape(value, true))
+		}
+		if extra != "" {
+			if len(values) > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(extra)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+// series are the values of a metric with the same label values.
+type series[T any] struct {
+	labels []string
+	value  T
+}
+
+// sorted returns the series of the m in the order of their keys, so the output is stable.
+func sorted[T any](m map[string]*series[T]) []*series[T] {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	s := make([]*series[T], len(keys))
+	for i, k := range keys {
+		s[i] = m[k]
+	}
+	return s
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series[float64]
+}
+
+// NewCounter returns the unregistered counter with the name and the names of its labels.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{desc: desc{name, help, labels}, series: map[string]*series[float64]{}}
+}
+
+// Inc adds 1 to the counter of the values of the labels.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds the v to the counter of the values of the labels.
+func (c *Counter) Add(v float64, values ...string) {
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &series[float64]{labels: slices.Clone(values)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) write(b *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(b, "counter")
+	for _, s := range sorted(c.series) {
+		c.sample(b, c.name, s.labels, "", s.value)
+	}
+}
+
+// Gauge is a value that goes up and down, the ones of a scrape are made unregistered and
+// given to the Write.
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series[float64]
+}
+
+// NewGauge returns the unregistered gauge with the name and the names of its labels.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{desc: desc{name, help, labels}, series: map[string]*series[float64]{}}
+}
+
+// Set sets the gauge of the values of the labels to the v.
+func (g *Gauge) Set(v float64, values ...string) {
+	key := g.key(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series[key] = &series[float64]{labels: slices.Clone(values), value: v}
+}
+
+// Add adds the v to the gauge of the values of the labels.
+func (g *Gauge) Add(v float64, values ...string) {
+	key := g.key(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.series[key]
+	if !ok {
+		s = &series[float64]{labels: slices.Clone(values)}
+		g.series[key] = s
+	}
+	s.value += v
+}
+
+func (g *Gauge) write(b *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(b, "gauge")
+	for _, s := range sorted(g.series) {
+		g.sample(b, g.name, s.labels, "", s.value)
+	}
+}
+
+// Histogram counts the observed values in the buckets of their upper bounds.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*series[*observations]
+}
+
+type observations struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram returns the unregistered histogram with the sorted upper bounds of the buckets
+// and the names of its labels.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{desc: desc{name, help, labels}, buckets: buckets, series: map[string]*series[*observations]{}}
+}
+
+// Observe adds the v to the histogram of the values of the labels.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &series[*observations]{labels: slices.Clone(values), value: &observations{counts: make([]uint64, len(h.buckets))}}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.value.counts[i]++
+		}
+	}
+	s.value.count++
+	s.value.sum += v
+}
+
+func (h *Histogram) write(b *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(b, "histogram")
+	for _, s := range sorted(h.series) {
+		for i, upper := range h.buckets {
+			h.sample(b, h.name+"_bucket", s.labels, `le="`+formatFloat(upper)+`"`, float64(s.value.counts[i]))
+		}
+		h.sample(b, h.name+"_bucket", s.labels, `le="+Inf"`, float64(s.value.count))
+		h.sample(b, h.name+"_sum", s.labels, "", s.value.sum)
+		h.sample(b, h.name+"_count", s.labels, "", float64(s.value.count))
+	}
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape escapes the s for the help text, or the label value with its quotes.
+func escape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
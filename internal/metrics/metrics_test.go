@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestWrite(t *testing.T) {
+	r := &Registry{}
+	c := r.Counter("test_total", "Count of the tests.", "name")
+	c.Inc(`a"b`)
+	c.Add(2, "c")
+	h := r.Histogram("test_seconds", "Time of the tests.", []float64{0.1, 1})
+	h.Observe(0.5)
+	h.Observe(2)
+	g := NewGauge("test_gauge", "Extra gauge.")
+	g.Set(3)
+
+	var b strings.Builder
+	err := r.Write(&b, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_total Count of the tests.
+# TYPE test_total counter
+test_total{name="a\"b"} 1
+test_total{name="c"} 2
+# HELP test_seconds Time of the tests.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 0
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="+Inf"} 2
+test_seconds_sum 2.5
+test_seconds_count 2
+# HELP test_gauge Extra gauge.
+# TYPE test_gauge gauge
+test_gauge 3
+`
+	if b.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, b.String())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/accounts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for _, path := range []string{"/accounts/1", "/accounts/2", "/nothing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var b strings.Builder
+	err := (&Registry{}).Write(&b, HTTPRequests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`lothone_http_requests_total{method="GET",route="/accounts/{id}",status="202"} 2`,
+		`lothone_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("expected %s in\n%s", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package sysinfo
+
+import "syscall"
+
+// diskUsage returns the total and the used bytes of the file system of the path.
+func diskUsage(path string) (total, used uint64, err error) {
+	var fs syscall.Statfs_t
+	err = syscall.Statfs(path, &fs)
+	if err != nil {
+		return 0, 0, err
+	}
+	total = fs.Blocks * uint64(fs.Bsize)
+	return total, total - fs.Bfree*uint64(fs.Bsize), nil
+}
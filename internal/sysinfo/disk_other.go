@@ -0,0 +1,10 @@
+//go:build !linux
+
+package sysinfo
+
+import "errors"
+
+// diskUsage is only supported on linux, like the rest of the panel.
+func diskUsage(path string) (total, used uint64, err error) {
+	return 0, 0, errors.New("the disk usage is only read on linux")
+}
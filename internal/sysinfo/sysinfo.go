@@ -0,0 +1,248 @@
+// Package sysinfo reads the usage of the cpu, the memory, the disk and the network of the server
+// from /proc, for the server status page.
+package sysinfo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats is the usage of the server.
+type Stats struct {
+	// CPU is the percentage of the time of all the cores being busy over the interval of the Read.
+	CPU   float64
+	Cores int
+	// Load is the load average of the last 1, 5 and 15 minutes.
+	Load        [3]float64
+	MemoryTotal uint64
+	MemoryUsed  uint64 // the memory the programs can't get back without swapping.
+	DiskTotal   uint64
+	DiskUsed    uint64
+	// NetworkRx and NetworkTx are the bytes per second received and sent by the interfaces other than the loopback.
+	NetworkRx uint64
+	NetworkTx uint64
+	Uptime    time.Duration
+}
+
+// Read reads the Stats from the proc, like os.DirFS("/proc"), and of the disk of the path. The cpu and the
+// network are sampled twice the interval apart.
+func Read(proc fs.FS, path string, interval time.Duration) (Stats, error) {
+	var s Stats
+	cpu1, err := readCPU(proc)
+	if err != nil {
+		return s, err
+	}
+	net1, err := readNetwork(proc)
+	if err != nil {
+		return s, err
+	}
+	time.Sleep(interval)
+	cpu2, err := readCPU(proc)
+	if err != nil {
+		return s, err
+	}
+	net2, err := readNetwork(proc)
+	if err != nil {
+		return s, err
+	}
+
+	s.Cores = cpu2.cores
+	if total := cpu2.total - cpu1.total; total > 0 {
+		s.CPU = 100 * float64(total-(cpu2.idle-cpu1.idle)) / float64(total)
+	}
+	if seconds := interval.Seconds(); seconds > 0 {
+		s.NetworkRx = uint64(float64(delta(net2.rx, net1.rx)) / seconds)
+		s.NetworkTx = uint64(float64(delta(net2.tx, net1.tx)) / seconds)
+	}
+
+	s.Load, err = readLoad(proc)
+	if err != nil {
+		return s, err
+	}
+	s.MemoryTotal, s.MemoryUsed, err = readMemory(proc)
+	if err != nil {
+		return s, err
+	}
+	s.Uptime, err = readUptime(proc)
+	if err != nil {
+		return s, err
+	}
+	s.DiskTotal, s.DiskUsed, err = diskUsage(path)
+	return s, err
+}
+
+// ReadServer reads the Stats of the server the panel is running on, with the disk of the root.
+func ReadServer(interval time.Duration) (Stats, error) {
+	return Read(os.DirFS("/proc"), "/", interval)
+}
+
+// delta is the b - a of the counters, 0 if they're reset in between.
+func delta(b, a uint64) uint64 {
+	if b < a {
+		return 0
+	}
+	return b - a
+}
+
+type cpuTimes struct {
+	idle, total uint64
+	cores       int
+}
+
+// readCPU reads the times of all the cores from the stat, the idle one includes the iowait.
+func readCPU(proc fs.FS) (cpuTimes, error) {
+	var c cpuTimes
+	f, err := proc.Open("stat")
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			c.cores++
+			continue
+		}
+		if len(fields) < 5 {
+			return c, errors.New("the cpu line of the stat is too short")
+		}
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("parsing the cpu times of the stat: %w", err)
+			}
+			// the guest times are already in the user ones.
+			if i >= 8 {
+				break
+			}
+			c.total += v
+			// idle and iowait.
+			if i == 3 || i == 4 {
+				c.idle += v
+			}
+		}
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return c, err
+	}
+	if !found {
+		return c, errors.New("there's no cpu line in the stat")
+	}
+	return c, nil
+}
+
+type networkBytes struct {
+	rx, tx uint64
+}
+
+// readNetwork adds up the bytes of the interfaces other than the loopback from the net/dev.
+func readNetwork(proc fs.FS) (networkBytes, error) {
+	var n networkBytes
+	f, err := proc.Open("net/dev")
+	if err != nil {
+		return n, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return n, fmt.Errorf("parsing the received bytes of %s: %w", strings.TrimSpace(name), err)
+		}
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return n, fmt.Errorf("parsing the sent bytes of %s: %w", strings.TrimSpace(name), err)
+		}
+		n.rx += rx
+		n.tx += tx
+	}
+	return n, scanner.Err()
+}
+
+func readLoad(proc fs.FS) ([3]float64, error) {
+	var load [3]float64
+	data, err := fs.ReadFile(proc, "loadavg")
+	if err != nil {
+		return load, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return load, errors.New("the loadavg is too short")
+	}
+	for i := range load {
+		load[i], err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return load, fmt.Errorf("parsing the loadavg: %w", err)
+		}
+	}
+	return load, nil
+}
+
+// readMemory returns the total and the used memory in bytes from the meminfo.
+func readMemory(proc fs.FS) (total, used uint64, err error) {
+	f, err := proc.Open("meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || (key != "MemTotal" && key != "MemAvailable") {
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing the %s of the meminfo: %w", key, err)
+		}
+		values[key] = kb * 1024
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	total, ok := values["MemTotal"]
+	if !ok {
+		return 0, 0, errors.New("there's no MemTotal in the meminfo")
+	}
+	return total, total - min(values["MemAvailable"], total), nil
+}
+
+func readUptime(proc fs.FS) (time.Duration, error) {
+	data, err := fs.ReadFile(proc, "uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errors.New("the uptime is empty")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing the uptime: %w", err)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
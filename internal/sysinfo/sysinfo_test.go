@@ -0,0 +1,69 @@
+package sysinfo
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var proc = fstest.MapFS{
+	"stat": {Data: []byte(`cpu  100 0 100 700 100 0 0 0 0 0
+cpu0 50 0 50 350 50 0 0 0 0 0
+cpu1 50 0 50 350 50 0 0 0 0 0
+intr 1234
+`)},
+	"net/dev": {Data: []byte(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  999999     10    0    0    0     0          0         0   999999      10    0    0    0     0       0          0
+  eth0:    1000     10    0    0    0     0          0         0     2000      10    0    0    0     0       0          0
+  eth1:     500     10    0    0    0     0          0         0      500      10    0    0    0     0       0          0
+`)},
+	"loadavg": {Data: []byte("0.52 0.31 0.20 1/123 4567\n")},
+	"meminfo": {Data: []byte(`MemTotal:        2048 kB
+MemFree:          512 kB
+MemAvailable:    1024 kB
+`)},
+	"uptime": {Data: []byte("93784.52 180000.00\n")},
+}
+
+func TestRead(t *testing.T) {
+	c, err := readCPU(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.total != 1000 || c.idle != 800 || c.cores != 2 {
+		t.Errorf("expected 1000 total, 800 idle of 2 cores, got %+v", c)
+	}
+
+	n, err := readNetwork(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.rx != 1500 || n.tx != 2500 {
+		t.Errorf("expected the loopback left out, got %+v", n)
+	}
+
+	s, err := Read(proc, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Load != [3]float64{0.52, 0.31, 0.20} {
+		t.Errorf("unexpected load %v", s.Load)
+	}
+	if s.MemoryTotal != 2048*1024 || s.MemoryUsed != 1024*1024 {
+		t.Errorf("unexpected memory %d of %d", s.MemoryUsed, s.MemoryTotal)
+	}
+	if s.Uptime != 26*time.Hour+3*time.Minute+4*time.Second {
+		t.Errorf("unexpected uptime %v", s.Uptime)
+	}
+	if s.DiskTotal == 0 || s.DiskUsed > s.DiskTotal {
+		t.Errorf("unexpected disk %d of %d", s.DiskUsed, s.DiskTotal)
+	}
+}
+
+func TestReadMissing(t *testing.T) {
+	_, err := Read(fstest.MapFS{}, "/", 0)
+	if err == nil {
+		t.Error("expected an error without the stat")
+	}
+}
@@ -38,6 +38,7 @@ func (a AccountType) Protocol() (protocol string) {
 // Filename gets the filename of each v2ray protocol configuration
 // NOTE: Filename only gets the filenames for v2ray protocols that are prefixed with configFilePrefix and userFilePrefix.
 func (a AccountType) Filename() (string, string) {
+	cfg := config.Get()
 	var configFilename string
 	var usersFilename string
 	switch a {
@@ -48,7 +49,7 @@ func (a AccountType) Filename() (string, string) {
 		configFilename = "shadowsocks.json"
 		usersFilename = "shadowsocks_users.json"
 	}
-	return (*config.ConfigFilePrefix + configFilename), (*config.UserFilePrefix + usersFilename)
+	return (cfg.ConfigFilePrefix + configFilename), (cfg.UserFilePrefix + usersFilename)
 }
 
 // ParseAccountType converts a string to an AccountType. Returns an error if the given string is an invalid AccountType.
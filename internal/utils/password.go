@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters of the newly hashed passwords.
+// The encoded hashes keep their own parameters, so these can be raised later.
+const (
+	argon2Time    uint32 = 3
+	argon2Memory  uint32 = 64 * 1024 // in KiB.
+	argon2Threads uint8  = 2
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+)
+
+const (
+	// MaxUsernameLength and MaxPasswordLength are the sanity limits of the panel credentials.
+	MaxUsernameLength = 30
+	MaxPasswordLength = 72
+)
+
+var ErrInvalidHash = errors.New("Invalid password hash")
+
+// dummyHash is the hash of no admin the VerifyDummy checks against, made with the current parameters.
+var dummyHash = sync.OnceValue(func() string {
+	hash, err := HashPassword("lothone dummy password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// VerifyDummy verifies the password against the dummyHash, so the logins of the unknown usernames take
+// as long as the known ones and the timing doesn't tell which usernames exist.
+func VerifyDummy(password string) {
+	VerifyPassword(password, dummyHash())
+}
+
+// CheckCredentials checks the username and the password are within the limits of the panel.
+func CheckCredentials(username, password string) error {
+	if username == "" || password == "" {
+		return errors.New("username and password must not be empty")
+	}
+	if strings.ContainsAny(username, "~ \t\r\n") {
+		return errors.New("username must not contain spaces or tilde(~)")
+	}
+	if len(username) > MaxUsernameLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("usernames should not be more than %d characters and passwords %d", MaxUsernameLength, MaxPasswordLength)
+	}
+	return nil
+}
+
+// HashPassword hashes the password with Argon2id and a random salt, returning it in the
+// form of $argon2id$v=19$m=65536,t=3,p=2$salt$hash.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword verify the password Given with the correct hash made by the HashPassword.
+// The legacy unsalted sha-256 hex hashes are also accepted, use the NeedsRehash to upgrade them.
+//
+// The password is a correct password, only if the boolean is "true", and error is "nil".
+func VerifyPassword(password string, correct string) (bool, error) {
+	if isLegacyHash(correct) {
+		return verifyLegacyPassword(password, correct)
+	}
+
+	parts := strings.Split(correct, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrInvalidHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false, ErrInvalidHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) == 1 {
+		return true, nil
+	}
+	return false, ErrWrongPassword
+}
+
+// NeedsRehash reports whether the hash should be replaced by a new HashPassword of the
+// same password, being a legacy hash or made with weaker parameters.
+func NeedsRehash(hash string) bool {
+	if isLegacyHash(hash) {
+		return true
+	}
+	var memory, time uint32
+	var threads uint8
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return true
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	return err != nil || memory < argon2Memory || time < argon2Time || threads < argon2Threads
+}
+
+// isLegacyHash reports whether the hash is an unsalted sha-256 hex hash of the previous versions.
+func isLegacyHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// verifyLegacyPassword checks the password with the unsalted sha-256 hex hash.
+func verifyLegacyPassword(password string, correct string) (bool, error) {
+	hashBytes := sha256.Sum256([]byte(password))
+	userPassword, err := hex.DecodeString(correct)
+	if err != nil {
+		return false, err
+	}
+	if subtle.ConstantTimeCompare(hashBytes[:], userPassword) == 1 {
+		return true, nil
+	}
+	return false, ErrWrongPassword
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("expected an argon2id hash, got %s", hash)
+	}
+
+	other, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == other {
+		t.Error("expected the hashes of the same password to be salted differently")
+	}
+
+	correct, err := VerifyPassword("password", hash)
+	if !correct || err != nil {
+		t.Errorf("expected the password to be correct, got %v, %v", correct, err)
+	}
+	correct, err = VerifyPassword("wrong", hash)
+	if correct || err != ErrWrongPassword {
+		t.Errorf("expected the wrong password, got %v, %v", correct, err)
+	}
+	if NeedsRehash(hash) {
+		t.Error("expected the new hash not to need a rehash")
+	}
+}
+
+func TestLegacyPassword(t *testing.T) {
+	sum := sha256.Sum256([]byte("password"))
+	legacy := hex.EncodeToString(sum[:])
+
+	correct, err := VerifyPassword("password", legacy)
+	if !correct || err != nil {
+		t.Errorf("expected the legacy password to be correct, got %v, %v", correct, err)
+	}
+	correct, _ = VerifyPassword("wrong", legacy)
+	if correct {
+		t.Error("expected the wrong legacy password")
+	}
+	if !NeedsRehash(legacy) {
+		t.Error("expected the legacy hash to need a rehash")
+	}
+}
+
+func TestInvalidHash(t *testing.T) {
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=1,t=1$salt$hash", "$bcrypt$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA"} {
+		_, err := VerifyPassword("password", hash)
+		if err != ErrInvalidHash {
+			t.Errorf("%q: expected ErrInvalidHash, got %v", hash, err)
+		}
+	}
+}
+
+func TestVerifyDummy(t *testing.T) {
+	correct, err := VerifyPassword("password", dummyHash())
+	if err != ErrWrongPassword || correct {
+		t.Errorf("expected the dummy hash to be a valid hash of no password, got %v, %v", correct, err)
+	}
+	if !strings.HasPrefix(dummyHash(), "$argon2id$") {
+		t.Errorf("expected the dummy hash to cost as much as the admins, got %q", dummyHash())
+	}
+}
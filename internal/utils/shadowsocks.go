@@ -5,13 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"slices"
 	"sort"
-	"strings"
 
 	"github.com/htetmyatthar/lothone/internal/config"
 )
@@ -46,14 +45,14 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	// Read config file
 	configData, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Println("Error reading config file:", err)
+		slog.Error("Error reading config file.", "err", err)
 		return InternalServerErr
 	}
 
 	// Read users file
 	userData, err := os.ReadFile(usersFile)
 	if err != nil {
-		log.Println("Error reading user data file:", err)
+		slog.Error("Error reading user data file.", "err", err)
 		return InternalServerErr
 	}
 
@@ -61,7 +60,7 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	var configResult map[string]json.RawMessage
 	err = json.Unmarshal(configData, &configResult)
 	if err != nil {
-		log.Println("Error unmarshalling config JSON:", err)
+		slog.Error("Error unmarshalling config JSON.", "err", err)
 		return InternalServerErr
 	}
 
@@ -69,7 +68,7 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	var userResult map[string]json.RawMessage
 	err = json.Unmarshal(userData, &userResult)
 	if err != nil {
-		log.Println("Error unmarshalling users JSON:", err)
+		slog.Error("Error unmarshalling users JSON.", "err", err)
 		return InternalServerErr
 	}
 
@@ -77,7 +76,7 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	var inbounds []ShadowsocksInbound
 	err = json.Unmarshal(configResult["inbounds"], &inbounds)
 	if err != nil {
-		log.Println("Error unmarshalling 'inbounds':", err)
+		slog.Error("Error unmarshalling 'inbounds'.", "err", err)
 		return InternalServerErr
 	}
 
@@ -85,14 +84,14 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	var users []Client
 	err = json.Unmarshal(userResult["clients"], &users)
 	if err != nil {
-		log.Println("Error unmarshalling 'clients':", err)
+		slog.Error("Error unmarshalling 'clients'.", "err", err)
 		return InternalServerErr
 	}
 
 	// Check if password already exists that can uniquely identified a user for frontend.
 	for _, inbound := range inbounds {
 		if inbound.Settings.Password == c.Password {
-			log.Println("Error: password is already in use")
+			slog.Error("Error: password is already in use.")
 			return InternalServerErr
 		}
 	}
@@ -123,7 +122,7 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	// Marshal updated inbounds
 	inboundBytes, err := json.Marshal(inbounds)
 	if err != nil {
-		log.Println("Error marshalling modified inbounds:", err)
+		slog.Error("Error marshalling modified inbounds.", "err", err)
 		return InternalServerErr
 	}
 	configResult["inbounds"] = inboundBytes
@@ -131,7 +130,7 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	// Marshal updated users
 	usersBytes, err := json.Marshal(users)
 	if err != nil {
-		log.Println("Error marshalling modified users:", err)
+		slog.Error("Error marshalling modified users.", "err", err)
 		return InternalServerErr
 	}
 	userResult["clients"] = usersBytes
@@ -139,32 +138,32 @@ func CreateShadowsocksUser(c Client, configFile string, usersFile string) error
 	// Marshal final JSON with indentation
 	finalConfigJSON, err := json.MarshalIndent(configResult, "", "  ")
 	if err != nil {
-		log.Println("Error marshalling final config JSON:", err)
+		slog.Error("Error marshalling final config JSON.", "err", err)
 		return InternalServerErr
 	}
 
 	finalUserJSON, err := json.MarshalIndent(userResult, "", "  ")
 	if err != nil {
-		log.Println("Error marshalling final users JSON:", err)
+		slog.Error("Error marshalling final users JSON.", "err", err)
 		return InternalServerErr
 	}
 
 	// Write back to files
 	err = os.WriteFile(configFile, finalConfigJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified config JSON to file:", err)
+		slog.Error("Error writing modified config JSON to file.", "err", err)
 		return InternalServerErr
 	}
 
 	err = os.WriteFile(usersFile, finalUserJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified users JSON to file:", err)
+		slog.Error("Error writing modified users JSON to file.", "err", err)
 		return InternalServerErr
 	}
 
 	err = AllowPort(c.Port)
 	if err != nil {
-		log.Println("port error. please fix ufw.: ", err)
+		slog.Error("port error. please fix ufw.", "err", err)
 		return InternalServerErr
 	}
 
@@ -204,41 +203,41 @@ func getNextPort(inbounds []ShadowsocksInbound) int {
 func EditShadowsocksUser(client Client, configFile, usersFile string) (*Client, int, error) {
 	configData, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Println("Error reading file:", err)
+		slog.Error("Error reading file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	userData, err := os.ReadFile(usersFile)
 	if err != nil {
-		log.Println("Error reading user data file: ", err)
+		slog.Error("Error reading user data file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var configResult map[string]json.RawMessage
 	err = json.Unmarshal(configData, &configResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in config:", err)
+		slog.Error("Error unmarshalling JSON to map in config.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var userResult map[string]json.RawMessage
 	err = json.Unmarshal(userData, &userResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in users:", err)
+		slog.Error("Error unmarshalling JSON to map in users.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var inbounds []ShadowsocksInbound
 	err = json.Unmarshal(configResult["inbounds"], &inbounds)
 	if err != nil {
-		log.Println("Error unmarshalling 'inbounds':", err)
+		slog.Error("Error unmarshalling 'inbounds'.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var users []Client
 	err = json.Unmarshal(userResult["clients"], &users)
 	if err != nil {
-		log.Println("Error unmarshalling 'users': ", err)
+		slog.Error("Error unmarshalling 'users'.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
@@ -251,7 +250,7 @@ func EditShadowsocksUser(client Client, configFile, usersFile string) (*Client,
 	}
 
 	if !found {
-		log.Println("Invalid user is being searched.")
+		slog.Warn("Invalid user is being searched.")
 		return nil, http.StatusBadRequest, errors.New("Bad Request")
 	}
 
@@ -285,39 +284,39 @@ func EditShadowsocksUser(client Client, configFile, usersFile string) (*Client,
 
 	inboundBytes, err := json.Marshal(inbounds)
 	if err != nil {
-		log.Println("Error marshalling modified inbounds:", err)
+		slog.Error("Error marshalling modified inbounds.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 	configResult["inbounds"] = inboundBytes
 
 	usersBytes, err := json.Marshal(users)
 	if err != nil {
-		log.Println("Error marshalling modified users:", err)
+		slog.Error("Error marshalling modified users.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 	userResult["clients"] = usersBytes
 
 	finalConfigJSON, err := json.MarshalIndent(configResult, "", "  ")
 	if err != nil {
-		log.Println("Error marshalling final config JSON:", err)
+		slog.Error("Error marshalling final config JSON.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	finalUserJSON, err := json.MarshalIndent(userResult, "", " ")
 	if err != nil {
-		log.Println("Error marshalling final users JSON:", err)
+		slog.Error("Error marshalling final users JSON.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	err = os.WriteFile(configFile, finalConfigJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	err = os.WriteFile(usersFile, finalUserJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 	return &oldClient, http.StatusOK, nil
@@ -326,41 +325,41 @@ func EditShadowsocksUser(client Client, configFile, usersFile string) (*Client,
 func DeleteShadowsocksUser(password, deviceId, configFile, usersFile string) (*Client, int, error) {
 	configData, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Println("Error reading file:", err)
+		slog.Error("Error reading file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	userData, err := os.ReadFile(usersFile)
 	if err != nil {
-		log.Println("Error reading user data file: ", err)
+		slog.Error("Error reading user data file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var configResult map[string]json.RawMessage
 	err = json.Unmarshal(configData, &configResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in config:", err)
+		slog.Error("Error unmarshalling JSON to map in config.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var userResult map[string]json.RawMessage
 	err = json.Unmarshal(userData, &userResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in users:", err)
+		slog.Error("Error unmarshalling JSON to map in users.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var inbounds []Inbound
 	err = json.Unmarshal(configResult["inbounds"], &inbounds)
 	if err != nil {
-		log.Println("Error unmarshalling 'inbounds':", err)
+		slog.Error("Error unmarshalling 'inbounds'.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var users []Client
 	err = json.Unmarshal(userResult["clients"], &users)
 	if err != nil {
-		log.Println("Error unmarshalling 'users': ", err)
+		slog.Error("Error unmarshalling 'users'.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
@@ -373,7 +372,7 @@ func DeleteShadowsocksUser(password, deviceId, configFile, usersFile string) (*C
 	}
 
 	if users[index].Password != password && users[index].DeviceId != deviceId {
-		log.Println("Error invoking user deletion with incorrect information")
+		slog.Error("Error invoking user deletion with incorrect information.")
 		return nil, http.StatusForbidden, errors.New("User's not found")
 	}
 
@@ -383,45 +382,45 @@ func DeleteShadowsocksUser(password, deviceId, configFile, usersFile string) (*C
 
 	inboundBytes, err := json.Marshal(inbounds)
 	if err != nil {
-		log.Println("Error marshalling modified inbounds:", err)
+		slog.Error("Error marshalling modified inbounds.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 	configResult["inbounds"] = inboundBytes
 
 	usersBytes, err := json.Marshal(users)
 	if err != nil {
-		log.Println("Error marshalling modified users:", err)
+		slog.Error("Error marshalling modified users.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 	userResult["clients"] = usersBytes
 
 	finalConfigJSON, err := json.MarshalIndent(configResult, "", "  ")
 	if err != nil {
-		log.Println("Error marshalling final config JSON:", err)
+		slog.Error("Error marshalling final config JSON.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	finalUserJSON, err := json.MarshalIndent(userResult, "", " ")
 	if err != nil {
-		log.Println("Error marshalling final users JSON:", err)
+		slog.Error("Error marshalling final users JSON.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	err = os.WriteFile(configFile, finalConfigJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	err = os.WriteFile(usersFile, finalUserJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	err = DeletePort(deletedUser.Port)
 	if err != nil {
-		log.Println("port error. please fix ufw.: ", err)
+		slog.Error("port error. please fix ufw.", "err", err)
 		return nil, 0, InternalServerErr
 	}
 
@@ -439,24 +438,18 @@ type ShadowsocksConfig struct {
 
 // GenerateShadowsocksLockedURI generates a locked Shadowsocks URI
 func GenerateShadowsocksLockedURI(data Client) (string, error) {
+	cfg := config.Get()
 	if data.DeviceId == "" {
 		return "", fmt.Errorf("unable to generate locked URI without device id")
 	}
 
-	subDomain := strings.Split(*config.WebHost, ".")[0]
-
 	// Define the Shadowsocks configuration with DeviceID
 	ssConfig := ShadowsocksConfig{
 		Method:   "aes-128-gcm",
 		Password: data.Password,
-		Host:     *config.WebHost,
+		Host:     cfg.WebHost,
 		Port:     data.Port,
-		Ps: fmt.Sprintf("valid before (%s) %s-%s-%s [locked:%s]",
-			data.ExpireDate,
-			subDomain,
-			*config.WebHostRegion,
-			data.Password[len(data.Password)-4:],
-			data.DeviceId), // Include DeviceId in the name
+		Ps:       cfg.Remarks(data.Password, data.ExpireDate) + " [locked:" + data.DeviceId + "]", // Include DeviceId in the name
 	}
 
 	// Construct the base part: method:password
@@ -478,19 +471,14 @@ func GenerateShadowsocksLockedURI(data Client) (string, error) {
 
 // GenerateShadowsocksURI generates a standard Shadowsocks URI
 func GenerateShadowsocksURI(data Client) (string, error) {
-	subDomain := strings.Split(*config.WebHost, ".")[0]
-
+	cfg := config.Get()
 	// Define the Shadowsocks configuration
 	ssConfig := ShadowsocksConfig{
 		Method:   "aes-128-gcm", // Matches your inbound settings
 		Password: data.Password,
-		Host:     *config.WebHost,
-		Port:     data.Port, // Assuming Shadowsocks uses the same port as VMESS
-		Ps: fmt.Sprintf("valid before (%s) %s-%s-%s",
-			data.ExpireDate,
-			subDomain,
-			*config.WebHostRegion,
-			data.Password[len(data.Password)-4:]), // Consistent naming with VMESS
+		Host:     cfg.WebHost,
+		Port:     data.Port,                                   // Assuming Shadowsocks uses the same port as VMESS
+		Ps:       cfg.Remarks(data.Password, data.ExpireDate), // Consistent naming with VMESS
 	}
 
 	// Validate required fields
@@ -3,7 +3,7 @@ package utils
 import (
 	"bytes"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -11,11 +11,7 @@ import (
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
 	"github.com/htetmyatthar/lothone/internal/config"
-)
-
-const (
-	DefaultSSTPServerURL = "https://localhost:5555/api"
-	DefaultSSTPHub       = "default"
+	"github.com/htetmyatthar/lothone/internal/metrics"
 )
 
 type JSONRPCRequest struct {
@@ -51,9 +47,11 @@ type CreateUserResponse struct {
 
 // createSSTPUser create a user in the SSTP server of configured hub.
 // Docs link: https://github.com/SoftEtherVPN/SoftEtherVPN/tree/master/developer_tools/vpnserver-jsonrpc-clients/#createuser-rpc-api---create-a-user
-func CreateSSTPUser(name, desc, password string, expire time.Time) (*CreateUserResponse, error) {
+func CreateSSTPUser(name, desc, password string, expire time.Time) (_ *CreateUserResponse, err error) {
+	defer softEtherFailed("CreateUser", &err)
+	cfg := config.Get()
 	params := createUserParams{
-		HubName:         *config.SSTPHub,
+		HubName:         cfg.SSTPHub,
 		Name:            name,
 		Note:            desc,
 		ExpireTime:      expire.Format(time.RFC3339),
@@ -82,13 +80,13 @@ func CreateSSTPUser(name, desc, password string, expire time.Time) (*CreateUserR
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", *config.SSTPServerURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", cfg.SSTPServerURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-VPNADMIN-PASSWORD", *config.SSTPAdminPassword)
+	req.Header.Set("X-VPNADMIN-PASSWORD", cfg.SSTPAdminPassword)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -122,9 +120,11 @@ type DeleteUserResponse struct {
 }
 
 // deleteSSTPUser deletes a user in the SSTP server of configured hub.
-func DeleteSSTPUser(username string) (*DeleteUserResponse, error) {
+func DeleteSSTPUser(username string) (_ *DeleteUserResponse, err error) {
+	defer softEtherFailed("DeleteUser", &err)
+	cfg := config.Get()
 	params := deleteUserParams{
-		HubName: *config.SSTPHub,
+		HubName: cfg.SSTPHub,
 		Name:    username,
 	}
 
@@ -141,13 +141,13 @@ func DeleteSSTPUser(username string) (*DeleteUserResponse, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", *config.SSTPServerURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", cfg.SSTPServerURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-VPNADMIN-PASSWORD", *config.SSTPAdminPassword)
+	req.Header.Set("X-VPNADMIN-PASSWORD", cfg.SSTPAdminPassword)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -193,9 +193,11 @@ type EnumUserResponse struct {
 }
 
 // GetSSTPUsers retrieves the list of users from the VPN server
-func GetSSTPUsers() ([]UserInfo, error) {
+func GetSSTPUsers() (_ []UserInfo, err error) {
+	defer softEtherFailed("EnumUser", &err)
+	cfg := config.Get()
 	params := enumUserParams{
-		HubName: *config.SSTPHub,
+		HubName: cfg.SSTPHub,
 	}
 
 	id := uuid.NewString()
@@ -208,41 +210,41 @@ func GetSSTPUsers() ([]UserInfo, error) {
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
-		log.Println("marshalling error")
+		slog.Error("marshalling error.")
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", *config.SSTPServerURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", cfg.SSTPServerURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Println("requesting error")
+		slog.Error("requesting error.")
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-VPNADMIN-PASSWORD", *config.SSTPAdminPassword)
+	req.Header.Set("X-VPNADMIN-PASSWORD", cfg.SSTPAdminPassword)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Println("after request error")
+		slog.Error("after request error.")
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	var response EnumUserResponse
 
-	log.Println("response body: ", resp.Body)
+	slog.Debug("sstp server responded.", "status", resp.Status)
 
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	if err != nil {
-		log.Println("decoding error")
+		slog.Error("decoding error.")
 		return nil, err
 	}
 
-	log.Println("sstp server response:\n", response)
+	slog.Debug("sstp server response.", "response", response)
 
 	if response.ID != id {
-		log.Println("different id error")
+		slog.Error("different id error.")
 		return nil, errors.New("Different Request IDs.")
 	}
 
@@ -254,10 +256,17 @@ func GetSSTPUsers() ([]UserInfo, error) {
 	return response.Result.UserList, nil
 }
 
+// softEtherFailed counts the failed call of the rpc method of the softether server in the metrics.
+func softEtherFailed(method string, err *error) {
+	if *err != nil {
+		metrics.SoftEtherErrors.Inc(method)
+	}
+}
+
 func parseSSTPDate(s string) string {
 	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
-		log.Println("dates are not in format")
+		slog.Info("dates are not in format.")
 		return s
 	}
 	return strings.Split(t.String(), " ")[0]
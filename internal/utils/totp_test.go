@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the "12345678901234567890" secret of the RFC 6238 test vectors.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("at %d: expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestValidTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	step, ok := ValidTOTP(rfcSecret, "005924", now.Add(30*time.Second))
+	if !ok || step != TOTPStep(now) {
+		t.Errorf("expected the code of the previous period to be accepted, got %d, %v", step, ok)
+	}
+	if _, ok := ValidTOTP(rfcSecret, "005924", now.Add(2*time.Minute)); ok {
+		t.Error("expected the old code to be refused")
+	}
+	if _, ok := ValidTOTP(rfcSecret, "5924", now); ok {
+		t.Error("expected the short code to be refused")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Errorf("expected the new secret to be usable, got %v", err)
+	}
+
+	uri := TOTPURI("sg1.example.com", "admin", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/sg1.example.com:admin?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("unexpected provisioning uri %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+	if HashRecoveryCode(" "+strings.ToUpper(codes[0])+" ") != hashes[0] {
+		t.Error("expected the recovery code to match its hash regardless of the case and the spaces")
+	}
+}
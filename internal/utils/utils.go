@@ -2,14 +2,12 @@ package utils
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"crypto/subtle"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os/exec"
 	"runtime"
@@ -17,6 +15,7 @@ import (
 	"time"
 
 	"github.com/htetmyatthar/lothone/internal/config"
+	"github.com/htetmyatthar/lothone/internal/metrics"
 	"github.com/htetmyatthar/lothone/static"
 )
 
@@ -54,7 +53,18 @@ type Inbound struct {
 const (
 	AuthenticatedField = "b"    // to use with session values that are stored in the backend.
 	URLAfterLogin      = "fsim" // to use with session values that are stored in the backend.
-	DefaultAlterID     = 1
+	AdminField         = "u"    // username of the logged in admin, to use with session values.
+
+	// the login waiting for the second step after the password is verified, to use with session values.
+	PendingAdminField    = "pu" // username of the admin.
+	PendingUntilField    = "pt" // time the second step has to be done before.
+	PendingRememberField = "pr" // the remember me of the first step.
+	TOTPSecretField      = "ts" // the new two-factor secret being set up, saved after its first code is verified.
+
+	PasskeyChallengeField = "pc" // challenge of the passkey prompt, to use with session values.
+	PasskeyVerifiedField  = "pv" // time the admin verified again before registering a passkey.
+
+	DefaultAlterID = 1
 
 	V2boxLockedPrefix = "v2box://locked=" // to use with locked uri generation.
 )
@@ -62,10 +72,27 @@ const (
 var (
 	ErrWrongPassword = errors.New("Wrong password")
 	ErrUserLockedOut = errors.New("User is locked out")
-
-	PanelUsers = InitPanelUsers(*config.Admins)
 )
 
+// Notifier sends the push notifications to the gotify server of the current configuration.
+type Notifier struct{}
+
+// NewNotifier returns the Notifier.
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// Notify sends the notification with each of the gotify api keys.
+func (n *Notifier) Notify(title, message string, priority int) {
+	cfg := config.Get()
+	for _, key := range cfg.GotifyAPIKeys {
+		err := SendNoti(cfg.GotifyServer, key, title, message, priority)
+		if err != nil {
+			metrics.NotificationFailures.Inc()
+		}
+	}
+}
+
 // getMemoryUsage returns the memory usage in the current
 // state of the function being called.
 func GetMemoryUsage() uint64 {
@@ -74,23 +101,66 @@ func GetMemoryUsage() uint64 {
 	return m.TotalAlloc
 }
 
+// Service is a systemd service of the server shown on the server status page.
+type Service struct {
+	Name string // shown to the admins.
+	Unit string
+}
+
+// Services are the services of the server, the vpn ones and the panel itself.
+var Services = []Service{
+	{Name: "v2ray", Unit: "v2ray"},
+	{Name: "shadowsocks", Unit: "shadowsocks"},
+	{Name: "softether", Unit: "softether-vpnserver"},
+	{Name: "panel", Unit: "server-manager"},
+}
+
+// PanelService is the name of the service of the panel, restarting it stops the panel too.
+const PanelService = "panel"
+
+// FindService returns the one of the Services with the name.
+func FindService(name string) (Service, bool) {
+	for _, s := range Services {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Service{}, false
+}
+
 // Function to restart V2Ray service
 func RestartService() error {
-	cmd := exec.Command("sudo", "systemctl", "restart", "v2ray")
-	output, err := cmd.CombinedOutput()
+	err := RestartUnit("v2ray")
 	if err != nil {
-		return fmt.Errorf("Failed to restart service: %s, %v", string(output), err)
+		return err
 	}
+	return RestartUnit("shadowsocks")
+}
 
-	scmd := exec.Command("sudo", "systemctl", "restart", "shadowsocks")
-	output, err = scmd.CombinedOutput()
+// RestartUnit restarts the systemd service, counting the restart and its time in the metrics.
+func RestartUnit(name string) error {
+	start := time.Now()
+	output, err := exec.Command("sudo", "systemctl", "restart", name).CombinedOutput()
+	metrics.ServiceRestartDuration.Observe(time.Since(start).Seconds(), name)
 	if err != nil {
+		metrics.ServiceRestarts.Inc(name, "failed")
 		return fmt.Errorf("Failed to restart service: %s, %v", string(output), err)
 	}
-
+	metrics.ServiceRestarts.Inc(name, "succeeded")
 	return nil
 }
 
+// ServiceState returns the systemd state of the service, eg. active, inactive or failed.
+func ServiceState(name string) string {
+	output, err := exec.Command("systemctl", "is-active", name).Output()
+	state := strings.TrimSpace(string(output))
+	if state == "" {
+		slog.Error("getting the state of the service gone wrong.", "service", name, "err", err)
+		return "unknown"
+	}
+	return state
+}
+
 // Function to validate V2Ray configuration
 // Deprecated: Unecessary check?
 func ValidateConfig() error {
@@ -105,39 +175,6 @@ func ValidateConfig() error {
 	// return nil
 }
 
-// VerifyPassword verify the password Given with the correct Password.
-// This method can be used to check the input password is the correct u's Password or not,
-// while returning an error if there's any.
-//
-// The password is a correct password, only if the boolean is "true", and error is "nil".
-func VerifyPassword(password string, correct string) (bool, error) {
-	_, hashBytes, err := HashPassword(password)
-	if err != nil {
-		return false, err
-	}
-	userPassword, err := hex.DecodeString(correct)
-	if err != nil {
-		return false, err
-	}
-	if subtle.ConstantTimeCompare(hashBytes, userPassword) == 1 {
-		return true, nil
-	}
-	return false, ErrWrongPassword
-}
-
-// HashPassword hashes the given password string to sha-256 hash returning the hashed values
-// as a hex-dec value string and also in the form of byte slice.
-func HashPassword(password string) (string, []byte, error) {
-	hasher := sha256.New()
-	_, err := hasher.Write([]byte(password))
-	if err != nil {
-		return "", nil, err
-	}
-	hashedBytes := hasher.Sum(nil)
-	// hex is easier to maintain
-	return hex.EncodeToString(hashedBytes), hashedBytes, err
-}
-
 // Message data for the gotify server.
 type GotifyMessage struct {
 	Title    string `json:"title"`
@@ -155,13 +192,13 @@ func SendNoti(gotifyServer, appToken, title, message string, priority int) error
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
-		log.Printf("failed to marshal JSON payload: %v\n", err)
+		slog.Error("failed to marshal JSON payload.", "err", err)
 		return err
 	}
 
 	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s/message", gotifyServer), bytes.NewBuffer(payload))
 	if err != nil {
-		log.Printf("failed to create HTTP request: %v", err)
+		slog.Error("failed to create HTTP request.", "err", err)
 		return err
 	}
 
@@ -174,14 +211,14 @@ func SendNoti(gotifyServer, appToken, title, message string, priority int) error
 
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("failed to send HTTP request: %v", err)
+		slog.Error("failed to send HTTP request.", "err", err)
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Printf("gotify server returned non-2xx status: %d %s", resp.StatusCode, resp.Status)
-		return err
+		slog.Error("gotify server returned non-2xx status.", "status", resp.Status)
+		return fmt.Errorf("gotify server returned %s", resp.Status)
 	}
 
 	return nil
@@ -198,27 +235,3 @@ func InitStaticServer() http.Handler {
 	fsHandler := http.FileServer(http.FS(staticFS))
 	return fsHandler
 }
-
-// InitPanelUsers returns the panel users map that each username maps to each password which is hashed already.
-// Each user should be seperated by comma(,).
-// Username and password of each user should be seperated by tilde(~).
-func InitPanelUsers(string) map[string]string {
-	users := make(map[string]string)
-	admins := strings.Split(*config.Admins, ",")
-	for _, admin := range admins {
-		info := strings.Split(admin, "~")
-		// maps the username to the hashed password.
-		password, _, err := HashPassword(info[1])
-		if err != nil {
-			panic("Can't hash the user passwords. Please check panel username and passwords.")
-		}
-
-		// sanity check.
-		if len(info[1]) > 30 || len(info[0]) > 30 {
-			panic("Usernames should not be more than 30 characters and Passwords 30.")
-		}
-		users[info[0]] = password
-	}
-	log.Println("users: ", users)
-	return users
-}
@@ -5,12 +5,11 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"slices"
 	"strconv"
-	"strings"
 
 	"github.com/goccy/go-json"
 	"github.com/htetmyatthar/lothone/internal/config"
@@ -26,34 +25,34 @@ var InternalServerErr = errors.New("Internal Server Error")
 func CreateVmessUser(c Client, configFile string, usersFile string) error {
 	configData, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Println("Error reading file:", err)
+		slog.Error("Error reading file.", "err", err)
 		return InternalServerErr
 	}
 
 	userData, err := os.ReadFile(usersFile)
 	if err != nil {
-		log.Println("Error reading user data file: ", err)
+		slog.Error("Error reading user data file.", "err", err)
 		return InternalServerErr
 	}
 
 	var configResult map[string]json.RawMessage
 	err = json.Unmarshal(configData, &configResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in config:", err)
+		slog.Error("Error unmarshalling JSON to map in config.", "err", err)
 		return InternalServerErr
 	}
 
 	var userResult map[string]json.RawMessage
 	err = json.Unmarshal(userData, &userResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in users:", err)
+		slog.Error("Error unmarshalling JSON to map in users.", "err", err)
 		return InternalServerErr
 	}
 
 	var inbounds []Inbound
 	err = json.Unmarshal(configResult["inbounds"], &inbounds)
 	if err != nil {
-		log.Println("Error unmarshalling 'inbounds':", err)
+		slog.Error("Error unmarshalling 'inbounds'.", "err", err)
 		return InternalServerErr
 	}
 
@@ -61,14 +60,14 @@ func CreateVmessUser(c Client, configFile string, usersFile string) error {
 	var users []Client
 	err = json.Unmarshal(userResult["clients"], &users)
 	if err != nil {
-		log.Println("Error unmarshalling 'users': ", err)
+		slog.Error("Error unmarshalling 'users'.", "err", err)
 		return InternalServerErr
 	}
 
 	// make sure that the server id doesn't already exist.
 	for _, client := range inbounds[0].Settings.Clients {
 		if client.Id == c.Id {
-			log.Println("Error : ", err)
+			slog.Error("Error.", "err", err)
 			return errors.New("Internal Server Error, Server ID already exists.")
 		}
 	}
@@ -78,7 +77,7 @@ func CreateVmessUser(c Client, configFile string, usersFile string) error {
 		AlterId: DefaultAlterID,
 	}
 
-	c.Port, _ = strconv.Atoi(*config.V2rayPort) // NOTE: ignored error
+	c.Port, _ = strconv.Atoi(config.Get().V2rayPort) // NOTE: ignored error
 
 	// append the new user.
 	inbounds[0].Settings.Clients = append(inbounds[0].Settings.Clients, newV2rayClient)
@@ -86,39 +85,39 @@ func CreateVmessUser(c Client, configFile string, usersFile string) error {
 
 	inboundBytes, err := json.Marshal(inbounds)
 	if err != nil {
-		log.Println("Error marshalling modified inbounds:", err)
+		slog.Error("Error marshalling modified inbounds.", "err", err)
 		return InternalServerErr
 	}
 	configResult["inbounds"] = inboundBytes
 
 	usersBytes, err := json.Marshal(users)
 	if err != nil {
-		log.Println("Error marshalling modified users:", err)
+		slog.Error("Error marshalling modified users.", "err", err)
 		return InternalServerErr
 	}
 	userResult["clients"] = usersBytes
 
 	finalConfigJSON, err := json.MarshalIndent(configResult, "", "  ")
 	if err != nil {
-		log.Println("Error marshalling final config JSON:", err)
+		slog.Error("Error marshalling final config JSON.", "err", err)
 		return InternalServerErr
 	}
 
 	finalUserJSON, err := json.MarshalIndent(userResult, "", " ")
 	if err != nil {
-		log.Println("Error marshalling final users JSON:", err)
+		slog.Error("Error marshalling final users JSON.", "err", err)
 		return InternalServerErr
 	}
 
 	err = os.WriteFile(configFile, finalConfigJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return InternalServerErr
 	}
 
 	err = os.WriteFile(usersFile, finalUserJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return InternalServerErr
 	}
 
@@ -129,41 +128,41 @@ func CreateVmessUser(c Client, configFile string, usersFile string) error {
 func DeleteVmessUser(serverId, deviceId, configFile, usersFile string) (*Client, int, error) {
 	configData, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Println("Error reading file:", err)
+		slog.Error("Error reading file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	userData, err := os.ReadFile(usersFile)
 	if err != nil {
-		log.Println("Error reading user data file: ", err)
+		slog.Error("Error reading user data file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var configResult map[string]json.RawMessage
 	err = json.Unmarshal(configData, &configResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in config:", err)
+		slog.Error("Error unmarshalling JSON to map in config.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var userResult map[string]json.RawMessage
 	err = json.Unmarshal(userData, &userResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in users:", err)
+		slog.Error("Error unmarshalling JSON to map in users.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var inbounds []Inbound
 	err = json.Unmarshal(configResult["inbounds"], &inbounds)
 	if err != nil {
-		log.Println("Error unmarshalling 'inbounds':", err)
+		slog.Error("Error unmarshalling 'inbounds'.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	var users []Client
 	err = json.Unmarshal(userResult["clients"], &users)
 	if err != nil {
-		log.Println("Error unmarshalling 'users': ", err)
+		slog.Error("Error unmarshalling 'users'.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
@@ -176,7 +175,7 @@ func DeleteVmessUser(serverId, deviceId, configFile, usersFile string) (*Client,
 	}
 
 	if users[index].Id != serverId && users[index].DeviceId != deviceId {
-		log.Println("Error invoking user deletion with incorrect information")
+		slog.Error("Error invoking user deletion with incorrect information.")
 		return nil, http.StatusForbidden, errors.New("User's not found")
 	}
 
@@ -186,39 +185,39 @@ func DeleteVmessUser(serverId, deviceId, configFile, usersFile string) (*Client,
 
 	inboundBytes, err := json.Marshal(inbounds)
 	if err != nil {
-		log.Println("Error marshalling modified inbounds:", err)
+		slog.Error("Error marshalling modified inbounds.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 	configResult["inbounds"] = inboundBytes
 
 	usersBytes, err := json.Marshal(users)
 	if err != nil {
-		log.Println("Error marshalling modified users:", err)
+		slog.Error("Error marshalling modified users.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 	userResult["clients"] = usersBytes
 
 	finalConfigJSON, err := json.MarshalIndent(configResult, "", "  ")
 	if err != nil {
-		log.Println("Error marshalling final config JSON:", err)
+		slog.Error("Error marshalling final config JSON.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	finalUserJSON, err := json.MarshalIndent(userResult, "", " ")
 	if err != nil {
-		log.Println("Error marshalling final users JSON:", err)
+		slog.Error("Error marshalling final users JSON.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	err = os.WriteFile(configFile, finalConfigJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	err = os.WriteFile(usersFile, finalUserJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
@@ -229,41 +228,41 @@ func DeleteVmessUser(serverId, deviceId, configFile, usersFile string) (*Client,
 func EditVmessUser(client Client, configFile, usersFile string) (*Client, int, error) {
 	configData, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Println("Error reading file:", err)
+		slog.Error("Error reading file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	userData, err := os.ReadFile(usersFile)
 	if err != nil {
-		log.Println("Error reading user data file: ", err)
+		slog.Error("Error reading user data file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var configResult map[string]json.RawMessage
 	err = json.Unmarshal(configData, &configResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in config:", err)
+		slog.Error("Error unmarshalling JSON to map in config.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var userResult map[string]json.RawMessage
 	err = json.Unmarshal(userData, &userResult)
 	if err != nil {
-		log.Println("Error unmarshalling JSON to map in users:", err)
+		slog.Error("Error unmarshalling JSON to map in users.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var inbounds []Inbound
 	err = json.Unmarshal(configResult["inbounds"], &inbounds)
 	if err != nil {
-		log.Println("Error unmarshalling 'inbounds':", err)
+		slog.Error("Error unmarshalling 'inbounds'.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	var users []Client
 	err = json.Unmarshal(userResult["clients"], &users)
 	if err != nil {
-		log.Println("Error unmarshalling 'users': ", err)
+		slog.Error("Error unmarshalling 'users'.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
@@ -276,7 +275,7 @@ func EditVmessUser(client Client, configFile, usersFile string) (*Client, int, e
 	}
 
 	if !found {
-		log.Println("Invalid user is being searched.")
+		slog.Warn("Invalid user is being searched.")
 		return nil, http.StatusBadRequest, errors.New("Bad Request")
 	}
 
@@ -300,39 +299,39 @@ func EditVmessUser(client Client, configFile, usersFile string) (*Client, int, e
 
 	inboundBytes, err := json.Marshal(inbounds)
 	if err != nil {
-		log.Println("Error marshalling modified inbounds:", err)
+		slog.Error("Error marshalling modified inbounds.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 	configResult["inbounds"] = inboundBytes
 
 	usersBytes, err := json.Marshal(users)
 	if err != nil {
-		log.Println("Error marshalling modified users:", err)
+		slog.Error("Error marshalling modified users.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 	userResult["clients"] = usersBytes
 
 	finalConfigJSON, err := json.MarshalIndent(configResult, "", "  ")
 	if err != nil {
-		log.Println("Error marshalling final config JSON:", err)
+		slog.Error("Error marshalling final config JSON.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	finalUserJSON, err := json.MarshalIndent(userResult, "", " ")
 	if err != nil {
-		log.Println("Error marshalling final users JSON:", err)
+		slog.Error("Error marshalling final users JSON.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	err = os.WriteFile(configFile, finalConfigJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 
 	err = os.WriteFile(usersFile, finalUserJSON, 0644)
 	if err != nil {
-		log.Println("Error writing modified JSON to file:", err)
+		slog.Error("Error writing modified JSON to file.", "err", err)
 		return nil, http.StatusInternalServerError, InternalServerErr
 	}
 	return &oldClient, http.StatusOK, nil
@@ -359,10 +358,9 @@ type vmessConfig struct {
 }
 
 func GenerateVmessURI(data Client) (string, error) {
-	subDomain := strings.Split(*config.WebHost, ".")[0]
-
+	cfg := config.Get()
 	vmessTemplate := vmessConfig{
-		Add:  *config.WebHost,
+		Add:  cfg.WebHost,
 		Aid:  "1",
 		Alpn: "",
 		Fp:   "",
@@ -370,12 +368,8 @@ func GenerateVmessURI(data Client) (string, error) {
 		ID:   data.Id,
 		Net:  "tcp",
 		Path: "/",
-		Port: *config.V2rayPort,
-		Ps: fmt.Sprintf("valid before (%s) %s-%s-%s",
-			data.ExpireDate,
-			subDomain,
-			*config.WebHostRegion,
-			data.Id[len(data.Id)-4:]),
+		Port: cfg.V2rayPort,
+		Ps:   cfg.Remarks(data.Id, data.ExpireDate),
 		Scy:  "none",
 		Sni:  "",
 		Tls:  "",
@@ -394,14 +388,13 @@ func GenerateVmessURI(data Client) (string, error) {
 
 // GenerateLockedURI generates a locked VMESS URI
 func GenerateVmessLockedURI(data Client) (string, error) {
+	cfg := config.Get()
 	if data.DeviceId == "" {
 		return "", fmt.Errorf("unable to generate locked QR without device id")
 	}
 
-	subDomain := strings.Split(*config.WebHost, ".")[0]
-
 	vmessTemplate := vmessConfig{
-		Add:      *config.WebHost,
+		Add:      cfg.WebHost,
 		Aid:      "1",
 		Alpn:     "",
 		DeviceID: data.DeviceId,
@@ -410,17 +403,13 @@ func GenerateVmessLockedURI(data Client) (string, error) {
 		ID:       data.Id,
 		Net:      "tcp",
 		Path:     "/",
-		Port:     *config.V2rayPort,
-		Ps: fmt.Sprintf("valid before (%s) %s-%s-%s",
-			data.ExpireDate,
-			subDomain,
-			*config.WebHostRegion,
-			data.Id[len(data.Id)-4:]),
-		Scy:  "none",
-		Sni:  "",
-		Tls:  "",
-		Type: "http",
-		V:    "2",
+		Port:     cfg.V2rayPort,
+		Ps:       cfg.Remarks(data.Id, data.ExpireDate),
+		Scy:      "none",
+		Sni:      "",
+		Tls:      "",
+		Type:     "http",
+		V:        "2",
 	}
 
 	jsonData, err := json.Marshal(vmessTemplate)
@@ -0,0 +1,152 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errCBOR = errors.New("invalid cbor")
+
+// decodeCBOR decodes the first cbor item of the data, returning the rest of it. Only the
+// definite lengths of what the authenticators send are supported. The integers are int64,
+// the byte strings []byte, the text strings string, the arrays []any and the maps map[any]any.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeItem(data, 0)
+}
+
+// maxDepth limits the nesting of the arrays and the maps.
+const maxDepth = 16
+
+func decodeItem(data []byte, depth int) (any, []byte, error) {
+	if depth > maxDepth {
+		return nil, nil, fmt.Errorf("%w: too deep", errCBOR)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	// simple values have no argument to read.
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		}
+		return nil, nil, fmt.Errorf("%w: unsupported simple value %d", errCBOR, info)
+	}
+
+	n, data, err := readArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return int64(n), data, nil
+
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return -1 - int64(n), data, nil
+
+	case 2, 3:
+		if n > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+		if major == 2 {
+			return data[:n], data[n:], nil
+		}
+		return string(data[:n]), data[n:], nil
+
+	case 4:
+		if n > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+		items := make([]any, 0, n)
+		for range n {
+			var item any
+			item, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+
+	case 5:
+		if n > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+		m := make(map[any]any, n)
+		for range n {
+			var key, value any
+			key, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key", errCBOR)
+			}
+			value, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, data, nil
+
+	case 6:
+		// the tags are ignored, only the tagged item is kept.
+		return decodeItem(data, depth+1)
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported major type %d", errCBOR, major)
+}
+
+func readArgument(info byte, data []byte) (uint64, []byte, error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, nil, fmt.Errorf("%w: indefinite lengths are not supported", errCBOR)
+	}
+
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+	var n uint64
+	switch size {
+	case 1:
+		n = uint64(data[0])
+	case 2:
+		n = uint64(binary.BigEndian.Uint16(data))
+	case 4:
+		n = uint64(binary.BigEndian.Uint32(data))
+	case 8:
+		n = binary.BigEndian.Uint64(data)
+	}
+	return n, data[size:], nil
+}
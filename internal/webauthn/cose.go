@@ -0,0 +1,100 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms of the supported public keys.
+const (
+	algES256 = -7
+	algEdDSA = -8
+	algRS256 = -257
+)
+
+// COSE key parameters.
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2 // also the modulus n of the rsa keys.
+	coseY   = -3 // also the exponent e of the rsa keys.
+)
+
+var ErrAlgorithm = errors.New("Unsupported public key algorithm.")
+
+// parsePublicKey parses the COSE encoded public key.
+func parsePublicKey(cose []byte) (crypto.PublicKey, error) {
+	value, _, err := decodeCBOR(cose)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid public key: %v", err)
+	}
+	key, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.New("Invalid public key.")
+	}
+	alg, _ := key[int64(coseAlg)].(int64)
+	x, _ := key[int64(coseX)].([]byte)
+	y, _ := key[int64(coseY)].([]byte)
+
+	switch alg {
+	case algES256:
+		crv, _ := key[int64(coseCrv)].(int64)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("Invalid ES256 public key.")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("Invalid ES256 public key.")
+		}
+		return pub, nil
+
+	case algEdDSA:
+		crv, _ := key[int64(coseCrv)].(int64)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("Invalid EdDSA public key.")
+		}
+		return ed25519.PublicKey(x), nil
+
+	case algRS256:
+		e := new(big.Int).SetBytes(y)
+		if len(x) < 256 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("Invalid RS256 public key.")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(x), E: int(e.Int64())}, nil
+	}
+	return nil, ErrAlgorithm
+}
+
+// verifySignature verifies the signature of the message with the COSE encoded public key.
+func verifySignature(cose, message, signature []byte) error {
+	pub, err := parsePublicKey(cose)
+	if err != nil {
+		return err
+	}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		hash := sha256.Sum256(message)
+		if ecdsa.VerifyASN1(pub, hash[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(pub, message, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		hash := sha256.Sum256(message)
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature) == nil {
+			return nil
+		}
+	}
+	return ErrSignature
+}
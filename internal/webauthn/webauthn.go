@@ -0,0 +1,369 @@
+// Package webauthn registers the security keys and the platform passkeys of the admins and
+// verifies their logins with the Web Authentication api of the browsers.
+//
+// Only what the panel needs is implemented: the attestation is not asked for, so the
+// registrations are trusted the same as the logged in admin registering them, and the
+// ES256, EdDSA and RS256 public keys are supported.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrChallenge = errors.New("The challenge is not the one that was given.")
+	ErrOrigin    = errors.New("The origin is not of the panel.")
+	ErrRPID      = errors.New("The credential is not of the panel.")
+	ErrPresence  = errors.New("The user is not present.")
+	ErrVerified  = errors.New("The user is not verified.")
+	ErrSignature = errors.New("Invalid signature.")
+	// ErrSignCount is likely to be a cloned authenticator.
+	ErrSignCount = errors.New("The signature counter went backwards.")
+)
+
+// timeout of the browser prompts in milliseconds.
+const timeout = 120000
+
+// authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// RelyingParty is the panel the credentials are registered to.
+type RelyingParty struct {
+	// ID is the domain name of the panel.
+	ID   string
+	Name string
+	// Origin is where the panel is opened in the browser, eg. https://example.com:8888.
+	Origin string
+}
+
+// Credential is a registered public key credential.
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE encoded public key.
+	PublicKey []byte
+	SignCount uint32
+}
+
+// NewChallenge returns a random challenge to be signed by the authenticator.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
+
+// Descriptor identifies a credential in the options.
+type Descriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type entity struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are the options of navigator.credentials.create, the binary values
+// are base64url encoded to be decoded by the script.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     entity                 `json:"rp"`
+	User                   entity                 `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []Descriptor           `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options of navigator.credentials.get, the binary values
+// are base64url encoded to be decoded by the script.
+type RequestOptions struct {
+	Challenge        string       `json:"challenge"`
+	RPID             string       `json:"rpId"`
+	Timeout          int          `json:"timeout"`
+	AllowCredentials []Descriptor `json:"allowCredentials"`
+	UserVerification string       `json:"userVerification"`
+}
+
+// CreationOptions returns the options to register a new credential of the user, the
+// existing credentials of the user are excluded. The credentials are made discoverable
+// when the authenticator can, so they can be used without a username.
+func (rp RelyingParty) CreationOptions(challenge, user string, exclude [][]byte) CreationOptions {
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        entity{ID: rp.ID, Name: rp.Name},
+		User:      entity{ID: encode([]byte(user)), Name: user, DisplayName: user},
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: algES256},
+			{Type: "public-key", Alg: algEdDSA},
+			{Type: "public-key", Alg: algRS256},
+		},
+		Timeout:            timeout,
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns the options to log in with one of the allowed credentials, or with
+// any discoverable credential of the panel when none are given. The user verification is
+// required for the logins without the password.
+func (rp RelyingParty) RequestOptions(challenge string, allow [][]byte, userVerification bool) RequestOptions {
+	uv := "preferred"
+	if userVerification {
+		uv = "required"
+	}
+	return RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          timeout,
+		AllowCredentials: descriptors(allow),
+		UserVerification: uv,
+	}
+}
+
+func descriptors(ids [][]byte) []Descriptor {
+	list := []Descriptor{}
+	for _, id := range ids {
+		list = append(list, Descriptor{Type: "public-key", ID: encode(id)})
+	}
+	return list
+}
+
+// AttestationResponse is the result of navigator.credentials.create sent back by the
+// script, the binary values are base64url encoded.
+type AttestationResponse struct {
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+}
+
+// AssertionResponse is the result of navigator.credentials.get sent back by the script,
+// the binary values are base64url encoded.
+type AssertionResponse struct {
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// CredentialID returns the decoded id of the credential.
+func (r AssertionResponse) CredentialID() ([]byte, error) {
+	return decode(r.ID)
+}
+
+// User returns the user the credential is registered for, empty if the authenticator didn't give it.
+func (r AssertionResponse) User() string {
+	user, err := decode(r.UserHandle)
+	if err != nil {
+		return ""
+	}
+	return string(user)
+}
+
+// VerifyRegistration verifies the new credential made for the challenge.
+func (rp RelyingParty) VerifyRegistration(challenge string, r AttestationResponse) (Credential, error) {
+	clientData, err := decode(r.ClientDataJSON)
+	if err != nil {
+		return Credential{}, fmt.Errorf("Invalid client data: %v", err)
+	}
+	err = rp.verifyClientData(clientData, "webauthn.create", challenge)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	object, err := decode(r.AttestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("Invalid attestation object: %v", err)
+	}
+	value, _, err := decodeCBOR(object)
+	if err != nil {
+		return Credential{}, fmt.Errorf("Invalid attestation object: %v", err)
+	}
+	attestation, ok := value.(map[any]any)
+	if !ok {
+		return Credential{}, errors.New("Invalid attestation object.")
+	}
+	authData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return Credential{}, errors.New("Invalid attestation object: no authenticator data.")
+	}
+
+	data, err := rp.verifyAuthData(authData, false)
+	if err != nil {
+		return Credential{}, err
+	}
+	if data.flags&flagAttested == 0 {
+		return Credential{}, errors.New("Invalid authenticator data: no credential.")
+	}
+
+	id, err := decode(r.ID)
+	if err != nil || !bytes.Equal(id, data.credentialID) {
+		return Credential{}, errors.New("Invalid credential id.")
+	}
+	_, err = parsePublicKey(data.publicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+	return Credential{ID: data.credentialID, PublicKey: data.publicKey, SignCount: data.signCount}, nil
+}
+
+// VerifyLogin verifies the assertion of the credential for the challenge, returning the new
+// signature counter to be saved.
+func (rp RelyingParty) VerifyLogin(challenge string, c Credential, r AssertionResponse, userVerification bool) (uint32, error) {
+	clientData, err := decode(r.ClientDataJSON)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid client data: %v", err)
+	}
+	err = rp.verifyClientData(clientData, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := decode(r.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid authenticator data: %v", err)
+	}
+	data, err := rp.verifyAuthData(authData, userVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	signature, err := decode(r.Signature)
+	if err != nil {
+		return 0, ErrSignature
+	}
+	hash := sha256.Sum256(clientData)
+	err = verifySignature(c.PublicKey, append(authData[:len(authData):len(authData)], hash[:]...), signature)
+	if err != nil {
+		return 0, err
+	}
+
+	// the authenticators without a counter always give zero.
+	if (data.signCount != 0 || c.SignCount != 0) && data.signCount <= c.SignCount {
+		return 0, ErrSignCount
+	}
+	return data.signCount, nil
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func (rp RelyingParty) verifyClientData(data []byte, typ, challenge string) error {
+	var c clientData
+	err := json.Unmarshal(data, &c)
+	if err != nil {
+		return fmt.Errorf("Invalid client data: %v", err)
+	}
+	if c.Type != typ {
+		return fmt.Errorf("Invalid client data type %q.", c.Type)
+	}
+	if challenge == "" || strings.TrimRight(c.Challenge, "=") != challenge {
+		return ErrChallenge
+	}
+	if c.Origin != rp.Origin {
+		return ErrOrigin
+	}
+	return nil
+}
+
+type authData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (rp RelyingParty) verifyAuthData(data []byte, userVerification bool) (authData, error) {
+	var a authData
+	if len(data) < 37 {
+		return a, errors.New("Invalid authenticator data: too short.")
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return a, ErrRPID
+	}
+	a.flags = data[32]
+	if a.flags&flagUserPresent == 0 {
+		return a, ErrPresence
+	}
+	if userVerification && a.flags&flagUserVerified == 0 {
+		return a, ErrVerified
+	}
+	a.signCount = binary.BigEndian.Uint32(data[33:37])
+
+	if a.flags&flagAttested == 0 {
+		return a, nil
+	}
+	rest := data[37:]
+	if len(rest) < 18 {
+		return a, errors.New("Invalid authenticator data: too short.")
+	}
+	// the aaguid of the authenticator model is not used.
+	n := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < n {
+		return a, errors.New("Invalid authenticator data: too short.")
+	}
+	a.credentialID = rest[:n]
+
+	_, extensions, err := decodeCBOR(rest[n:])
+	if err != nil {
+		return a, fmt.Errorf("Invalid credential public key: %v", err)
+	}
+	a.publicKey = rest[n : len(rest)-len(extensions)]
+	return a, nil
+}
+
+// EncodeID encodes the credential id the same as the browsers.
+func EncodeID(id []byte) string {
+	return encode(id)
+}
+
+// DecodeID decodes the credential id given by the browsers.
+func DecodeID(s string) ([]byte, error) {
+	return decode(s)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode decodes the base64url values of the browsers, with or without the padding.
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,198 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+var testRP = RelyingParty{ID: "sg1.example.com", Name: "Lothone", Origin: "https://sg1.example.com:8888"}
+
+// cborHead encodes the major type and the argument of a cbor item.
+func cborHead(major byte, n int) []byte {
+	if n < 24 {
+		return []byte{major<<5 | byte(n)}
+	}
+	if n < 256 {
+		return []byte{major<<5 | 24, byte(n)}
+	}
+	return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+}
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHead(1, -1-n)
+	}
+	return cborHead(0, n)
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, len(s)), s...)
+}
+
+// authenticator is a software authenticator with an ES256 key.
+type authenticator struct {
+	key   *ecdsa.PrivateKey
+	id    []byte
+	count uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authenticator{key: key, id: []byte("credential-id")}
+}
+
+func (a *authenticator) publicKey() []byte {
+	var cose []byte
+	cose = append(cose, cborHead(5, 5)...)
+	cose = append(cose, cborInt(coseKty)...)
+	cose = append(cose, cborInt(2)...)
+	cose = append(cose, cborInt(coseAlg)...)
+	cose = append(cose, cborInt(algES256)...)
+	cose = append(cose, cborInt(coseCrv)...)
+	cose = append(cose, cborInt(1)...)
+	cose = append(cose, cborInt(coseX)...)
+	cose = append(cose, cborBytes(a.key.X.FillBytes(make([]byte, 32)))...)
+	cose = append(cose, cborInt(coseY)...)
+	cose = append(cose, cborBytes(a.key.Y.FillBytes(make([]byte, 32)))...)
+	return cose
+}
+
+func (a *authenticator) authData(flags byte, attested bool) []byte {
+	hash := sha256.Sum256([]byte(testRP.ID))
+	data := append(hash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.count)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.id)))
+		data = append(data, a.id...)
+		data = append(data, a.publicKey()...)
+	}
+	return data
+}
+
+func clientDataJSON(t *testing.T, typ, challenge, origin string) []byte {
+	t.Helper()
+	data, err := json.Marshal(clientData{Type: typ, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (a *authenticator) create(t *testing.T, challenge string) AttestationResponse {
+	var object []byte
+	object = append(object, cborHead(5, 3)...)
+	object = append(object, cborText("fmt")...)
+	object = append(object, cborText("none")...)
+	object = append(object, cborText("attStmt")...)
+	object = append(object, cborHead(5, 0)...)
+	object = append(object, cborText("authData")...)
+	object = append(object, cborBytes(a.authData(flagUserPresent|flagAttested, true))...)
+
+	return AttestationResponse{
+		ID:                encode(a.id),
+		ClientDataJSON:    encode(clientDataJSON(t, "webauthn.create", challenge, testRP.Origin)),
+		AttestationObject: encode(object),
+	}
+}
+
+func (a *authenticator) get(t *testing.T, challenge, origin string, flags byte) AssertionResponse {
+	a.count++
+	authData := a.authData(flags, false)
+	clientData := clientDataJSON(t, "webauthn.get", challenge, origin)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), hash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return AssertionResponse{
+		ID:                encode(a.id),
+		ClientDataJSON:    encode(clientData),
+		AuthenticatorData: encode(authData),
+		Signature:         encode(signature),
+		UserHandle:        encode([]byte("admin")),
+	}
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	a := newAuthenticator(t)
+
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := testRP.VerifyRegistration(challenge, a.create(t, challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.ID, a.id) {
+		t.Errorf("expected the credential id %q, got %q", a.id, c.ID)
+	}
+
+	challenge, _ = NewChallenge()
+	r := a.get(t, challenge, testRP.Origin, flagUserPresent|flagUserVerified)
+	if r.User() != "admin" {
+		t.Errorf("expected the user handle of admin, got %q", r.User())
+	}
+	count, err := testRP.VerifyLogin(challenge, c, r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected the sign count 1, got %d", count)
+	}
+
+	// the same assertion is refused once the counter is saved.
+	c.SignCount = count
+	if _, err := testRP.VerifyLogin(challenge, c, r, true); err != ErrSignCount {
+		t.Errorf("expected the replayed assertion to be refused, got %v", err)
+	}
+}
+
+func TestLoginRefused(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge, _ := NewChallenge()
+	c, err := testRP.VerifyRegistration(challenge, a.create(t, challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		r    AssertionResponse
+		uv   bool
+		want error
+	}{
+		{"wrong challenge", a.get(t, "other", testRP.Origin, flagUserPresent), false, ErrChallenge},
+		{"wrong origin", a.get(t, challenge, "https://evil.example.com", flagUserPresent), false, ErrOrigin},
+		{"not present", a.get(t, challenge, testRP.Origin, 0), false, ErrPresence},
+		{"not verified", a.get(t, challenge, testRP.Origin, flagUserPresent), true, ErrVerified},
+	}
+	for _, tt := range tests {
+		if _, err := testRP.VerifyLogin(challenge, c, tt.r, tt.uv); err != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	r := a.get(t, challenge, testRP.Origin, flagUserPresent)
+	r.Signature = encode([]byte("not a signature"))
+	if _, err := testRP.VerifyLogin(challenge, c, r, false); err != ErrSignature {
+		t.Errorf("expected the invalid signature to be refused, got %v", err)
+	}
+}
@@ -1,39 +1,48 @@
 package auth
 
 import (
-	"log"
 	"net/http"
 
+	"github.com/alexedwards/scs/v2"
+	"github.com/htetmyatthar/lothone/internal/logging"
 	"github.com/htetmyatthar/lothone/internal/utils"
-	"github.com/htetmyatthar/lothone/middleware/session"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println("auth middleware is started.")
-		// Proceed to next handler
-		authenticated := session.GetSessionMgr().GetBool(r.Context(), utils.AuthenticatedField)
-
-		if !authenticated {
-			log.Println("Unauthenticated user redirecting to user login form")
-			session.GetSessionMgr().Put(r.Context(), utils.URLAfterLogin, r.URL.String())
-
-			// for htmx requests.
-			if r.Header.Get("HX-Request") == "true" {
-				log.Println("trying to handle htmx request.")
-				w.Header().Set("HX-Redirect", "/login")
-				w.Header().Set("HX-Push-Url", "/login")
-				w.WriteHeader(http.StatusOK)
+// AuthMiddleware redirects the users that are not logged in the sessions to the login page.
+func AuthMiddleware(sessions *scs.SessionManager) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logging.FromContext(r.Context()).Debug("auth middleware is started.")
+			// the api token requests are authenticated by the TokenMiddleware.
+			if FromToken(r.Context()) {
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			// for normal html requests.
-			log.Println("trying to handle html request.")
-			http.Redirect(w, r, "/login", http.StatusFound)
-			return
+			// Proceed to next handler
+			authenticated := sessions.GetBool(r.Context(), utils.AuthenticatedField)
 
-		}
-		log.Println("auth middleware successfully executed.")
-		next.ServeHTTP(w, r)
-	})
+			if !authenticated {
+				logging.FromContext(r.Context()).Info("Unauthenticated user redirecting to user login form.")
+				sessions.Put(r.Context(), utils.URLAfterLogin, r.URL.String())
+
+				// for htmx requests.
+				if r.Header.Get("HX-Request") == "true" {
+					logging.FromContext(r.Context()).Debug("trying to handle htmx request.")
+					w.Header().Set("HX-Redirect", "/login")
+					w.Header().Set("HX-Push-Url", "/login")
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+
+				// for normal html requests.
+				logging.FromContext(r.Context()).Debug("trying to handle html request.")
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+
+			}
+			logging.FromContext(r.Context()).Debug("auth middleware successfully executed.")
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/htetmyatthar/lothone/internal/logging"
+	"github.com/htetmyatthar/lothone/internal/utils"
+)
+
+// Role is what an admin is allowed to do in the panel.
+type Role string
+
+const (
+	// RoleOwner can do everything, including managing the other admins.
+	RoleOwner Role = "owner"
+	// RoleAdmin manages the accounts and the server.
+	RoleAdmin Role = "admin"
+	// RoleReseller creates and edits its own accounts.
+	RoleReseller Role = "reseller"
+	// RoleReadOnly only views the accounts of everyone.
+	RoleReadOnly Role = "read-only"
+)
+
+// Roles are every role, from the most allowed one.
+var Roles = []Role{RoleOwner, RoleAdmin, RoleReseller, RoleReadOnly}
+
+// Permission is an action of the panel that needs to be allowed by the role.
+type Permission uint
+
+const (
+	ViewAccounts Permission = 1 << iota
+	CreateAccounts
+	EditAccounts
+	DeleteAccounts
+	ManageServer
+	ManageAdmins
+	// AllAccounts is the access to the accounts of every admin, otherwise only the owned ones.
+	AllAccounts
+	// TransferAccounts gives the accounts to another admin.
+	TransferAccounts
+	// ViewServer sees the status of the services.
+	ViewServer
+)
+
+var permissions = map[Role]Permission{
+	RoleOwner:    ViewAccounts | CreateAccounts | EditAccounts | DeleteAccounts | ViewServer | ManageServer | ManageAdmins | AllAccounts | TransferAccounts,
+	RoleAdmin:    ViewAccounts | CreateAccounts | EditAccounts | DeleteAccounts | ViewServer | ManageServer | AllAccounts,
+	RoleReseller: ViewAccounts | CreateAccounts | EditAccounts,
+	RoleReadOnly: ViewAccounts | ViewServer | AllAccounts,
+}
+
+// ParseRole returns the role of the name.
+func ParseRole(name string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == strings.ToLower(strings.TrimSpace(name)) {
+			return role, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q, must be one of %v", name, Roles)
+}
+
+// SavedRole returns the role saved for an admin, the admins saved before the roles are owners
+// because they could do everything.
+func SavedRole(saved string) Role {
+	if saved == "" {
+		return RoleOwner
+	}
+	return Role(saved)
+}
+
+// Can reports whether the role is allowed to do the p.
+func (r Role) Can(p Permission) bool {
+	return permissions[r]&p == p
+}
+
+type (
+	roleKey  struct{}
+	adminKey struct{}
+)
+
+// RoleFromContext returns the role of the logged in admin put in by the RoleMiddleware,
+// empty if there's none, which is allowed nothing.
+func RoleFromContext(ctx context.Context) Role {
+	role, _ := ctx.Value(roleKey{}).(Role)
+	return role
+}
+
+// AdminFromContext returns the username of the logged in admin, or of the api token, put in by the RoleMiddleware.
+func AdminFromContext(ctx context.Context) string {
+	admin, _ := ctx.Value(adminKey{}).(string)
+	return admin
+}
+
+// Can reports whether the logged in admin of the ctx is allowed to do the p, to be used
+// by the templates rendered with the context of the request. The api tokens are also limited
+// by their scopes.
+func Can(ctx context.Context, p Permission) bool {
+	if t, ok := ctx.Value(tokenKey{}).(tokenAuth); ok && (t.scopes|AllAccounts)&p != p {
+		return false
+	}
+	return RoleFromContext(ctx).Can(p)
+}
+
+// RoleMiddleware puts the username and the role of the logged in admin, or of the api token,
+// into the request context. The role is looked up on every request, so the changes apply
+// without logging in again. It must be used after the AuthMiddleware.
+func RoleMiddleware(sessions *scs.SessionManager, roleOf func(username string) (Role, error)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username := sessions.GetString(r.Context(), utils.AdminField)
+			if t, ok := r.Context().Value(tokenKey{}).(tokenAuth); ok {
+				username = t.admin
+			}
+			role, err := roleOf(username)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("getting the role of the admin gone wrong.", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			ctx := context.WithValue(r.Context(), adminKey{}, username)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, roleKey{}, role)))
+		})
+	}
+}
+
+// Require refuses the requests of the admins whose role doesn't allow the p.
+// It must be used after the RoleMiddleware.
+func Require(p Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Can(r.Context(), p) {
+				logging.FromContext(r.Context()).Warn("Forbidden request of the role.", "role", RoleFromContext(r.Context()), "method", r.Method, "path", r.URL.Path)
+				// htmx doesn't swap the error responses, the page stays as it is.
+				http.Error(w, "Forbidden: your role is not allowed to do this.", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
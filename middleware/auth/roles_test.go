@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	tests := []struct {
+		role    Role
+		allowed []Permission
+		refused []Permission
+	}{
+		{RoleOwner, []Permission{ViewAccounts, DeleteAccounts, ManageServer, ManageAdmins, TransferAccounts}, nil},
+		{RoleAdmin, []Permission{CreateAccounts, DeleteAccounts, ManageServer, AllAccounts}, []Permission{ManageAdmins, TransferAccounts}},
+		{RoleReseller, []Permission{ViewAccounts, CreateAccounts, EditAccounts}, []Permission{DeleteAccounts, ManageServer, ManageAdmins, AllAccounts}},
+		{RoleReadOnly, []Permission{ViewAccounts, AllAccounts}, []Permission{CreateAccounts, EditAccounts, DeleteAccounts, ManageServer}},
+		{"", nil, []Permission{ViewAccounts}},
+	}
+	for _, tt := range tests {
+		for _, p := range tt.allowed {
+			if !tt.role.Can(p) {
+				t.Errorf("expected %q to be allowed %d", tt.role, p)
+			}
+		}
+		for _, p := range tt.refused {
+			if tt.role.Can(p) {
+				t.Errorf("expected %q to be refused %d", tt.role, p)
+			}
+		}
+	}
+
+	if role, err := ParseRole(" Read-Only "); err != nil || role != RoleReadOnly {
+		t.Errorf("unexpected role %q, %v", role, err)
+	}
+	if _, err := ParseRole("root"); err == nil {
+		t.Error("expected the unknown role to be refused")
+	}
+	if SavedRole("") != RoleOwner {
+		t.Error("expected the admins without a role to be owners")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	handler := Require(DeleteAccounts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for role, code := range map[Role]int{RoleAdmin: http.StatusOK, RoleReseller: http.StatusForbidden, "": http.StatusForbidden} {
+		r := httptest.NewRequest(http.MethodDelete, "/accounts", nil)
+		r = r.WithContext(context.WithValue(r.Context(), roleKey{}, role))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("expected %d for %q, got %d", code, role, w.Code)
+		}
+	}
+}
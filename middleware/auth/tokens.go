@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/htetmyatthar/lothone/internal/logging"
+	"github.com/htetmyatthar/lothone/middleware/csrf"
+)
+
+// Scopes are what the api tokens can be allowed to do, each one needs the permissions of it
+// to be allowed by the role of the admin of the token too.
+var Scopes = map[string]Permission{
+	"accounts:read":  ViewAccounts,
+	"accounts:write": CreateAccounts | EditAccounts | DeleteAccounts,
+	"server:read":    ViewServer,
+	"server:write":   ManageServer,
+}
+
+// ScopeNames are the names of the Scopes in order.
+var ScopeNames = []string{"accounts:read", "accounts:write", "server:read", "server:write"}
+
+// ScopePermissions returns the permissions of the scopes.
+func ScopePermissions(scopes []string) (Permission, error) {
+	var p Permission
+	for _, scope := range scopes {
+		sp, ok := Scopes[scope]
+		if !ok {
+			return 0, fmt.Errorf("unknown scope %q, must be one of %v", scope, ScopeNames)
+		}
+		p |= sp
+	}
+	return p, nil
+}
+
+// TokenLookup returns the admin and the scopes of the valid api token.
+type TokenLookup func(token string) (admin string, scopes []string, err error)
+
+type tokenKey struct{}
+
+type tokenAuth struct {
+	admin  string
+	scopes Permission
+}
+
+// FromToken reports whether the request of the ctx is authenticated with an api token.
+func FromToken(ctx context.Context) bool {
+	_, ok := ctx.Value(tokenKey{}).(tokenAuth)
+	return ok
+}
+
+// TokenMiddleware authenticates the requests with the api token of the Authorization: Bearer
+// header. Those requests don't use the cookies, so they are exempt from the csrf check, and
+// they must be let in before the csrf.CSRFMiddleware. The requests without the header are
+// left to the sessions.
+func TokenMiddleware(lookup TokenLookup) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				tokenUnauthorized(w, "Unauthorized: only the Bearer api tokens are accepted.")
+				return
+			}
+			admin, scopes, err := lookup(strings.TrimSpace(token))
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("Request with invalid api token.", "err", err)
+				tokenUnauthorized(w, "Unauthorized: invalid or expired api token.")
+				return
+			}
+			p, err := ScopePermissions(scopes)
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("api token with invalid scopes.", "err", err)
+				tokenUnauthorized(w, "Unauthorized: invalid api token scopes.")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), tokenKey{}, tokenAuth{admin: admin, scopes: p})
+			next.ServeHTTP(w, csrf.Exempt(r.WithContext(ctx)))
+		})
+	}
+}
+
+func tokenUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="lothone"`)
+	http.Error(w, message, http.StatusUnauthorized)
+}
+
+// SessionOnly refuses the api token requests to the pages of the logged in admins, eg. changing
+// the login of the admin.
+func SessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if FromToken(r.Context()) {
+			http.Error(w, "Forbidden: api tokens can't be used here.", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
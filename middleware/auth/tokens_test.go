@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScopePermissions(t *testing.T) {
+	p, err := ScopePermissions([]string{"accounts:read", "server:write"})
+	if err != nil || p != ViewAccounts|ManageServer {
+		t.Errorf("unexpected permissions %d, %v", p, err)
+	}
+	if _, err := ScopePermissions([]string{"admins:write"}); err == nil {
+		t.Error("expected the unknown scope to be refused")
+	}
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	lookup := func(token string) (string, []string, error) {
+		if token != "valid" {
+			return "", nil, errors.New("invalid token")
+		}
+		return "bot", []string{"accounts:read"}, nil
+	}
+	var ctx context.Context
+	handler := TokenMiddleware(lookup)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+
+	for header, code := range map[string]int{"": http.StatusOK, "Bearer valid": http.StatusOK, "Bearer wrong": http.StatusUnauthorized, "Basic valid": http.StatusUnauthorized} {
+		ctx = nil
+		r := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+		r.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("expected %d for %q, got %d", code, header, w.Code)
+		}
+		if code == http.StatusOK && FromToken(ctx) != (header != "") {
+			t.Errorf("unexpected token authentication for %q", header)
+		}
+	}
+
+	// the token of an owner is still limited by its scopes.
+	r := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	r.Header.Set("Authorization", "Bearer valid")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	ctx = context.WithValue(ctx, roleKey{}, RoleOwner)
+	if !Can(ctx, ViewAccounts) || !Can(ctx, AllAccounts) || Can(ctx, DeleteAccounts) {
+		t.Error("expected the token to be allowed its scopes only")
+	}
+}
@@ -0,0 +1,109 @@
+// Package clientip finds the ip address of the clients behind the reverse proxies and
+// limits the routes to the allowed ones.
+package clientip
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// The headers the reverse proxies give the ip address of the client with.
+const (
+	XForwardedFor  = "X-Forwarded-For"
+	XRealIP        = "X-Real-IP"
+	CFConnectingIP = "CF-Connecting-IP"
+)
+
+// Headers are the headers that can be preferred for the ip address of the client.
+var Headers = []string{XForwardedFor, XRealIP, CFConnectingIP}
+
+type ipKey struct{}
+
+// Resolve returns the ip address of the client of the r. The headers are only believed when the
+// request is from a trustedProxy, the first one of them the request has is used. The last address
+// of the X-Forwarded-For that is not a trusted proxy is the client, the ones before it can be made up
+// by the client. The other headers have only the address of the client set by the proxy.
+func Resolve(r *http.Request, trustedProxy func(ip netip.Addr) bool, headers []string) netip.Addr {
+	ip := remoteIP(r)
+	if !ip.IsValid() || !trustedProxy(ip) {
+		return ip
+	}
+
+	for _, header := range headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		if !strings.EqualFold(header, XForwardedFor) {
+			client, err := netip.ParseAddr(strings.TrimSpace(values[0]))
+			if err != nil {
+				return ip
+			}
+			return client.Unmap()
+		}
+
+		forwarded := strings.Split(strings.Join(values, ","), ",")
+		for i := len(forwarded) - 1; i >= 0 && trustedProxy(ip); i-- {
+			next, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+			if err != nil {
+				break
+			}
+			ip = next.Unmap()
+		}
+		return ip
+	}
+	return ip
+}
+
+// Middleware resolves the ip address of the client of every request once with the resolve,
+// the handlers get it by the FromRequest.
+func Middleware(resolve func(r *http.Request) netip.Addr) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ipKey{}, resolve(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// FromRequest returns the ip address of the client resolved by the Middleware, or the remote
+// address of the r if it's not resolved. It's not valid if the remote address is not an ip address.
+func FromRequest(r *http.Request) netip.Addr {
+	if ip, ok := r.Context().Value(ipKey{}).(netip.Addr); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// Allow lets only the clients whose ip address is allowed through, the others are served by the forbidden.
+// It must be used after the Middleware.
+func Allow(allowed func(ip netip.Addr) bool, forbidden http.Handler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := FromRequest(r)
+			if !ip.IsValid() || !allowed(ip) {
+				slog.Warn("Forbidden request of an untrusted ip.", "ip", ip, "method", r.Method, "path", r.URL.Path)
+				forbidden.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func remoteIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ip.Unmap()
+}
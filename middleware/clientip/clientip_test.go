@@ -0,0 +1,59 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	proxy := netip.MustParsePrefix("10.0.0.0/8")
+	trustedProxy := func(ip netip.Addr) bool { return proxy.Contains(ip) }
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		prefer  []string
+		want    string
+	}{
+		{"direct", "1.2.3.4:1000", nil, Headers, "1.2.3.4"},
+		{"untrusted proxy", "1.2.3.4:1000", map[string]string{XForwardedFor: "5.6.7.8"}, Headers, "1.2.3.4"},
+		{"trusted proxy", "10.0.0.1:1000", map[string]string{XForwardedFor: "5.6.7.8"}, Headers, "5.6.7.8"},
+		{"proxy chain", "10.0.0.1:1000", map[string]string{XForwardedFor: "9.9.9.9, 5.6.7.8, 10.0.0.2"}, Headers, "5.6.7.8"},
+		{"invalid forwarded", "10.0.0.1:1000", map[string]string{XForwardedFor: "unknown"}, Headers, "10.0.0.1"},
+		{"real ip", "10.0.0.1:1000", map[string]string{XRealIP: "5.6.7.8"}, Headers, "5.6.7.8"},
+		{"preferred header", "10.0.0.1:1000", map[string]string{XForwardedFor: "9.9.9.9", CFConnectingIP: "5.6.7.8"}, []string{CFConnectingIP, XForwardedFor}, "5.6.7.8"},
+		{"header not preferred", "10.0.0.1:1000", map[string]string{CFConnectingIP: "5.6.7.8"}, []string{XForwardedFor}, "10.0.0.1"},
+		{"ipv6", "[2001:db8::1]:1000", nil, Headers, "2001:db8::1"},
+		{"ipv4-mapped", "[::ffff:1.2.3.4]:1000", nil, Headers, "1.2.3.4"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		for header, value := range tt.headers {
+			r.Header.Set(header, value)
+		}
+		if got := Resolve(r, trustedProxy, tt.prefer); got.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	allowed := netip.MustParsePrefix("192.168.0.0/16")
+	forbidden := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) })
+	resolve := func(r *http.Request) netip.Addr { return netip.MustParseAddr(r.Header.Get(XRealIP)) }
+	h := Middleware(resolve)(Allow(allowed.Contains, forbidden)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	for ip, want := range map[string]int{"192.168.1.1": http.StatusOK, "8.8.8.8": http.StatusForbidden} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(XRealIP, ip)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", ip, want, w.Code)
+		}
+	}
+}
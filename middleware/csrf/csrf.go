@@ -5,21 +5,25 @@
 package csrf
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/htetmyatthar/lothone/middleware/session"
+	"github.com/alexedwards/scs/v2"
+	"github.com/htetmyatthar/lothone/internal/logging"
 )
 
 const (
@@ -29,21 +33,107 @@ const (
 	CSRFCookieName string = "lothone_token"
 )
 
-// csrfKey is the key to use for csrf middleware. This is generated at the start of the program.
-var (
-	csrfKey            string
-	CSRFTrustedOrigins []string
-)
+// CSRF generates and checks the csrf tokens of the sessions.
+type CSRF struct {
+	sessions *scs.SessionManager
+	store    KeyStore
+
+	// mu guards the keys and the rotation.
+	mu       sync.Mutex
+	keys     Keys
+	rotation time.Duration
+
+	// TrustedOrigins are the hosts other than the panel itself that are allowed to refer to the panel.
+	TrustedOrigins []string
+}
+
+// Keys are the secret keys the tokens are signed with. The tokens of the Previous key are still
+// valid for the csrfTimeout after the rotation, so the open pages keep working.
+type Keys struct {
+	Current   []byte    `json:"current"`
+	Previous  []byte    `json:"previous,omitempty"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// KeyStore keeps the keys over the restarts, Load returns nil if there's none saved yet.
+type KeyStore interface {
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+// New returns the CSRF with the keys of the store, a new key is made and saved if there's none.
+// The key is rotated every rotation, never if it's 0.
+func New(sessions *scs.SessionManager, store KeyStore, rotation time.Duration) (*CSRF, error) {
+	c := &CSRF{sessions: sessions, store: store, rotation: rotation}
+
+	data, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		err = json.Unmarshal(data, &c.keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.keys.Current) == 0 {
+		err = c.rotate(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("New csrf secret key is generated.")
+	}
+	return c, nil
+}
 
-func init() {
-	// generate new csrf key at the start of the program.
+// SetRotation changes how often the key is rotated, never if it's 0.
+func (c *CSRF) SetRotation(rotation time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rotation = rotation
+}
+
+// validKeys returns the current key and the previous one if it's still in the grace window,
+// rotating the key first if it's time to.
+func (c *CSRF) validKeys(now time.Time) [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rotation > 0 && now.Sub(c.keys.RotatedAt) >= c.rotation {
+		err := c.rotate(now)
+		if err != nil {
+			// the current key keeps being used until the next try.
+			slog.Error("rotating the csrf key gone wrong.", "err", err)
+		} else {
+			slog.Info("The csrf secret key is rotated.")
+		}
+	}
+
+	keys := [][]byte{c.keys.Current}
+	if len(c.keys.Previous) != 0 && now.Sub(c.keys.RotatedAt) < csrfTimeout {
+		keys = append(keys, c.keys.Previous)
+	}
+	return keys
+}
+
+// rotate makes a new current key and saves the keys, the mu must be held if the c is in use.
+func (c *CSRF) rotate(now time.Time) error {
 	key := make([]byte, 32)
 	_, err := rand.Read(key)
 	if err != nil {
-		panic(err) // NOTE: stop if the key generation gone wrong.
+		return err
+	}
+	keys := Keys{Current: key, Previous: c.keys.Current, RotatedAt: now}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	err = c.store.Save(data)
+	if err != nil {
+		return err
 	}
-	csrfKey = string(key)
-	log.Println("New csrf token secret key is generated: ", csrfKey)
+	c.keys = keys
+	return nil
 }
 
 // csrfTimeout is the duration for which XSRF tokens are valid.
@@ -60,8 +150,9 @@ func clean(s string) string {
 // path is the cookie path.("Be careful of the trailing slashes('/')")
 // key is a secret key for your application; it must be non-empty.
 // userID is an optional unique identifier for the user.
-func Generate(w http.ResponseWriter, path, userID string) string {
-	token := generateTokenAtTime(csrfKey, userID, "", time.Now())
+func (c *CSRF) Generate(w http.ResponseWriter, path, userID string) string {
+	now := time.Now()
+	token := generateTokenAtTime(string(c.validKeys(now)[0]), userID, "", now)
 	// Create a new cookie with the CSRF token
 	cookie := &http.Cookie{
 		Name:     CSRFCookieName,
@@ -94,16 +185,33 @@ func generateTokenAtTime(key, userID, actionID string, now time.Time) string {
 	return fmt.Sprintf("%s:%d", tok, milliTime)
 }
 
-// Valid reports whether a token is a valid, unexpired token returned by Generate.
-// The token is considered to be expired and invalid if it is older than the default Timeout.
-func Valid(token, userID, actionID string) bool {
-	return validTokenAtTime(token, csrfKey, userID, actionID, time.Now(), csrfTimeout)
+// Valid reports whether a token is a valid, unexpired token returned by Generate, or by the
+// Token for the actionID. The tokens of the previous key are valid in the grace window.
+func (c *CSRF) Valid(token, userID, actionID string) bool {
+	return c.ValidFor(token, userID, actionID, csrfTimeout)
 }
 
 // ValidFor reports whether a token is a valid, unexpired token returned by Generate.
 // The token is considered to be expired and invalid if it is older than the timeout duration.
-func ValidFor(token, key, userID, actionID string, timeout time.Duration) bool {
-	return validTokenAtTime(token, csrfKey, userID, actionID, time.Now(), timeout)
+func (c *CSRF) ValidFor(token, userID, actionID string, timeout time.Duration) bool {
+	now := time.Now()
+	for _, key := range c.validKeys(now) {
+		if validTokenAtTime(token, string(key), userID, actionID, now, timeout) {
+			return true
+		}
+	}
+	return false
+}
+
+// stale reports whether the token is only valid with the previous key, so the page should get new tokens.
+func (c *CSRF) stale(token, userID string) bool {
+	now := time.Now()
+	return !validTokenAtTime(token, string(c.validKeys(now)[0]), userID, "", now, csrfTimeout)
+}
+
+// ActionID is the action the tokens of the method and the route are bound to.
+func ActionID(method, route string) string {
+	return method + " " + route
 }
 
 // validTokenAtTime reports whether a token is valid at the given time.
@@ -135,21 +243,52 @@ func validTokenAtTime(token, key, userID, actionID string, now time.Time, timeou
 	}
 
 	expected := generateTokenAtTime(key, userID, actionID, issueTime)
-	log.Println("\n\n\n\n\n")
-	log.Println("csrf token is generated for expectation:\n key: ", key, "\nuser:", userID, "\naction: ", actionID, "\ntime: ", issueTime)
-	log.Println("\n\n\n\n\n")
-	log.Println("\nexpected: ", expected, "\nresult: ", token)
-	log.Println("\n\n\n\n\n")
 
 	// Check that the token matches the expected value.
 	// Use constant time comparison to avoid timing attacks.
 	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
 }
 
+type (
+	exemptKey struct{}
+	csrfKey   struct{}
+)
+
+// Exempt marks the r to skip the csrf check. It must only be used for the requests that are
+// authenticated without the cookies, eg. with the api tokens, which the browsers don't send by themselves.
+func Exempt(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), exemptKey{}, true))
+}
+
+// Token returns the tokens of the actions, in the form of "METHOD /route", for the page with the
+// pageToken returned by the Generate, to be sent as the X-CSRF-TOKEN header or the token field.
+// Each of the tokens is only valid for its own action. It's for the templates rendered with the
+// context of the request, it's empty without the CSRFMiddleware.
+func Token(ctx context.Context, pageToken string, actions ...string) string {
+	c, ok := ctx.Value(csrfKey{}).(*CSRF)
+	if !ok || pageToken == "" {
+		return ""
+	}
+	now := time.Now()
+	key := string(c.validKeys(now)[0])
+	tokens := make([]string, len(actions))
+	for i, action := range actions {
+		tokens[i] = generateTokenAtTime(key, pageToken, action, now)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Header returns the Token as the hx-headers.
+func Header(ctx context.Context, pageToken string, actions ...string) string {
+	return `{"X-CSRF-TOKEN": "` + Token(ctx, pageToken, actions...) + `"}`
+}
+
 // CSRFMiddleware prevents csrf attacks via checking it has the valid csrf using
-// double submit cookie pattern (cookie + [body/header])
-func CSRFMiddleware(next http.Handler) http.Handler {
+// double submit cookie pattern (cookie + [body/header]). The cookie is the page token of the
+// session and the submitted one is the Token of the page token for the method and the route of the request.
+func (c *CSRF) CSRFMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), csrfKey{}, c))
 
 		// Skip CSRF check for GET, HEAD, OPTIONS, TRACE as they're typically safe
 		if r.Method == http.MethodGet || r.Method == http.MethodHead ||
@@ -158,22 +297,27 @@ func CSRFMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if exempt, _ := r.Context().Value(exemptKey{}).(bool); exempt {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Check origin for HTTPS connections
 		if r.URL.Scheme == "https" {
 			referer, err := url.Parse(r.Referer())
 			if err != nil || referer.String() == "" {
-				log.Printf("Invalid or missing referer: %v", err)
+				logging.FromContext(r.Context()).Warn("Invalid or missing referer.", "err", err)
 				unauthorizedHandler(w, "No referer")
 				return
 			}
 
 			valid := (r.URL.Scheme == referer.Scheme && r.URL.Host == referer.Host)
 			if !valid {
-				valid = slices.Contains(CSRFTrustedOrigins, referer.Host)
+				valid = slices.Contains(c.TrustedOrigins, referer.Host)
 			}
 
 			if !valid {
-				log.Printf("Invalid referer: %s", referer.String())
+				logging.FromContext(r.Context()).Warn("Invalid referer.", "referer", referer.String())
 				unauthorizedHandler(w, "Bad referer")
 				return
 			}
@@ -182,51 +326,53 @@ func CSRFMiddleware(next http.Handler) http.Handler {
 		// Get CSRF token from cookie
 		cookieToken, err := r.Cookie(CSRFCookieName)
 		if err != nil {
-			log.Printf("CSRF cookie not found: %v", err)
-			// Redirect to GET endpoint to generate new token
-			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			invalidToken(w, r, "the csrf cookie is not found")
 			return
 		}
 
 		// Get CSRF token from request (header/form)
 		token, err := getToken(r)
 		if err != nil || token == "" {
-			log.Printf("CSRF token not present in request: %v", err)
-			// Redirect to GET endpoint to generate new token
-			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			invalidToken(w, r, "the csrf token is not present in the request")
 			return
 		}
 
-		// Compare tokens using constant-time comparison
-		if subtle.ConstantTimeCompare([]byte(cookieToken.Value), []byte(token)) != 1 {
-			log.Printf("CSRF tokens don't match. Cookie: %s, Request: %s",
-				cookieToken.Value, token)
-			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		// Validate the signatures and the expirations, the page token with the session
+		// and the submitted one with the page token and the action.
+		sToken := c.sessions.Token(r.Context())
+		if !c.Valid(cookieToken.Value, sToken, "") {
+			invalidToken(w, r, "the csrf cookie is invalid or expired")
 			return
 		}
-
-		sToken := session.GetSessionMgr().Token(r.Context())
-		// Validate token signature and expiration
-		/*
-			actionID := r.Method + r.URL.String()
-			if r.URL.String() == "/accounts" { // for accounts path.
-				actionID = AccountsActionID
-			}
-		*/
-		actionID := ""
-		if !Valid(token, sToken, actionID) {
-			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		actionID := ActionID(r.Method, r.URL.Path)
+		if !slices.ContainsFunc(strings.Fields(token), func(t string) bool { return c.Valid(t, cookieToken.Value, actionID) }) {
+			invalidToken(w, r, "the csrf token is not of the page or the action")
 			return
 		}
 
+		// the page of the previous key gets new tokens before they expire.
+		if c.stale(cookieToken.Value, sToken) {
+			w.Header().Set("X-CSRF-Stale", "true")
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// invalidToken refuses the request with the invalid csrf token, the htmx pages are refreshed
+// to get the new tokens.
+func invalidToken(w http.ResponseWriter, r *http.Request, reason string) {
+	logging.FromContext(r.Context()).Warn("Forbidden request.", "method", r.Method, "path", r.URL.Path, "reason", reason)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Refresh", "true")
+	}
+	unauthorizedHandler(w, "Invalid csrf token, refresh the page.")
+}
+
 // unauthorizedhandler sets a HTTP 403 Forbidden status and writes the
 // CSRF failure reason to the response.
 func unauthorizedHandler(w http.ResponseWriter, reason string) {
-	http.Error(w, http.StatusText(http.StatusForbidden)+reason, http.StatusForbidden)
+	http.Error(w, http.StatusText(http.StatusForbidden)+": "+reason, http.StatusForbidden)
 }
 
 // getToken gets the csrf token from the http header or form to check with csrf cookie.
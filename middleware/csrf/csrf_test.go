@@ -5,9 +5,14 @@
 package csrf
 
 import (
+	"context"
 	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/alexedwards/scs/v2"
 )
 
 const (
@@ -114,3 +119,87 @@ func TestValidateBadData(t *testing.T) {
 		}
 	}
 }
+
+// memStore is the KeyStore in the memory.
+type memStore struct {
+	data []byte
+}
+
+func (s *memStore) Load() ([]byte, error) { return s.data, nil }
+
+func (s *memStore) Save(data []byte) error {
+	s.data = data
+	return nil
+}
+
+func TestKeys(t *testing.T) {
+	store := &memStore{}
+	c, err := New(scs.New(), store, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := generateTokenAtTime(string(c.keys.Current), userID, actionID, time.Now())
+
+	// the key is kept over the restarts.
+	c, err = New(scs.New(), store, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Valid(tok, userID, actionID) {
+		t.Error("Expected the token to be valid with the saved key")
+	}
+
+	// the tokens of the previous key are valid but stale after the rotation.
+	c.SetRotation(time.Hour)
+	c.keys.RotatedAt = time.Now().Add(-2 * time.Hour)
+	if !c.Valid(tok, userID, actionID) {
+		t.Error("Expected the token to be valid after the rotation")
+	}
+	if !c.stale(generateTokenAtTime(string(c.keys.Previous), userID, "", time.Now()), userID) {
+		t.Error("Expected the token of the previous key to be stale")
+	}
+	if c.stale(generateTokenAtTime(string(c.keys.Current), userID, "", time.Now()), userID) {
+		t.Error("Expected the token of the current key not to be stale")
+	}
+
+	// the previous key is dropped after the grace window.
+	c.SetRotation(0)
+	c.keys.RotatedAt = time.Now().Add(-csrfTimeout)
+	if c.Valid(tok, userID, actionID) {
+		t.Error("Expected the token to be invalid after the grace window")
+	}
+}
+
+func TestMiddlewareActions(t *testing.T) {
+	sessions := scs.New()
+	c, err := New(sessions, &memStore{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sessions.LoadAndSave(c.CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	// the new session has the empty token.
+	page := generateTokenAtTime(string(c.keys.Current), "", "", time.Now())
+	ctx := context.WithValue(context.Background(), csrfKey{}, c)
+
+	tests := []struct {
+		name, token string
+		code        int
+	}{
+		{"Action", Token(ctx, page, "POST /form"), http.StatusOK},
+		{"One of the actions", Token(ctx, page, "DELETE /form", "POST /form"), http.StatusOK},
+		{"Other action", Token(ctx, page, "POST /other"), http.StatusForbidden},
+		{"Page token", page, http.StatusForbidden},
+		{"Other page", Token(ctx, page+"1", "POST /form"), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/form", nil)
+		r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: page})
+		r.Header.Set("X-CSRF-TOKEN", tt.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%v: Expected %d, got %d", tt.name, tt.code, w.Code)
+		}
+	}
+}
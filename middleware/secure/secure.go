@@ -0,0 +1,100 @@
+// Package secure sends the security headers of the pages, with the Content-Security-Policy
+// of a nonce made for every request.
+package secure
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/htetmyatthar/lothone/internal/logging"
+)
+
+// Options are the security headers to send.
+type Options struct {
+	// Off sends none of the headers.
+	Off bool
+	// Sources are the origins other than the panel the scripts, the styles and the images are from.
+	Sources []string
+	// ReportOnly only reports the violations of the policy.
+	ReportOnly bool
+	// HSTS is the max age of the Strict-Transport-Security, it's not sent if it's 0.
+	HSTS time.Duration
+}
+
+// Policy returns the Content-Security-Policy allowing the scripts of the nonce and the sources.
+// The alpine and the htmx evaluate the attributes like x-data and the hx-trigger filters as scripts,
+// so the eval can't be disallowed. The inline styles are allowed for the htmx indicators and the components.
+func Policy(nonce string, sources []string) string {
+	others := ""
+	if len(sources) != 0 {
+		others = " " + strings.Join(sources, " ")
+	}
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-" + nonce + "' 'unsafe-eval'" + others,
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com" + others,
+		"font-src 'self' https://fonts.gstatic.com" + others,
+		"img-src 'self' data: blob:" + others,
+		"connect-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// Middleware sends the security headers of the options, which are read on every request so the
+// reloads apply at once. The nonce is given to the templ components with the context of the request,
+// the script tags get it with the templ.GetNonce.
+func Middleware(options func() Options) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			o := options()
+			if o.Off {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			nonce, err := newNonce()
+			if err != nil {
+				logging.FromContext(r.Context()).Error("making the csp nonce gone wrong.", "err", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			r = r.WithContext(templ.WithNonce(r.Context(), nonce))
+
+			header := "Content-Security-Policy"
+			if o.ReportOnly {
+				header = "Content-Security-Policy-Report-Only"
+			}
+			h := w.Header()
+			h.Set(header, Policy(nonce, o.Sources))
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			// the csrf check needs the referer of the panel's own requests.
+			h.Set("Referrer-Policy", "same-origin")
+			h.Set("Cross-Origin-Opener-Policy", "same-origin")
+			h.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=()")
+			if o.HSTS > 0 && r.TLS != nil {
+				h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(o.HSTS.Seconds())))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newNonce returns a random nonce of 128 bits.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
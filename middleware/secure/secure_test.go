@@ -0,0 +1,61 @@
+package secure
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+func TestMiddleware(t *testing.T) {
+	var nonce string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = templ.GetNonce(r.Context())
+	})
+
+	options := Options{Sources: []string{"https://cdn.example.com"}, HSTS: 24 * time.Hour}
+	h := Middleware(func() Options { return options })(next)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	csp := w.Header().Get("Content-Security-Policy")
+	if nonce == "" || !strings.Contains(csp, "'nonce-"+nonce+"'") {
+		t.Errorf("expected the policy with the nonce %q, got %q", nonce, csp)
+	}
+	if !strings.Contains(csp, "https://cdn.example.com") {
+		t.Errorf("expected the policy with the sources, got %q", csp)
+	}
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("expected no hsts without the tls")
+	}
+
+	previous := nonce
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if nonce == previous {
+		t.Error("expected a new nonce for every request")
+	}
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=86400" {
+		t.Errorf("expected the hsts of a day, got %q", got)
+	}
+
+	options = Options{ReportOnly: true}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("Content-Security-Policy") != "" || w.Header().Get("Content-Security-Policy-Report-Only") == "" {
+		t.Error("expected only the report only policy")
+	}
+
+	options = Options{Off: true}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(w.Header()) != 0 {
+		t.Errorf("expected no headers, got %v", w.Header())
+	}
+}
@@ -1,7 +1,7 @@
 package session
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -9,23 +9,20 @@ import (
 	"github.com/htetmyatthar/lothone/internal/config"
 )
 
-var (
-	sessionMgr *scs.SessionManager
-)
-
 const (
 	sessionName string = "lothone_id"
 )
 
-func init() {
-	sessionMgr = scs.New()
+// New returns the session manager of the panel configured with the c, keeping the sessions in the store.
+func New(c *config.Config, store scs.Store) *scs.SessionManager {
+	sessionMgr := scs.New()
+	sessionMgr.Store = store
 
-	sessionMgr.Lifetime = 36 * time.Hour
-	sessionMgr.IdleTimeout = 12 * time.Hour
+	SetTimeouts(sessionMgr, c)
 
 	// Cookie configuration
 	sessionMgr.Cookie.Name = config.SessionCookieName
-	sessionMgr.Cookie.Domain = *config.WebHost
+	sessionMgr.Cookie.Domain = c.WebHost
 
 	sessionMgr.Cookie.HttpOnly = true
 	sessionMgr.Cookie.Persist = false
@@ -36,10 +33,14 @@ func init() {
 	sessionMgr.Cookie.Path = "/"
 
 	// Log for debugging
-	log.Printf("Session cookie configured: Secure=%v, SameSite=%v, Path=%s, Hostname:%s", sessionMgr.Cookie.Secure, sessionMgr.Cookie.SameSite, sessionMgr.Cookie.Path, sessionMgr.Cookie.Domain)
+	slog.Debug("Session cookie configured.", "secure", sessionMgr.Cookie.Secure, "same_site", sessionMgr.Cookie.SameSite, "path", sessionMgr.Cookie.Path, "domain", sessionMgr.Cookie.Domain)
+	return sessionMgr
 }
 
-// GetSessionMgr returns the singleton session manager for the application.
-func GetSessionMgr() *scs.SessionManager {
-	return sessionMgr
+// SetTimeouts sets the lifetime and the idle timeout of the sessions of the s from the c, the
+// reloads call it too so the new sessions and the ones being used follow the reloaded durations.
+func SetTimeouts(s *scs.SessionManager, c *config.Config) {
+	s.Lifetime = time.Duration(c.SessionDuration) * time.Minute
+	// the sessions shorter than the idle timeout are never idle for long enough.
+	s.IdleTimeout = time.Duration(min(c.SessionIdle, c.SessionDuration)) * time.Minute
 }
@@ -0,0 +1,46 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log/slog"
+	"sync"
+)
+
+// fingerprints are the short hashes of the contents of the static files by their names, like "js/webauthn.js".
+var fingerprints = sync.OnceValue(func() map[string]string {
+	files := map[string]string{}
+	err := fs.WalkDir(WebFS, "static", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := WebFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		files[path[len("static/"):]] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+	if err != nil {
+		slog.Error("fingerprinting the static files gone wrong.", "err", err)
+	}
+	return files
+})
+
+// Path returns the url of the static file with the fingerprint of its content, so the browsers
+// cache it until it's changed.
+func Path(name string) string {
+	v, ok := fingerprints()[name]
+	if !ok {
+		return "/static/" + name
+	}
+	return "/static/" + name + "?v=" + v
+}
+
+// Fingerprinted reports whether the v is the fingerprint of the current content of the file.
+func Fingerprinted(name, v string) bool {
+	fingerprint, ok := fingerprints()[name]
+	return ok && v == fingerprint
+}
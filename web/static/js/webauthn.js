@@ -0,0 +1,73 @@
+// passkey registers and uses the passkeys with the browser, the options and the results are
+// sent to the panel as json with the binary values in base64url.
+window.passkey = {
+	supported() {
+		return window.PublicKeyCredential !== undefined;
+	},
+
+	encode(buffer) {
+		const bytes = new Uint8Array(buffer);
+		let binary = "";
+		for (const b of bytes) {
+			binary += String.fromCharCode(b);
+		}
+		return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+	},
+
+	decode(value) {
+		const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+		const binary = atob(base64 + "===".slice((base64.length + 3) % 4));
+		return Uint8Array.from(binary, (c) => c.charCodeAt(0));
+	},
+
+	async post(url, token, body) {
+		const response = await fetch(url, {
+			method: "POST",
+			headers: { "Content-Type": "application/json", "X-CSRF-TOKEN": token },
+			body: JSON.stringify(body || {}),
+		});
+		if (!response.ok) {
+			throw new Error((await response.text()).trim());
+		}
+		return response.json();
+	},
+
+	// login logs in with a passkey using the csrf token and the remember me of the form.
+	async login(form) {
+		const token = form.querySelector("[name=token]").value;
+		const remember = form.querySelector("[name=remember]")?.checked ?? false;
+
+		const options = await this.post("/login/passkey/begin", token);
+		options.challenge = this.decode(options.challenge);
+		options.allowCredentials.forEach((c) => (c.id = this.decode(c.id)));
+
+		const credential = await navigator.credentials.get({ publicKey: options });
+		const result = await this.post("/login/passkey", token, {
+			id: credential.id,
+			clientDataJSON: this.encode(credential.response.clientDataJSON),
+			authenticatorData: this.encode(credential.response.authenticatorData),
+			signature: this.encode(credential.response.signature),
+			userHandle: credential.response.userHandle ? this.encode(credential.response.userHandle) : "",
+			remember: remember,
+		});
+		window.location.href = result.redirect;
+	},
+
+	// register registers a new passkey with the name after verifying again with the password or the
+	// code of the verification, and shows the passkeys again.
+	async register(token, name, verification) {
+		const options = await this.post("/passkeys/begin", token, verification);
+		options.challenge = this.decode(options.challenge);
+		options.user.id = this.decode(options.user.id);
+		options.excludeCredentials.forEach((c) => (c.id = this.decode(c.id)));
+
+		const credential = await navigator.credentials.create({ publicKey: options });
+		await this.post("/passkeys", token, {
+			name: name,
+			id: credential.id,
+			clientDataJSON: this.encode(credential.response.clientDataJSON),
+			attestationObject: this.encode(credential.response.attestationObject),
+		});
+		htmx.ajax("GET", "/passkeys", { target: "#main-content", swap: "outerHTML" });
+	},
+};